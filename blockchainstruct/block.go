@@ -3,9 +3,12 @@ package blockchainstruct
 import (
 	"bytes"
 	"encoding/gob" //gob is the library used for encoding data (serialisation which can be done through protobufs as well for data streams in binary format
+	"errors"
+	"fmt"
 	"log"
 	"time"
 
+	"github.com/cyprus09/blockchain/chaincfg"
 	"github.com/cyprus09/blockchain/merkletree"
 )
 
@@ -41,6 +44,34 @@ func (b *Block) HashTransactions() []byte {
 	return mTree.RootNode.Data
 }
 
+// CheckCoinbase checks that the block has a single coinbase paying no more than the
+// subsidy of its height. Fees are not paid to the miner, so the subsidy is the limit
+func (b *Block) CheckCoinbase() error {
+	coinbases := 0
+	for _, tx := range b.Transactions {
+		if !tx.IsCoinbase() {
+			continue
+		}
+		coinbases++
+
+		value := 0
+		for _, out := range tx.VOut {
+			if out.Value < 0 {
+				return errors.New("coinbase pays a negative value")
+			}
+			value += out.Value
+		}
+		if subsidy := chaincfg.ActiveParams().Subsidy(b.Height); value > subsidy {
+			return fmt.Errorf("coinbase pays %d, the subsidy at height %d is %d", value, b.Height, subsidy)
+		}
+	}
+	if coinbases != 1 {
+		return fmt.Errorf("block has %d coinbase transactions", coinbases)
+	}
+
+	return nil
+}
+
 // NewBlock creates and returns a new Block
 func NewBlock(transactions []*Transaction, prevBlockHash []byte, height int) *Block {
 	block := &Block{time.Now().Unix(), transactions, prevBlockHash, []byte{}, 0, height}
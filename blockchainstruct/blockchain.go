@@ -8,20 +8,26 @@ import (
 	"fmt"
 	"log"
 	"os"
+	"time"
 
 	"github.com/boltdb/bolt"
+	"github.com/cyprus09/blockchain/chaincfg"
+	"github.com/cyprus09/blockchain/utils"
 )
 
 const (
-	dbFile              = "blockchain_%s.db"
-	blocksBucket        = "blocks"
-	genesisCoinbaseData = "The Times 03/Jan/2009 Chancellor on brink of second bailout for banks"
+	dbFile       = "blockchain_%s.db"
+	blocksBucket = "blocks"
+	// dbOpenTimeout is how long to wait for the lock on the DB, which a running node holds
+	dbOpenTimeout = time.Second
 )
 
 // Blockchain keeps a sequence of Blocks in the blockchain
 type Blockchain struct {
 	tip []byte
 	DB  *bolt.DB
+	// handlers are called for every block that joins or leaves the best chain
+	handlers []func(ChainEvent)
 }
 
 func dbExists(dbFile string) bool {
@@ -32,7 +38,22 @@ func dbExists(dbFile string) bool {
 	return true
 }
 
-// MineBlock saves the provided data as a block in the blockchain
+// openDB opens the DB, exiting with a hint to use RPC when a running node holds it
+func openDB(dbFile string) *bolt.DB {
+	db, err := bolt.Open(dbFile, 0600, &bolt.Options{Timeout: dbOpenTimeout})
+	if err == bolt.ErrTimeout {
+		fmt.Println("ERROR: The blockchain is in use by a running node, use its RPC or stop it first.")
+		os.Exit(1)
+	}
+	if err != nil {
+		log.Panic(err)
+	}
+
+	return db
+}
+
+// MineBlock saves the provided data as a block in the blockchain. It returns nil when
+// mining was cancelled
 func (bc *Blockchain) MineBlock(transactions []*Transaction) *Block {
 	var lastHash []byte
 	var lastHeight int
@@ -46,7 +67,7 @@ func (bc *Blockchain) MineBlock(transactions []*Transaction) *Block {
 
 	err := bc.DB.View(func(tx *bolt.Tx) error {
 		b := tx.Bucket([]byte(blocksBucket))
-		lastHash = b.Get([]byte("l"))
+		lastHash = append([]byte{}, b.Get([]byte("l"))...)
 
 		blockData := b.Get(lastHash)
 		block := DeserializeBlock(blockData)
@@ -60,6 +81,9 @@ func (bc *Blockchain) MineBlock(transactions []*Transaction) *Block {
 	}
 
 	newBlock := NewBlock(transactions, lastHash, lastHeight + 1)
+	if newBlock.CurrHash == nil {
+		return nil
+	}
 
 	err = bc.DB.Update(func(tx *bolt.Tx) error {
 		b := tx.Bucket([]byte(blocksBucket))
@@ -82,12 +106,14 @@ func (bc *Blockchain) MineBlock(transactions []*Transaction) *Block {
 	if err != nil {
 		log.Panic(err)
 	}
+
+	bc.notify(ChainEvent{BlockConnected, newBlock})
 	return newBlock
 }
 
 // FindUTXO finds and returns all unspent transaction outputs
-func (bc *Blockchain) FindUTXO() map[string]TxOutputs {
-	UTXO := make(map[string]TxOutputs)
+func (bc *Blockchain) FindUTXO() []UTXO {
+	var UTXOs []UTXO
 	spentTXOs := make(map[string][]int)
 	bci := bc.Iterator()
 
@@ -108,9 +134,8 @@ func (bc *Blockchain) FindUTXO() map[string]TxOutputs {
 					}
 				}
 
-				outs := UTXO[txID]
-				outs.Outputs = append(outs.Outputs, out)
-				UTXO[txID] = outs
+				entry := UTXOEntry{out.Value, out.PubKeyHash, block.Height, tx.IsCoinbase()}
+				UTXOs = append(UTXOs, UTXO{tx.ID, outIdx, entry})
 			}
 
 			if !tx.IsCoinbase() {
@@ -126,7 +151,7 @@ func (bc *Blockchain) FindUTXO() map[string]TxOutputs {
 		}
 	}
 
-	return UTXO
+	return UTXOs
 }
 
 // Iterator returns a BlockchainIterator
@@ -157,8 +182,9 @@ func (bc *Blockchain) FindTransaction(ID []byte) (Transaction, error) {
 }
 
 // NewBlockChain creates a new Blockchain with the genesis block
-func NewBlockchain(nodeID string) *Blockchain {
-	dbFile := fmt.Sprintf(dbFile, nodeID)
+// It returns an error when the chainstate was written by a newer version
+func NewBlockchain(nodeID string) (*Blockchain, error) {
+	dbFile := utils.DataPath(fmt.Sprintf(dbFile, nodeID))
 	if !dbExists(dbFile) {
 		fmt.Println("No existing blockchain found. Create one first.")
 		os.Exit(1)
@@ -166,14 +192,11 @@ func NewBlockchain(nodeID string) *Blockchain {
 
 	var tip []byte
 
-	db, err := bolt.Open(dbFile, 0600, nil)
-	if err != nil {
-		log.Panic(err)
-	}
+	db := openDB(dbFile)
 
-	err = db.Update(func(tx *bolt.Tx) error {
+	err := db.Update(func(tx *bolt.Tx) error {
 		b := tx.Bucket([]byte(blocksBucket))
-		tip = b.Get([]byte("l"))
+		tip = append([]byte{}, b.Get([]byte("l"))...)
 
 		return nil
 	})
@@ -182,14 +205,21 @@ func NewBlockchain(nodeID string) *Blockchain {
 		log.Panic(err)
 	}
 
-	bc := Blockchain{tip, db}
+	bc := Blockchain{tip: tip, DB: db}
 
-	return &bc
+	UTXOSet := UTXOSet{&bc}
+	err = UTXOSet.upgradeChainstate()
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	return &bc, nil
 }
 
 // CreateBlockchain creates a new blockchain DB
 func CreateBlockchain(address, nodeID string) *Blockchain {
-	dbFile := fmt.Sprintf(dbFile, nodeID)
+	dbFile := utils.DataPath(fmt.Sprintf(dbFile, nodeID))
 	if dbExists(dbFile) {
 		fmt.Println("Blockchain already exists.")
 		os.Exit(1)
@@ -197,15 +227,12 @@ func CreateBlockchain(address, nodeID string) *Blockchain {
 
 	var tip []byte
 
-	cbtx := NewCoinbaseTx(address, genesisCoinbaseData)
+	cbtx := NewCoinbaseTx(address, chaincfg.ActiveParams().GenesisCoinbaseData, 0)
 	genesis := NewGenesisBlock(cbtx)
 
-	db, err := bolt.Open(dbFile, 0600, nil)
-	if err != nil {
-		log.Panic(err)
-	}
+	db := openDB(dbFile)
 
-	err = db.Update(func(tx *bolt.Tx) error {
+	err := db.Update(func(tx *bolt.Tx) error {
 		b, err := tx.CreateBucket([]byte(blocksBucket))
 		if err != nil {
 			log.Panic(err)
@@ -229,13 +256,20 @@ func CreateBlockchain(address, nodeID string) *Blockchain {
 		log.Panic(err)
 	}
 
-	bc := Blockchain{tip, db}
+	bc := Blockchain{tip: tip, DB: db}
 
 	return &bc
 }
 
-// AddBlock saves the block into the blockchain
-func (bc *Blockchain) AddBlock(block *Block) {
+// AddBlock saves the block into the blockchain. Blocks whose coinbase pays more than
+// the subsidy are rejected
+func (bc *Blockchain) AddBlock(block *Block) error {
+	var oldTip []byte
+
+	if err := block.CheckCoinbase(); err != nil {
+		return err
+	}
+
 	err := bc.DB.Update(func(tx *bolt.Tx) error {
 		b := tx.Bucket([]byte(blocksBucket))
 		blockInDb := b.Get(block.CurrHash)
@@ -260,12 +294,61 @@ func (bc *Blockchain) AddBlock(block *Block) {
 				log.Panic(err)
 			}
 			bc.tip = block.CurrHash
+			oldTip = append([]byte{}, lastHash...)
 		}
 		return nil
 	})
 	if err != nil {
 		log.Panic(err)
 	}
+
+	if oldTip != nil {
+		disconnected, connected := bc.tipChange(oldTip, block)
+		for _, b := range disconnected {
+			bc.notify(ChainEvent{BlockDisconnected, b})
+		}
+		for _, b := range connected {
+			bc.notify(ChainEvent{BlockConnected, b})
+		}
+	}
+
+	return nil
+}
+
+// tipChange returns the blocks that left the best chain when the tip moved from oldTip
+// to newTip, newest first, and those that joined it, oldest first. When a block between
+// them is not known yet only newTip is reported as connected
+func (bc *Blockchain) tipChange(oldTip []byte, newTip *Block) ([]*Block, []*Block) {
+	var disconnected, connected []*Block
+
+	old, err := bc.GetBlock(oldTip)
+	if err != nil {
+		return nil, []*Block{newTip}
+	}
+	oldBlock, newBlock := &old, newTip
+
+	for !bytes.Equal(oldBlock.CurrHash, newBlock.CurrHash) {
+		if newBlock.Height >= oldBlock.Height {
+			connected = append([]*Block{newBlock}, connected...)
+			if len(newBlock.PrevBlockHash) == 0 {
+				return nil, []*Block{newTip}
+			}
+			prev, err := bc.GetBlock(newBlock.PrevBlockHash)
+			if err != nil {
+				return nil, []*Block{newTip}
+			}
+			newBlock = &prev
+		} else {
+			disconnected = append(disconnected, oldBlock)
+			prev, err := bc.GetBlock(oldBlock.PrevBlockHash)
+			if err != nil {
+				return nil, []*Block{newTip}
+			}
+			oldBlock = &prev
+		}
+	}
+
+	return disconnected, connected
 }
 
 // GetBestHeight returns the height of the latest block
@@ -287,6 +370,45 @@ func (bc *Blockchain) GetBestHeight() int {
 	return lastBlock.Height
 }
 
+// GetBestHash returns the hash of the latest block
+func (bc *Blockchain) GetBestHash() []byte {
+	var lastHash []byte
+
+	err := bc.DB.View(func(tx *bolt.Tx) error {
+		b := tx.Bucket([]byte(blocksBucket))
+		lastHash = append([]byte{}, b.Get([]byte("l"))...)
+
+		return nil
+	})
+	if err != nil {
+		log.Panic(err)
+	}
+
+	return lastHash
+}
+
+// GetBlockHash returns the hash of the block at height on the best chain
+func (bc *Blockchain) GetBlockHash(height int) ([]byte, error) {
+	if height < 0 {
+		return nil, fmt.Errorf("block height %d is negative", height)
+	}
+
+	bci := bc.Iterator()
+
+	for {
+		block := bci.Next()
+		if block.Height == height {
+			return block.CurrHash, nil
+		}
+
+		if len(block.PrevBlockHash) == 0 || block.Height < height {
+			break
+		}
+	}
+
+	return nil, fmt.Errorf("no block at height %d", height)
+}
+
 // GetBlock finds a block by its hash and returns it
 func (bc *Blockchain) GetBlock(blockHash []byte) (Block, error) {
 	var block Block
@@ -0,0 +1,29 @@
+package blockchainstruct
+
+// ChainEventType tells whether a block joined or left the best chain
+type ChainEventType int
+
+const (
+	// BlockConnected is sent when a block becomes part of the best chain
+	BlockConnected ChainEventType = iota
+	// BlockDisconnected is sent when a reorganization takes a block off the best chain
+	BlockDisconnected
+)
+
+// ChainEvent is passed to the handlers of Subscribe
+type ChainEvent struct {
+	Type  ChainEventType
+	Block *Block
+}
+
+// Subscribe registers handler to be called after every change of the best chain.
+// Handlers run on the goroutine that changed the chain and must not block
+func (bc *Blockchain) Subscribe(handler func(ChainEvent)) {
+	bc.handlers = append(bc.handlers, handler)
+}
+
+func (bc *Blockchain) notify(event ChainEvent) {
+	for _, handler := range bc.handlers {
+		handler(event)
+	}
+}
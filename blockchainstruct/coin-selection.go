@@ -0,0 +1,225 @@
+package blockchainstruct
+
+import (
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"math/rand"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const bnbMaxTries = 100000
+
+// ErrInsufficientFunds is returned when the available outputs can't cover a payment
+var ErrInsufficientFunds = errors.New("not enough funds in your account")
+
+// CoinSelector picks the unspent outputs that fund a payment of target coins
+type CoinSelector interface {
+	Select(utxos []UTXO, target int) ([]UTXO, error)
+}
+
+// Outpoint references a single output of a transaction
+type Outpoint struct {
+	TxID []byte
+	VOut int
+}
+
+// CoinControl tunes how a new transaction picks and spends its inputs
+type CoinControl struct {
+	// Selector picks the inputs, LargestFirst is used when nil
+	Selector CoinSelector
+	// DustThreshold is the smallest change worth an output, anything below it is left to the miner as fee
+	DustThreshold int
+	// Outpoints, when set, are spent as they are instead of running a selector
+	Outpoints []Outpoint
+	// Spent holds the outpoints, in their String form, that unconfirmed transactions
+	// already spend. They are never selected
+	Spent map[string]bool
+}
+
+// LargestFirst spends the biggest outputs first, keeping the input count low
+type LargestFirst struct{}
+
+// SmallestFirst spends the smallest outputs first, consolidating the wallet
+type SmallestFirst struct{}
+
+// RandomSelector spends outputs in random order
+type RandomSelector struct{}
+
+// BranchAndBound searches for a set of outputs that pays the target exactly, or overshoots it
+// by at most Tolerance so no change is needed. It falls back to LargestFirst when there is no such set
+type BranchAndBound struct {
+	Tolerance int
+}
+
+// NewCoinSelector returns the selector registered under name
+func NewCoinSelector(name string, dustThreshold int) (CoinSelector, error) {
+	switch name {
+	case "", "largest":
+		return LargestFirst{}, nil
+	case "smallest":
+		return SmallestFirst{}, nil
+	case "bnb":
+		return BranchAndBound{Tolerance: dustThreshold}, nil
+	case "random":
+		return RandomSelector{}, nil
+	default:
+		return nil, fmt.Errorf("unknown coin selection strategy %q", name)
+	}
+}
+
+// ParseOutpoint parses an outpoint written as <txid>:<vout>
+func ParseOutpoint(s string) (Outpoint, error) {
+	parts := strings.Split(s, ":")
+	if len(parts) != 2 {
+		return Outpoint{}, fmt.Errorf("outpoint %q is not in <txid>:<vout> form", s)
+	}
+
+	txID, err := hex.DecodeString(parts[0])
+	if err != nil {
+		return Outpoint{}, fmt.Errorf("outpoint %q has an invalid txid: %v", s, err)
+	}
+	if len(txID) == 0 {
+		return Outpoint{}, fmt.Errorf("outpoint %q has no txid", s)
+	}
+
+	vout, err := strconv.Atoi(parts[1])
+	if err != nil || vout < 0 {
+		return Outpoint{}, fmt.Errorf("outpoint %q has an invalid output index", s)
+	}
+
+	return Outpoint{txID, vout}, nil
+}
+
+// ParseOutpoints parses a comma separated list of outpoints, rejecting one listed twice
+func ParseOutpoints(s string) ([]Outpoint, error) {
+	var outpoints []Outpoint
+	seen := make(map[string]bool)
+
+	for _, input := range strings.Split(s, ",") {
+		outpoint, err := ParseOutpoint(strings.TrimSpace(input))
+		if err != nil {
+			return nil, err
+		}
+		if seen[outpoint.String()] {
+			return nil, fmt.Errorf("outpoint %s is listed more than once", outpoint)
+		}
+		seen[outpoint.String()] = true
+		outpoints = append(outpoints, outpoint)
+	}
+
+	return outpoints, nil
+}
+
+// String returns the <txid>:<vout> form of the outpoint
+func (o Outpoint) String() string {
+	return fmt.Sprintf("%x:%d", o.TxID, o.VOut)
+}
+
+// accumulate takes outputs in order until they cover the target
+func accumulate(utxos []UTXO, target int) ([]UTXO, error) {
+	var selected []UTXO
+	total := 0
+
+	for _, utxo := range utxos {
+		if total >= target {
+			break
+		}
+		selected = append(selected, utxo)
+		total += utxo.Value
+	}
+
+	if total < target {
+		return nil, ErrInsufficientFunds
+	}
+
+	return selected, nil
+}
+
+// sortedByValue returns a copy of utxos ordered by value, biggest first when descending is set
+func sortedByValue(utxos []UTXO, descending bool) []UTXO {
+	sorted := append([]UTXO{}, utxos...)
+
+	sort.SliceStable(sorted, func(i, j int) bool {
+		if descending {
+			return sorted[i].Value > sorted[j].Value
+		}
+		return sorted[i].Value < sorted[j].Value
+	})
+
+	return sorted
+}
+
+// Select implements CoinSelector
+func (LargestFirst) Select(utxos []UTXO, target int) ([]UTXO, error) {
+	return accumulate(sortedByValue(utxos, true), target)
+}
+
+// Select implements CoinSelector
+func (SmallestFirst) Select(utxos []UTXO, target int) ([]UTXO, error) {
+	return accumulate(sortedByValue(utxos, false), target)
+}
+
+// Select implements CoinSelector
+func (RandomSelector) Select(utxos []UTXO, target int) ([]UTXO, error) {
+	shuffled := append([]UTXO{}, utxos...)
+	rng := rand.New(rand.NewSource(time.Now().UnixNano()))
+
+	rng.Shuffle(len(shuffled), func(i, j int) {
+		shuffled[i], shuffled[j] = shuffled[j], shuffled[i]
+	})
+
+	return accumulate(shuffled, target)
+}
+
+// Select implements CoinSelector
+func (bnb BranchAndBound) Select(utxos []UTXO, target int) ([]UTXO, error) {
+	sorted := sortedByValue(utxos, true)
+
+	// remaining[i] is the value of all outputs from i onwards, used to prune branches that can't reach the target
+	remaining := make([]int, len(sorted)+1)
+	for i := len(sorted) - 1; i >= 0; i-- {
+		remaining[i] = remaining[i+1] + sorted[i].Value
+	}
+	if remaining[0] < target {
+		return nil, ErrInsufficientFunds
+	}
+
+	var best []int
+	var picked []int
+	tries := 0
+
+	var search func(i, total int) bool
+	search = func(i, total int) bool {
+		tries++
+		if total >= target && total <= target+bnb.Tolerance {
+			best = append([]int{}, picked...)
+			return true
+		}
+		if total > target+bnb.Tolerance || i == len(sorted) || total+remaining[i] < target || tries > bnbMaxTries {
+			return false
+		}
+
+		picked = append(picked, i)
+		if search(i+1, total+sorted[i].Value) {
+			return true
+		}
+		picked = picked[:len(picked)-1]
+
+		return search(i+1, total)
+	}
+
+	if !search(0, 0) {
+		return LargestFirst{}.Select(utxos, target)
+	}
+
+	var selected []UTXO
+	for _, i := range best {
+		selected = append(selected, sorted[i])
+	}
+
+	return selected, nil
+}
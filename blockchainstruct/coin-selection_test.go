@@ -0,0 +1,193 @@
+package blockchainstruct
+
+import (
+	"os"
+	"testing"
+
+	"github.com/cyprus09/blockchain/chaincfg"
+	"github.com/cyprus09/blockchain/utils"
+	"github.com/cyprus09/blockchain/wallets"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+func TestBlockchainstruct(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "Blockchainstruct Suite")
+}
+
+var _ = BeforeSuite(func() {
+	// regtest blocks are mined almost instantly
+	Expect(chaincfg.SetActiveNetwork("regtest")).To(Succeed())
+})
+
+// newTestChain creates a chain in a temporary data directory whose genesis block pays
+// wallet, with its chainstate built
+func newTestChain(wallet *wallets.Wallet) *UTXOSet {
+	dir, err := os.MkdirTemp("", "blockchainstruct")
+	Expect(err).NotTo(HaveOccurred())
+	DeferCleanup(os.RemoveAll, dir)
+	Expect(utils.SetDataDir(dir)).To(Succeed())
+
+	bc := CreateBlockchain(string(wallet.GetAddress()), "test")
+	DeferCleanup(bc.DB.Close)
+
+	UTXOSet := &UTXOSet{Blockchain: bc}
+	UTXOSet.Reindex()
+
+	return UTXOSet
+}
+
+// testUTXOs returns outputs of the given values, each from a transaction of its own
+func testUTXOs(values ...int) []UTXO {
+	var utxos []UTXO
+
+	for i, value := range values {
+		utxos = append(utxos, UTXO{[]byte{byte(i)}, 0, UTXOEntry{Value: value}})
+	}
+
+	return utxos
+}
+
+// utxoValues returns the values of utxos in order
+func utxoValues(utxos []UTXO) []int {
+	values := []int{}
+
+	for _, utxo := range utxos {
+		values = append(values, utxo.Value)
+	}
+
+	return values
+}
+
+// selectionVector is a coin selection and the values of the outputs it should pick, in
+// order, or nil when it should fail for lack of funds
+type selectionVector struct {
+	name     string
+	selector CoinSelector
+	utxos    []int
+	target   int
+	selected []int
+}
+
+var selectionVectors = []selectionVector{
+	{"largest first", LargestFirst{}, []int{5, 1, 3, 8, 2}, 9, []int{8, 5}},
+	{"largest first with one output", LargestFirst{}, []int{5, 1, 3, 8, 2}, 8, []int{8}},
+	{"largest first without enough funds", LargestFirst{}, []int{5, 1, 3}, 10, nil},
+	{"smallest first", SmallestFirst{}, []int{5, 1, 3, 8, 2}, 4, []int{1, 2, 3}},
+	{"smallest first with every output", SmallestFirst{}, []int{5, 1, 3, 8, 2}, 19, []int{1, 2, 3, 5, 8}},
+	{"smallest first without enough funds", SmallestFirst{}, []int{5, 1, 3}, 10, nil},
+	{"branch and bound exact match", BranchAndBound{}, []int{5, 1, 3, 8, 2}, 6, []int{5, 1}},
+	{"branch and bound exact match over several outputs", BranchAndBound{}, []int{4, 4, 7}, 8, []int{4, 4}},
+	{"branch and bound within tolerance", BranchAndBound{Tolerance: 1}, []int{5, 3, 8}, 7, []int{8}},
+	{"branch and bound over tolerance", BranchAndBound{Tolerance: 1}, []int{10, 10}, 5, []int{10}},
+	{"branch and bound falling back to largest first", BranchAndBound{}, []int{2, 6, 9}, 10, []int{9, 6}},
+	{"branch and bound without enough funds", BranchAndBound{}, []int{5, 1, 3}, 10, nil},
+}
+
+// outpointVectors are outpoints in <txid>:<vout> form and the txid and output they parse to
+var outpointVectors = []struct {
+	text string
+	txID []byte
+	vout int
+}{
+	{"00ff:0", []byte{0x00, 0xff}, 0},
+	{"0a0b0c:12", []byte{0x0a, 0x0b, 0x0c}, 12},
+	{"9dd7e86e:4294967295", []byte{0x9d, 0xd7, 0xe8, 0x6e}, 4294967295},
+}
+
+var _ = Describe("Coin selection", func() {
+	Describe("Select", func() {
+		It("should pick the expected outputs", func() {
+			for _, vector := range selectionVectors {
+				selected, err := vector.selector.Select(testUTXOs(vector.utxos...), vector.target)
+
+				if vector.selected == nil {
+					Expect(err).To(Equal(ErrInsufficientFunds), vector.name)
+					continue
+				}
+				Expect(err).NotTo(HaveOccurred(), vector.name)
+				Expect(utxoValues(selected)).To(Equal(vector.selected), vector.name)
+			}
+		})
+
+		It("should leave the given outputs in their order", func() {
+			utxos := testUTXOs(5, 1, 3)
+
+			_, err := SmallestFirst{}.Select(utxos, 4)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(utxoValues(utxos)).To(Equal([]int{5, 1, 3}))
+		})
+	})
+
+	Describe("ParseOutpoint", func() {
+		It("should parse the reference outpoints", func() {
+			for _, vector := range outpointVectors {
+				outpoint, err := ParseOutpoint(vector.text)
+				Expect(err).NotTo(HaveOccurred(), vector.text)
+
+				Expect(outpoint.TxID).To(Equal(vector.txID), vector.text)
+				Expect(outpoint.VOut).To(Equal(vector.vout), vector.text)
+				Expect(outpoint.String()).To(Equal(vector.text))
+			}
+		})
+
+		It("should reject malformed outpoints", func() {
+			for _, invalid := range []string{"", "00ff", "00ff:", ":0", "00ff:0:1", "0g:0", "0ff:0", "00ff:-1", "00ff:x"} {
+				_, err := ParseOutpoint(invalid)
+				Expect(err).To(HaveOccurred(), "parsing %q", invalid)
+			}
+		})
+	})
+
+	Describe("ParseOutpoints", func() {
+		It("should parse a comma separated list", func() {
+			outpoints, err := ParseOutpoints("00ff:0, 00ff:1,0a0b0c:12")
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(outpoints).To(Equal([]Outpoint{{[]byte{0x00, 0xff}, 0}, {[]byte{0x00, 0xff}, 1}, {[]byte{0x0a, 0x0b, 0x0c}, 12}}))
+		})
+
+		It("should reject an outpoint listed twice", func() {
+			_, err := ParseOutpoints("00ff:0,0a0b0c:12,00FF:0")
+			Expect(err).To(MatchError(ContainSubstring("00ff:0 is listed more than once")))
+		})
+	})
+
+	Describe("NewBatchTransaction", func() {
+		var wallet *wallets.Wallet
+		var UTXOSet *UTXOSet
+		var to string
+
+		BeforeEach(func() {
+			wallet = wallets.NewWallet(wallets.KeyTypeP256)
+			UTXOSet = newTestChain(wallet)
+			to = string(wallets.NewWallet(wallets.KeyTypeP256).GetAddress())
+		})
+
+		It("should send change at or above the dust threshold back to the wallet", func() {
+			subsidy := chaincfg.ActiveParams().Subsidy(0)
+
+			tx := NewBatchTransaction(wallet, []Recipient{{to, subsidy - 3}}, UTXOSet, &CoinControl{DustThreshold: 3})
+			Expect(tx.VOut).To(HaveLen(2))
+			Expect(tx.VOut[1].Value).To(Equal(3))
+			Expect(tx.VOut[1].PubKeyHash).To(Equal(wallets.HashPubKey(wallet.PublicKey)))
+		})
+
+		It("should leave change below the dust threshold to the miner", func() {
+			subsidy := chaincfg.ActiveParams().Subsidy(0)
+
+			tx := NewBatchTransaction(wallet, []Recipient{{to, subsidy - 2}}, UTXOSet, &CoinControl{DustThreshold: 3})
+			Expect(tx.VOut).To(HaveLen(1))
+			Expect(tx.VOut[0].Value).To(Equal(subsidy - 2))
+		})
+
+		It("should not add a change output when the inputs pay the recipients exactly", func() {
+			subsidy := chaincfg.ActiveParams().Subsidy(0)
+
+			tx := NewBatchTransaction(wallet, []Recipient{{to, subsidy}}, UTXOSet, nil)
+			Expect(tx.VOut).To(HaveLen(1))
+			Expect(UTXOSet.Blockchain.VerifyTransaction(tx)).To(BeTrue())
+		})
+	})
+})
@@ -0,0 +1,164 @@
+package blockchainstruct
+
+import (
+	"bytes"
+	"encoding/gob"
+	"fmt"
+	"log"
+
+	"github.com/cyprus09/blockchain/wallets"
+)
+
+// PartialTransaction is a transaction that still has to be signed, together with the
+// outputs its inputs spend. It carries everything a wallet needs to sign the transaction
+// without access to the chain
+type PartialTransaction struct {
+	Tx Transaction
+	// Inputs holds the output spent by each input of Tx, in the same order
+	Inputs []UTXO
+	// PrevTxs holds the whole transaction each input spends from, in the same order, so
+	// the signer can check Inputs against the IDs the inputs commit to
+	PrevTxs []Transaction
+}
+
+// NewPartialTransaction builds an unsigned transaction paying every recipient from the
+// outputs of address. Only the address is needed, not its keys
+func NewPartialTransaction(address string, recipients []Recipient, UTXOSet *UTXOSet, coinControl *CoinControl) *PartialTransaction {
+	tx, selected := newUnsignedTransaction(address, recipients, UTXOSet, coinControl)
+
+	prevTxs := make([]Transaction, len(selected))
+	for i, utxo := range selected {
+		prevTx, err := UTXOSet.Blockchain.FindTransaction(utxo.TxID)
+		if err != nil {
+			log.Panic(err)
+		}
+		prevTxs[i] = prevTx
+	}
+
+	return &PartialTransaction{*tx, selected, prevTxs}
+}
+
+// CheckInputs checks every spent output of Inputs against the previous transaction it
+// comes from, and that transaction against the ID its input commits to. Values in Inputs
+// are not covered by the signatures, so a signer must not trust them otherwise
+func (ptx *PartialTransaction) CheckInputs() error {
+	if len(ptx.Inputs) != len(ptx.Tx.VIn) {
+		return fmt.Errorf("transaction has %d inputs but %d spent outputs", len(ptx.Tx.VIn), len(ptx.Inputs))
+	}
+	if len(ptx.PrevTxs) != len(ptx.Tx.VIn) {
+		return fmt.Errorf("transaction has %d inputs but %d previous transactions", len(ptx.Tx.VIn), len(ptx.PrevTxs))
+	}
+
+	for i, utxo := range ptx.Inputs {
+		VIn := ptx.Tx.VIn[i]
+		if !bytes.Equal(VIn.TxId, utxo.TxID) || VIn.VOut != utxo.VOut {
+			return fmt.Errorf("input %d spends %x:%d but its output is %x:%d", i, VIn.TxId, VIn.VOut, utxo.TxID, utxo.VOut)
+		}
+
+		prevTx := ptx.PrevTxs[i]
+		if id := prevTx.unsignedHash(); !bytes.Equal(id, VIn.TxId) {
+			return fmt.Errorf("previous transaction of input %d is %x, not %x", i, id, VIn.TxId)
+		}
+		if VIn.VOut < 0 || VIn.VOut >= len(prevTx.VOut) {
+			return fmt.Errorf("input %d spends output %d of a transaction with %d outputs", i, VIn.VOut, len(prevTx.VOut))
+		}
+
+		prevOut := prevTx.VOut[VIn.VOut]
+		if prevOut.Value != utxo.Value || !bytes.Equal(prevOut.PubKeyHash, utxo.PubKeyHash) {
+			return fmt.Errorf("input %d claims %d to %s but spends %d to %s", i, utxo.Value, wallets.EncodeAddress(utxo.PubKeyHash), prevOut.Value, wallets.EncodeAddress(prevOut.PubKeyHash))
+		}
+	}
+
+	return nil
+}
+
+// Sign checks the inputs, then fills in the public keys and signs every input with the keys of ws. It fails
+// without changing the transaction unless ws holds the key of every input
+func (ptx *PartialTransaction) Sign(ws *wallets.Wallets) error {
+	if err := ptx.CheckInputs(); err != nil {
+		return err
+	}
+
+	pubKeyHashes := ws.PubKeyHashes()
+	signers := make([]wallets.Wallet, len(ptx.Inputs))
+
+	for i, utxo := range ptx.Inputs {
+		address, ok := pubKeyHashes[string(utxo.PubKeyHash)]
+		if !ok {
+			return fmt.Errorf("input %d is locked to %s, which is not in the wallet", i, wallets.EncodeAddress(utxo.PubKeyHash))
+		}
+
+		wallet, err := ws.GetWallet(address)
+		if err != nil {
+			return err
+		}
+		signers[i] = wallet
+	}
+
+	for i := range ptx.Tx.VIn {
+		ptx.Tx.VIn[i].PubKey = signers[i].PublicKey
+		ptx.Tx.VIn[i].Signature = nil
+	}
+	ptx.Tx.ID = ptx.Tx.HashValue()
+
+	for i, utxo := range ptx.Inputs {
+		ptx.Tx.SignInput(i, signers[i].PrivateKey, TxOutput{utxo.Value, utxo.PubKeyHash})
+	}
+
+	return nil
+}
+
+// IsSigned reports whether every input carries a valid signature for the output it spends
+func (ptx *PartialTransaction) IsSigned() bool {
+	if len(ptx.Tx.VIn) == 0 || len(ptx.Inputs) != len(ptx.Tx.VIn) || ptx.Tx.ID == nil {
+		return false
+	}
+
+	for i, utxo := range ptx.Inputs {
+		if !ptx.Tx.VerifyInput(i, TxOutput{utxo.Value, utxo.PubKeyHash}) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// Fee returns what the spent outputs hold beyond the outputs of the transaction
+func (ptx *PartialTransaction) Fee() int {
+	fee := 0
+
+	for _, utxo := range ptx.Inputs {
+		fee += utxo.Value
+	}
+	for _, out := range ptx.Tx.VOut {
+		fee -= out.Value
+	}
+
+	return fee
+}
+
+// Serialize returns a serialized PartialTransaction
+func (ptx *PartialTransaction) Serialize() []byte {
+	var encoded bytes.Buffer
+
+	enc := gob.NewEncoder(&encoded)
+	err := enc.Encode(ptx)
+	if err != nil {
+		log.Panic(err)
+	}
+
+	return encoded.Bytes()
+}
+
+// DeserializePartialTransaction deserializes a partial transaction
+func DeserializePartialTransaction(data []byte) (*PartialTransaction, error) {
+	var ptx PartialTransaction
+
+	decoder := gob.NewDecoder(bytes.NewReader(data))
+	err := decoder.Decode(&ptx)
+	if err != nil {
+		return nil, err
+	}
+
+	return &ptx, nil
+}
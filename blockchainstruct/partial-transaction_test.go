@@ -0,0 +1,106 @@
+package blockchainstruct
+
+import (
+	"github.com/cyprus09/blockchain/wallets"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("PartialTransaction", func() {
+	var wallet *wallets.Wallet
+	var ws *wallets.Wallets
+	var UTXOSet *UTXOSet
+	var ptx *PartialTransaction
+
+	BeforeEach(func() {
+		wallet = wallets.NewWallet(wallets.KeyTypeSecp256k1)
+		UTXOSet = newTestChain(wallet)
+
+		ws, _ = wallets.NewWallets("test")
+		_, err := ws.ImportKey(wallet.PrivateKey, wallet.KeyType)
+		Expect(err).NotTo(HaveOccurred())
+
+		to := string(wallets.NewWallet(wallets.KeyTypeP256).GetAddress())
+		ptx = NewPartialTransaction(string(wallet.GetAddress()), []Recipient{{to, 7}}, UTXOSet, nil)
+	})
+
+	// expectRefused checks that Sign fails with an error containing reason and leaves the
+	// transaction unsigned
+	expectRefused := func(reason string) {
+		Expect(ptx.CheckInputs()).To(MatchError(ContainSubstring(reason)))
+		Expect(ptx.Sign(ws)).To(MatchError(ContainSubstring(reason)))
+		Expect(ptx.IsSigned()).To(BeFalse())
+		for _, VIn := range ptx.Tx.VIn {
+			Expect(VIn.PubKey).To(BeNil())
+			Expect(VIn.Signature).To(BeNil())
+		}
+	}
+
+	It("should carry the spent outputs and their transactions", func() {
+		Expect(ptx.Inputs).To(HaveLen(1))
+		Expect(ptx.PrevTxs).To(HaveLen(1))
+		Expect(ptx.PrevTxs[0].IsCoinbase()).To(BeTrue())
+		Expect(ptx.Fee()).To(Equal(0))
+		Expect(ptx.CheckInputs()).To(Succeed())
+	})
+
+	It("should sign offline from its serialized form", func() {
+		offline, err := DeserializePartialTransaction(ptx.Serialize())
+		Expect(err).NotTo(HaveOccurred())
+
+		Expect(offline.Sign(ws)).To(Succeed())
+		Expect(offline.IsSigned()).To(BeTrue())
+		Expect(UTXOSet.Blockchain.VerifyTransaction(&offline.Tx)).To(BeTrue())
+	})
+
+	It("should refuse a spent output whose value was changed", func() {
+		ptx.Inputs[0].Value += 5
+
+		expectRefused("claims 15")
+	})
+
+	It("should refuse a spent output locked to another key", func() {
+		ptx.Inputs[0].PubKeyHash = wallets.HashPubKey(wallets.NewWallet(wallets.KeyTypeP256).PublicKey)
+
+		expectRefused("input 0 claims")
+	})
+
+	It("should refuse a previous transaction changed to match a changed value", func() {
+		ptx.Inputs[0].Value += 5
+		ptx.PrevTxs[0].VOut[ptx.Inputs[0].VOut].Value += 5
+
+		expectRefused("previous transaction of input 0")
+	})
+
+	It("should refuse a previous transaction other than the one the input spends", func() {
+		ptx.PrevTxs[0] = *NewCoinbaseTx(string(wallet.GetAddress()), "", 1)
+
+		expectRefused("previous transaction of input 0")
+	})
+
+	It("should refuse a missing previous transaction", func() {
+		ptx.PrevTxs = nil
+
+		expectRefused("0 previous transactions")
+	})
+
+	It("should refuse a spent output other than the one the input spends", func() {
+		ptx.Inputs[0].VOut++
+
+		expectRefused("input 0 spends")
+	})
+
+	It("should refuse an input spending an output its transaction does not have", func() {
+		ptx.Tx.VIn[0].VOut = 5
+		ptx.Inputs[0].VOut = 5
+
+		expectRefused("input 0 spends output 5")
+	})
+
+	It("should refuse to sign without the key of every input", func() {
+		other, _ := wallets.NewWallets("other")
+
+		Expect(ptx.Sign(other)).To(MatchError(ContainSubstring("not in the wallet")))
+		Expect(ptx.IsSigned()).To(BeFalse())
+	})
+})
@@ -3,18 +3,26 @@ package blockchainstruct
 import (
 	"bytes"
 	"crypto/sha256"
-	"fmt"
-	"github.com/cyprus09/blockchain/utils"
+	"encoding/hex"
 	"math"
 	"math/big"
+	"sync/atomic"
+	"time"
+
+	"github.com/cyprus09/blockchain/chaincfg"
+	"github.com/cyprus09/blockchain/logging"
+	"github.com/cyprus09/blockchain/utils"
 )
 
 var (
 	maxNonce = math.MaxInt64
+	// powHashes and powNanos add up the hashes tried and the time spent by every Run
+	powHashes uint64
+	powNanos  uint64
+	// miningCancelled makes every Run give up once set
+	miningCancelled atomic.Bool
 )
 
-const targetBits = 20
-
 // ProofOfWork represents proof-of-work for a blockchain
 type ProofOfWork struct {
 	block  *Block
@@ -24,7 +32,7 @@ type ProofOfWork struct {
 // NewProofOfWork builds and returns the proof of work for the block
 func NewProofOfWork(b *Block) *ProofOfWork {
 	target := big.NewInt(1)
-	target.Lsh(target, uint(256-targetBits))
+	target.Lsh(target, uint(256-chaincfg.ActiveParams().TargetBits))
 
 	pow := &ProofOfWork{b, target}
 	return pow
@@ -37,7 +45,7 @@ func (pow *ProofOfWork) prepareData(nonce int) []byte {
 			pow.block.PrevBlockHash,
 			pow.block.HashTransactions(),
 			utils.IntToBytes(pow.block.Timestamp),
-			utils.IntToBytes(int64(targetBits)),
+			utils.IntToBytes(int64(chaincfg.ActiveParams().TargetBits)),
 			utils.IntToBytes(int64(nonce)),
 		},
 		[]byte{},
@@ -45,18 +53,24 @@ func (pow *ProofOfWork) prepareData(nonce int) []byte {
 	return data
 }
 
-// Run performs the proof of work from the data generated from prepareData
+// Run performs the proof of work from the data generated from prepareData. It returns
+// a nil hash when mining was cancelled
 func (pow *ProofOfWork) Run() (int, []byte) {
 	var hashInt big.Int
 	var hashValue [32]byte
 	nonce := 0
+	start := time.Now()
 
-	fmt.Printf("Mining new block now...")
+	logging.Miner.Debug("mining block", "height", pow.block.Height, "txs", len(pow.block.Transactions))
 	for nonce < maxNonce {
+		if miningCancelled.Load() {
+			logging.Miner.Info("mining cancelled", "height", pow.block.Height)
+			return nonce, nil
+		}
+
 		data := pow.prepareData(nonce)
 
 		hashValue = sha256.Sum256(data)
-		fmt.Printf("\r%x", hashValue)
 		hashInt.SetBytes(hashValue[:])
 
 		if hashInt.Cmp(pow.target) == -1 {
@@ -66,10 +80,27 @@ func (pow *ProofOfWork) Run() (int, []byte) {
 		}
 	}
 
-	fmt.Print("\n\n")
+	elapsed := time.Since(start)
+	atomic.AddUint64(&powHashes, uint64(nonce)+1)
+	atomic.AddUint64(&powNanos, uint64(elapsed))
+
+	logging.Miner.Debug("found proof of work", "hash", hex.EncodeToString(hashValue[:]), "nonce", nonce,
+		"elapsed", elapsed, "hashrate", float64(nonce+1)/elapsed.Seconds())
 	return nonce, hashValue[:]
 }
 
+// CancelMining stops the proof of work running now and every later one, so a node
+// shutting down does not wait for a block to be found
+func CancelMining() {
+	miningCancelled.Store(true)
+}
+
+// PoWStats returns how many hashes proof of work has tried so far and how long it took,
+// the hashrate being their ratio
+func PoWStats() (uint64, time.Duration) {
+	return atomic.LoadUint64(&powHashes), time.Duration(atomic.LoadUint64(&powNanos))
+}
+
 // Validate validates the block's PoW which takes way lesser time than the actual process of generating the hash
 func (pow *ProofOfWork) Validate() bool {
 	var hashInt big.Int
@@ -15,8 +15,7 @@ type TxInput struct {
 
 // UsesKey checks whether the address initiated the transaction
 func (in *TxInput) UsesKey(pubKeyHash []byte) bool {
-	wallet := wallets.NewWallet()
-	lockingHash := wallet.HashPubKey(in.PubKey)
+	lockingHash := wallets.HashPubKey(in.PubKey)
 
 	return bytes.Equal(lockingHash, pubKeyHash)
 }
@@ -2,10 +2,9 @@ package blockchainstruct
 
 import (
 	"bytes"
-	"encoding/gob"
 	"log"
 
-	"github.com/cyprus09/blockchain/utils"
+	"github.com/cyprus09/blockchain/wallets"
 )
 
 // TxOutput represents a transaction output
@@ -16,8 +15,10 @@ type TxOutput struct {
 
 // Lock signs the output
 func (out *TxOutput) Lock(address []byte) {
-	pubKeyHash := utils.Base58Decode(address)
-	pubKeyHash = pubKeyHash[1 : len(pubKeyHash)-4]
+	pubKeyHash, err := wallets.DecodeAddress(string(address))
+	if err != nil {
+		log.Panic(err)
+	}
 	out.PubKeyHash = pubKeyHash
 }
 
@@ -33,34 +34,3 @@ func NewTxOutput(value int, address string) *TxOutput {
 
 	return txo
 }
-
-// TxOutputs collects TxOutput
-type TxOutputs struct {
-	Outputs []TxOutput
-}
-
-// SerializeOutputs serializes TxOutputs
-func (outs *TxOutputs) SerializeOutputs() []byte {
-	var buff bytes.Buffer
-
-	enc := gob.NewEncoder(&buff)
-	err := enc.Encode(outs)
-	if err != nil {
-		log.Panic(err)
-	}
-
-	return buff.Bytes()
-}
-
-// DeserializeOutputs deserializes TxOutputs
-func DeserializeOutputs(data []byte) TxOutputs {
-	var outputs TxOutputs
-
-	dec := gob.NewDecoder(bytes.NewReader(data))
-	err := dec.Decode(&outputs)
-	if err != nil {
-		log.Panic(err)
-	}
-
-	return outputs
-}
@@ -3,20 +3,17 @@ package blockchainstruct
 import (
 	"bytes"
 	"crypto/ecdsa"
-	"crypto/elliptic"
 	"crypto/rand"
 	"crypto/sha256"
 	"encoding/gob" //gob is the library used for encoding data (serialisation which can be done through protobufs as well for data streams in binary format
 	"encoding/hex"
 	"fmt"
+	"github.com/cyprus09/blockchain/chaincfg"
 	"github.com/cyprus09/blockchain/wallets"
 	"log"
-	"math/big"
 	"strings"
 )
 
-const subsidy = 10
-
 // Transaction struct represents a Bitcoin transaction
 type Transaction struct {
 	ID   []byte
@@ -67,7 +64,20 @@ func (tx *Transaction) HashValue() []byte {
 	return hashValue[:]
 }
 
-// Sign signs each input of a Transaction
+// unsignedHash returns the hash of tx without its signatures, which is its ID as the
+// ID is set before the inputs are signed
+func (tx *Transaction) unsignedHash() []byte {
+	txCopy := *tx
+	txCopy.VIn = make([]TxInput, len(tx.VIn))
+	for i, VIn := range tx.VIn {
+		VIn.Signature = nil
+		txCopy.VIn[i] = VIn
+	}
+
+	return txCopy.HashValue()
+}
+
+// Sign signs each input of a Transaction with a deterministic, low-S compact signature
 func (tx *Transaction) Sign(privKey ecdsa.PrivateKey, prevTXs map[string]Transaction) {
 	if tx.IsCoinbase() {
 		return
@@ -79,24 +89,28 @@ func (tx *Transaction) Sign(privKey ecdsa.PrivateKey, prevTXs map[string]Transac
 		}
 	}
 
-	txCopy := tx.TrimmedCopy()
-
-	for inID, VIn := range txCopy.VIn {
+	for inID, VIn := range tx.VIn {
 		prevTX := prevTXs[hex.EncodeToString(VIn.TxId)]
-		txCopy.VIn[inID].Signature = nil
-		txCopy.VIn[inID].PubKey = prevTX.VOut[VIn.VOut].PubKeyHash
+		tx.SignInput(inID, privKey, prevTX.VOut[VIn.VOut])
+	}
+}
 
-		dataToSign := fmt.Sprintf("%x\n", txCopy)
+// SignInput signs the input at inID, which spends prevOut
+func (tx *Transaction) SignInput(inID int, privKey ecdsa.PrivateKey, prevOut TxOutput) {
+	dataToSign := tx.signatureHash(inID, prevOut)
 
-		r, s, err := ecdsa.Sign(rand.Reader, &privKey, []byte(dataToSign))
-		if err != nil {
-			log.Panic(err)
-		}
+	tx.VIn[inID].Signature = wallets.Sign(&privKey, dataToSign)
+}
 
-		signature := append(r.Bytes(), s.Bytes()...)
-		tx.VIn[inID].Signature = signature
-		txCopy.VIn[inID].PubKey = nil
-	}
+// signatureHash returns the hash the input at inID signs: the trimmed transaction
+// with the pubkey hash of prevOut in place of that input's public key
+func (tx *Transaction) signatureHash(inID int, prevOut TxOutput) []byte {
+	txCopy := tx.TrimmedCopy()
+	txCopy.VIn[inID].PubKey = prevOut.PubKeyHash
+
+	hash := sha256.Sum256([]byte(fmt.Sprintf("%x\n", txCopy)))
+
+	return hash[:]
 }
 
 // String returns a human readable representation of a transaction
@@ -142,7 +156,7 @@ func (tx *Transaction) TrimmedCopy() Transaction {
 	return txCopy
 }
 
-// Verify verifies signatures of Transaction inputs
+// Verify verifies signatures of Transaction inputs, rejecting any that are not canonical
 func (tx *Transaction) Verify(prevTXs map[string]Transaction) bool {
 	if tx.IsCoinbase() {
 		return true
@@ -154,44 +168,32 @@ func (tx *Transaction) Verify(prevTXs map[string]Transaction) bool {
 		}
 	}
 
-	txCopy := tx.TrimmedCopy()
-	curve := elliptic.P256()
-
 	for inID, VIn := range tx.VIn {
 		prevTX := prevTXs[hex.EncodeToString(VIn.TxId)]
-		txCopy.VIn[inID].Signature = nil
-		txCopy.VIn[inID].PubKey = prevTX.VOut[VIn.VOut].PubKeyHash
-
-		r := big.Int{}
-		s := big.Int{}
-		sigLen := len(VIn.Signature)
-		r.SetBytes(VIn.Signature[:(sigLen / 2)])
-		s.SetBytes(VIn.Signature[(sigLen / 2):])
-
-		x := big.Int{}
-		y := big.Int{}
-		keyLen := len(VIn.PubKey)
-		x.SetBytes(VIn.PubKey[:(keyLen / 2)])
-		y.SetBytes(VIn.PubKey[(keyLen / 2):])
-
-		dataToVerify := fmt.Sprintf("%x\n", txCopy)
-
-		rawPubKey := ecdsa.PublicKey{
-			Curve: curve,
-			X:     &x,
-			Y:     &y,
-		}
-
-		if !ecdsa.Verify(&rawPubKey, []byte(dataToVerify), &r, &s) {
+		if !tx.VerifyInput(inID, prevTX.VOut[VIn.VOut]) {
 			return false
 		}
-		txCopy.VIn[inID].PubKey = nil
 	}
 	return true
 }
 
-// NewCoinbaseTx creates a new coinbase transaction
-func NewCoinbaseTx(to, data string) *Transaction {
+// VerifyInput verifies the signature of the input at inID, which spends prevOut
+func (tx *Transaction) VerifyInput(inID int, prevOut TxOutput) bool {
+	VIn := tx.VIn[inID]
+
+	pubKey, err := wallets.DecodePubKey(VIn.PubKey)
+	if err != nil {
+		return false
+	}
+
+	dataToVerify := tx.signatureHash(inID, prevOut)
+
+	return wallets.Verify(pubKey, dataToVerify, VIn.Signature)
+}
+
+// NewCoinbaseTx creates the coinbase transaction of the block at height, paying the
+// subsidy of the active network to to
+func NewCoinbaseTx(to, data string, height int) *Transaction {
 	if data == "" {
 		randData := make([]byte, 20)
 		_, err := rand.Read(randData)
@@ -202,7 +204,7 @@ func NewCoinbaseTx(to, data string) *Transaction {
 	}
 
 	txIn := TxInput{[]byte{}, -1, nil, []byte(data)}
-	txOut := NewTxOutput(subsidy, to)
+	txOut := NewTxOutput(chaincfg.ActiveParams().Subsidy(height), to)
 
 	tx := Transaction{nil, []TxInput{txIn}, []TxOutput{*txOut}}
 	tx.ID = tx.HashValue()
@@ -210,42 +212,75 @@ func NewCoinbaseTx(to, data string) *Transaction {
 	return &tx
 }
 
-// NewUTXOTTransaction creates a new transaction
-func NewUTXOTTransaction(wallet *wallets.Wallet, to string, amount int, UTXOSet *UTXOSet) *Transaction {
+// Recipient is a single payment of a transaction
+type Recipient struct {
+	Address string `json:"address"`
+	Amount  int    `json:"amount"`
+}
+
+// NewUTXOTTransaction creates a new transaction, picking its inputs as coinControl says.
+// A nil coinControl selects the largest outputs first and always returns change
+func NewUTXOTTransaction(wallet *wallets.Wallet, to string, amount int, UTXOSet *UTXOSet, coinControl *CoinControl) *Transaction {
+	return NewBatchTransaction(wallet, []Recipient{{to, amount}}, UTXOSet, coinControl)
+}
+
+// NewBatchTransaction creates a single transaction paying every recipient, with one change output back to the wallet
+func NewBatchTransaction(wallet *wallets.Wallet, recipients []Recipient, UTXOSet *UTXOSet, coinControl *CoinControl) *Transaction {
+	tx, _ := newUnsignedTransaction(string(wallet.GetAddress()), recipients, UTXOSet, coinControl)
+
+	for i := range tx.VIn {
+		tx.VIn[i].PubKey = wallet.PublicKey
+	}
+	tx.ID = tx.HashValue()
+	UTXOSet.Blockchain.SignTransaction(tx, wallet.PrivateKey)
+
+	return tx
+}
+
+// newUnsignedTransaction builds a transaction paying every recipient from the outputs of
+// address, with change back to address. It returns the outputs it spends, one per input.
+// The inputs carry neither public key nor signature and the transaction has no ID yet
+func newUnsignedTransaction(address string, recipients []Recipient, UTXOSet *UTXOSet, coinControl *CoinControl) (*Transaction, []UTXO) {
 	var inputs []TxInput
 	var outputs []TxOutput
 
-	pubKeyHash := wallet.HashPubKey(wallet.PublicKey)
-	acc, validOutputs := UTXOSet.FindSpendableOutputs(pubKeyHash, amount)
-
-	if acc < amount {
-		log.Panic("ERROR: Not enough funds in your account")
+	if coinControl == nil {
+		coinControl = &CoinControl{}
 	}
 
-	// Build a list of inputs
-	for txId, outs := range validOutputs {
-		txId, err := hex.DecodeString(txId)
-		if err != nil {
-			log.Panic(err)
+	amount := 0
+	for _, recipient := range recipients {
+		if recipient.Amount <= 0 {
+			log.Panicf("ERROR: Amount for %s must be positive", recipient.Address)
 		}
+		amount += recipient.Amount
+	}
 
-		for _, out := range outs {
-			input := TxInput{txId, out, nil, wallet.PublicKey}
-			inputs = append(inputs, input)
-		}
+	pubKeyHash, err := wallets.DecodeAddress(address)
+	if err != nil {
+		log.Panic(err)
+	}
+	selected, err := UTXOSet.SelectCoins(pubKeyHash, amount, coinControl)
+	if err != nil {
+		log.Panicf("ERROR: %v", err)
 	}
 
-	// Build a list of outputs
-	from := string(wallet.GetAddress())
-	outputs = append(outputs, *NewTxOutput(amount, to))
-	if acc > amount {
-		// generate change
-		outputs = append(outputs, *NewTxOutput(acc-amount, from))
+	// Build a list of inputs
+	acc := 0
+	for _, utxo := range selected {
+		input := TxInput{utxo.TxID, utxo.VOut, nil, nil}
+		inputs = append(inputs, input)
+		acc += utxo.Value
 	}
 
-	tx := Transaction{nil, inputs, outputs}
-	tx.ID = tx.HashValue()
-	UTXOSet.Blockchain.SignTransaction(&tx, wallet.PrivateKey)
+	// Build a list of outputs
+	for _, recipient := range recipients {
+		outputs = append(outputs, *NewTxOutput(recipient.Amount, recipient.Address))
+	}
+	if change := acc - amount; change > 0 && change >= coinControl.DustThreshold {
+		// generate change, dust below the threshold is left as fee
+		outputs = append(outputs, *NewTxOutput(change, address))
+	}
 
-	return &tx
+	return &Transaction{nil, inputs, outputs}, selected
 }
@@ -0,0 +1,66 @@
+package blockchainstruct
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/gob"
+	"log"
+)
+
+// UTXOEntry is a single unspent output as stored in the chainstate bucket
+type UTXOEntry struct {
+	Value      int
+	PubKeyHash []byte
+	Height     int
+	Coinbase   bool
+}
+
+// UTXO pairs an unspent output with the outpoint it can be spent from
+type UTXO struct {
+	TxID []byte
+	VOut int
+	UTXOEntry
+}
+
+// outpointKey builds the chainstate key of an output, the txid followed by the big endian output index
+func outpointKey(txID []byte, vout int) []byte {
+	key := make([]byte, len(txID)+4)
+	copy(key, txID)
+	binary.BigEndian.PutUint32(key[len(txID):], uint32(vout))
+
+	return key
+}
+
+// splitOutpointKey returns the txid and output index stored in a chainstate key
+func splitOutpointKey(key []byte) ([]byte, int) {
+	split := len(key) - 4
+	txID := append([]byte{}, key[:split]...)
+
+	return txID, int(binary.BigEndian.Uint32(key[split:]))
+}
+
+// SerializeEntry serializes a UTXOEntry
+func (e *UTXOEntry) SerializeEntry() []byte {
+	var buff bytes.Buffer
+
+	enc := gob.NewEncoder(&buff)
+	err := enc.Encode(e)
+	if err != nil {
+		log.Panic(err)
+	}
+
+	return buff.Bytes()
+}
+
+// DeserializeEntry deserializes a UTXOEntry
+func DeserializeEntry(data []byte) UTXOEntry {
+	var entry UTXOEntry
+
+	dec := gob.NewDecoder(bytes.NewReader(data))
+	err := dec.Decode(&entry)
+	if err != nil {
+		log.Panic(err)
+	}
+
+	return entry
+}
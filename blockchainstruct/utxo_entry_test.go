@@ -0,0 +1,135 @@
+package blockchainstruct
+
+import (
+	"bytes"
+	"encoding/hex"
+
+	"github.com/boltdb/bolt"
+	"github.com/cyprus09/blockchain/wallets"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+// outpointKeyVectors are outpoints and their chainstate keys in hex
+var outpointKeyVectors = []struct {
+	txID string
+	vout int
+	key  string
+}{
+	{"00", 0, "0000000000"},
+	{"abcdef", 1, "abcdef00000001"},
+	{"abcdef", 256, "abcdef00000100"},
+	{"9dd7e86e0c1f4a35b3fe2e85e9c3f2b1a6e4b7d8c9f0a1b2c3d4e5f60718293a", 65537, "9dd7e86e0c1f4a35b3fe2e85e9c3f2b1a6e4b7d8c9f0a1b2c3d4e5f60718293a00010001"},
+}
+
+var _ = Describe("Chainstate", func() {
+	Describe("outpointKey", func() {
+		It("should append the big endian output index to the txid", func() {
+			for _, vector := range outpointKeyVectors {
+				txID, err := hex.DecodeString(vector.txID)
+				Expect(err).NotTo(HaveOccurred())
+
+				key := outpointKey(txID, vector.vout)
+				Expect(hex.EncodeToString(key)).To(Equal(vector.key))
+
+				splitTxID, vout := splitOutpointKey(key)
+				Expect(splitTxID).To(Equal(txID))
+				Expect(vout).To(Equal(vector.vout))
+			}
+		})
+
+		It("should sort the outputs of a transaction by index", func() {
+			txID := []byte{0xab, 0xcd}
+
+			for vout := 0; vout < 1024; vout++ {
+				Expect(bytes.Compare(outpointKey(txID, vout), outpointKey(txID, vout+1))).To(Equal(-1))
+			}
+		})
+
+		It("should leave the txid of the key alone when split", func() {
+			key := outpointKey([]byte{0x01, 0x02}, 3)
+
+			txID, _ := splitOutpointKey(key)
+			txID[0] = 0xff
+			Expect(key[0]).To(Equal(byte(0x01)))
+		})
+	})
+
+	Describe("entries", func() {
+		var alice, bob *wallets.Wallet
+		var UTXOSet *UTXOSet
+		var tx *Transaction
+
+		BeforeEach(func() {
+			alice = wallets.NewWallet(wallets.KeyTypeP256)
+			bob = wallets.NewWallet(wallets.KeyTypeP256)
+			UTXOSet = newTestChain(alice)
+
+			tx = NewUTXOTTransaction(alice, string(bob.GetAddress()), 4, UTXOSet, nil)
+			mineTestBlock(UTXOSet, bob, tx)
+		})
+
+		It("should store every output under its own outpoint", func() {
+			Expect(tx.VOut).To(HaveLen(2))
+
+			for vout, out := range tx.VOut {
+				utxo, err := UTXOSet.GetUTXO(Outpoint{tx.ID, vout})
+				Expect(err).NotTo(HaveOccurred())
+				Expect(utxo.Value).To(Equal(out.Value))
+				Expect(utxo.PubKeyHash).To(Equal(out.PubKeyHash))
+				Expect(utxo.Height).To(Equal(1))
+				Expect(utxo.Coinbase).To(BeFalse())
+			}
+		})
+
+		It("should keep the index of the outputs left once one is spent", func() {
+			change := Outpoint{tx.ID, 1}
+			Expect(UTXOSet.GetUTXO(change)).To(HaveField("PubKeyHash", wallets.HashPubKey(alice.PublicKey)))
+
+			spend := NewBatchTransaction(bob, []Recipient{{string(alice.GetAddress()), 4}}, UTXOSet, &CoinControl{Outpoints: []Outpoint{{tx.ID, 0}}})
+			mineTestBlock(UTXOSet, bob, spend)
+
+			_, err := UTXOSet.GetUTXO(Outpoint{tx.ID, 0})
+			Expect(err).To(HaveOccurred())
+			utxo, err := UTXOSet.GetUTXO(change)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(utxo.VOut).To(Equal(1))
+			Expect(utxo.Value).To(Equal(6))
+		})
+
+		It("should rebuild the same entries as Update wrote", func() {
+			var before, after [][2][]byte
+			read := func(into *[][2][]byte) {
+				err := UTXOSet.Blockchain.DB.View(func(tx *bolt.Tx) error {
+					return tx.Bucket([]byte(utxoBucket)).ForEach(func(key, data []byte) error {
+						*into = append(*into, [2][]byte{append([]byte{}, key...), append([]byte{}, data...)})
+						return nil
+					})
+				})
+				Expect(err).NotTo(HaveOccurred())
+			}
+
+			read(&before)
+			UTXOSet.Reindex()
+			read(&after)
+
+			Expect(after).To(Equal(before))
+			for _, entry := range after {
+				txID, vout := splitOutpointKey(entry[0])
+				Expect(outpointKey(txID, vout)).To(Equal(entry[0]))
+			}
+		})
+
+		It("should refuse to open a chainstate written by a newer version", func() {
+			err := UTXOSet.Blockchain.DB.Update(func(tx *bolt.Tx) error {
+				return tx.Bucket([]byte(blocksBucket)).Put([]byte(chainstateVersionKey), []byte{chainstateVersion + 1})
+			})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(UTXOSet.Blockchain.DB.Close()).To(Succeed())
+
+			bc, err := NewBlockchain("test")
+			Expect(err).To(MatchError(ContainSubstring("layout version %d", chainstateVersion+1)))
+			Expect(bc).To(BeNil())
+		})
+	})
+})
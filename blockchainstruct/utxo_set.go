@@ -1,19 +1,66 @@
 package blockchainstruct
 
 import (
+	"bytes"
 	"encoding/hex"
+	"fmt"
 	"log"
 
 	"github.com/boltdb/bolt"
+	"github.com/cyprus09/blockchain/logging"
 )
 
-const utxoBucket = "chainstate"
+const (
+	utxoBucket      = "chainstate"
+	addrIndexBucket = "addrindex"
+	// chainstateVersionKey holds the chainstate layout in the blocks bucket. Chains without
+	// it store the TxOutputs of each txid and get their chainstate rebuilt on open
+	chainstateVersionKey = "chainstate_version"
+	chainstateVersion    = 1
+)
 
 // UTXOSet represents UTXO set
 type UTXOSet struct {
 	Blockchain *Blockchain
 }
 
+// addrIndexKey builds the address index key, the pubkey hash followed by the outpoint key
+func addrIndexKey(pubKeyHash, outpoint []byte) []byte {
+	key := append([]byte{}, pubKeyHash...)
+	return append(key, outpoint...)
+}
+
+// forEachOutput calls fn for every unspent output locked with pubKeyHash.
+// It walks the address index when one exists and falls back to a full scan of the chainstate otherwise
+func forEachOutput(tx *bolt.Tx, pubKeyHash []byte, fn func(utxo UTXO)) {
+	b := tx.Bucket([]byte(utxoBucket))
+	visit := func(key, data []byte) {
+		entry := DeserializeEntry(data)
+
+		if bytes.Equal(entry.PubKeyHash, pubKeyHash) {
+			txID, vout := splitOutpointKey(key)
+			fn(UTXO{txID, vout, entry})
+		}
+	}
+
+	idx := tx.Bucket([]byte(addrIndexBucket))
+	if idx == nil {
+		c := b.Cursor()
+		for k, v := c.First(); k != nil; k, v = c.Next() {
+			visit(k, v)
+		}
+		return
+	}
+
+	c := idx.Cursor()
+	for k, _ := c.Seek(pubKeyHash); k != nil && bytes.HasPrefix(k, pubKeyHash); k, _ = c.Next() {
+		outpoint := k[len(pubKeyHash):]
+		if data := b.Get(outpoint); data != nil {
+			visit(outpoint, data)
+		}
+	}
+}
+
 // FindSpendableOutputs finds and returns unspent outputs to reference in inputs
 func (u *UTXOSet) FindSpendableOutputs(pubKeyHash []byte, amount int) (int, map[string][]int) {
 	unspentOutputs := make(map[string][]int)
@@ -21,20 +68,13 @@ func (u *UTXOSet) FindSpendableOutputs(pubKeyHash []byte, amount int) (int, map[
 	db := u.Blockchain.DB
 
 	err := db.View(func(tx *bolt.Tx) error {
-		b := tx.Bucket([]byte(utxoBucket))
-		c := b.Cursor()
-
-		for k, v := c.First(); k != nil; k, v = c.Next() {
-			txId := hex.EncodeToString(k)
-			outs := DeserializeOutputs(v)
-
-			for outIdx, out := range outs.Outputs {
-				if out.IsLockedWithKey(pubKeyHash) && accumulated < amount {
-					accumulated += out.Value
-					unspentOutputs[txId] = append(unspentOutputs[txId], outIdx)
-				}
+		forEachOutput(tx, pubKeyHash, func(utxo UTXO) {
+			if accumulated < amount {
+				txId := hex.EncodeToString(utxo.TxID)
+				accumulated += utxo.Value
+				unspentOutputs[txId] = append(unspentOutputs[txId], utxo.VOut)
 			}
-		}
+		})
 		return nil
 	})
 	if err != nil {
@@ -49,26 +89,132 @@ func (u *UTXOSet) FindUTXO(pubKeyHash []byte) []TxOutput {
 	var UTXOs []TxOutput
 	db := u.Blockchain.DB
 
+	err := db.View(func(tx *bolt.Tx) error {
+		forEachOutput(tx, pubKeyHash, func(utxo UTXO) {
+			UTXOs = append(UTXOs, TxOutput{utxo.Value, utxo.PubKeyHash})
+		})
+		return nil
+	})
+	if err != nil {
+		log.Panic(err)
+	}
+
+	return UTXOs
+}
+
+// ListUnspent returns the unspent outputs of a public key hash along with their location in the chain
+func (u *UTXOSet) ListUnspent(pubKeyHash []byte) []UTXO {
+	var UTXOs []UTXO
+	db := u.Blockchain.DB
+
+	err := db.View(func(tx *bolt.Tx) error {
+		forEachOutput(tx, pubKeyHash, func(utxo UTXO) {
+			UTXOs = append(UTXOs, utxo)
+		})
+		return nil
+	})
+	if err != nil {
+		log.Panic(err)
+	}
+
+	return UTXOs
+}
+
+// GetUTXO returns the unspent output stored under the outpoint
+func (u *UTXOSet) GetUTXO(outpoint Outpoint) (UTXO, error) {
+	var utxo UTXO
+	db := u.Blockchain.DB
+
 	err := db.View(func(tx *bolt.Tx) error {
 		b := tx.Bucket([]byte(utxoBucket))
-		c := b.Cursor()
+		data := b.Get(outpointKey(outpoint.TxID, outpoint.VOut))
+		if data == nil {
+			return fmt.Errorf("output %s is spent or does not exist", outpoint)
+		}
+		utxo = UTXO{outpoint.TxID, outpoint.VOut, DeserializeEntry(data)}
 
-		for k, v := c.First(); k != nil; k, v = c.Next() {
-			outs := DeserializeOutputs(v)
+		return nil
+	})
 
-			for _, out := range outs.Outputs {
-				if out.IsLockedWithKey(pubKeyHash) {
-					UTXOs = append(UTXOs, out)
-				}
+	return utxo, err
+}
+
+// SelectCoins picks the outputs of pubKeyHash that fund a payment of amount coins
+func (u *UTXOSet) SelectCoins(pubKeyHash []byte, amount int, coinControl *CoinControl) ([]UTXO, error) {
+	if coinControl == nil {
+		coinControl = &CoinControl{}
+	}
+
+	if len(coinControl.Outpoints) > 0 {
+		var selected []UTXO
+		total := 0
+		seen := make(map[string]bool)
+
+		for _, outpoint := range coinControl.Outpoints {
+			if seen[outpoint.String()] {
+				return nil, fmt.Errorf("output %s is selected more than once", outpoint)
 			}
+			seen[outpoint.String()] = true
+			if coinControl.Spent[outpoint.String()] {
+				return nil, fmt.Errorf("output %s is already spent by an unconfirmed transaction", outpoint)
+			}
+			utxo, err := u.GetUTXO(outpoint)
+			if err != nil {
+				return nil, err
+			}
+			if !bytes.Equal(utxo.PubKeyHash, pubKeyHash) {
+				return nil, fmt.Errorf("output %s does not belong to this wallet", outpoint)
+			}
+			selected = append(selected, utxo)
+			total += utxo.Value
+		}
+
+		if total < amount {
+			return nil, ErrInsufficientFunds
 		}
+		return selected, nil
+	}
+
+	selector := coinControl.Selector
+	if selector == nil {
+		selector = LargestFirst{}
+	}
+
+	var unspent []UTXO
+	for _, utxo := range u.ListUnspent(pubKeyHash) {
+		if !coinControl.Spent[Outpoint{utxo.TxID, utxo.VOut}.String()] {
+			unspent = append(unspent, utxo)
+		}
+	}
+
+	return selector.Select(unspent, amount)
+}
+
+// HasAddrIndex reports whether the address index is enabled
+func (u *UTXOSet) HasAddrIndex() bool {
+	enabled := false
+
+	err := u.Blockchain.DB.View(func(tx *bolt.Tx) error {
+		enabled = tx.Bucket([]byte(addrIndexBucket)) != nil
 		return nil
 	})
 	if err != nil {
 		log.Panic(err)
 	}
 
-	return UTXOs
+	return enabled
+}
+
+// EnableAddrIndex creates the address index bucket. It is filled on the next Reindex
+// and kept in sync by Update from then on
+func (u *UTXOSet) EnableAddrIndex() {
+	err := u.Blockchain.DB.Update(func(tx *bolt.Tx) error {
+		_, err := tx.CreateBucketIfNotExists([]byte(addrIndexBucket))
+		return err
+	})
+	if err != nil {
+		log.Panic(err)
+	}
 }
 
 // CountTransactions returns the number of transactions in the UTXO set
@@ -79,9 +225,14 @@ func (u *UTXOSet) CountTransactions() int {
 	err := db.View(func(tx *bolt.Tx) error {
 		b := tx.Bucket([]byte(utxoBucket))
 		c := b.Cursor()
+		var lastTxID []byte
 
 		for k, _ := c.First(); k != nil; k, _ = c.Next() {
-			counter++
+			txID, _ := splitOutpointKey(k)
+			if !bytes.Equal(txID, lastTxID) {
+				counter++
+				lastTxID = txID
+			}
 		}
 
 		return nil
@@ -92,50 +243,87 @@ func (u *UTXOSet) CountTransactions() int {
 	return counter
 }
 
-// Reindex rebuilds the UTXO set
+// Reindex rebuilds the UTXO set, and the address index when it is enabled. The old
+// buckets are replaced in the same transaction that fills the new ones, so readers
+// never see an empty chainstate
 func (u *UTXOSet) Reindex() {
 	db := u.Blockchain.DB
-	bucketName := []byte(utxoBucket)
+	UTXOs := u.Blockchain.FindUTXO()
 
 	err := db.Update(func(tx *bolt.Tx) error {
-		err := tx.DeleteBucket(bucketName)
-		if err != nil && err != bolt.ErrBucketNotFound {
-			log.Panic(err)
+		buckets := [][]byte{[]byte(utxoBucket)}
+		if tx.Bucket([]byte(addrIndexBucket)) != nil {
+			buckets = append(buckets, []byte(addrIndexBucket))
 		}
 
-		_, err = tx.CreateBucket(bucketName)
-		if err != nil {
-			log.Panic(err)
+		for _, name := range buckets {
+			err := tx.DeleteBucket(name)
+			if err != nil && err != bolt.ErrBucketNotFound {
+				log.Panic(err)
+			}
+
+			_, err = tx.CreateBucket(name)
+			if err != nil {
+				log.Panic(err)
+			}
 		}
 
-		return nil
-	})
-	if err != nil {
-		log.Panic(err)
-	}
+		b := tx.Bucket([]byte(utxoBucket))
+		idx := tx.Bucket([]byte(addrIndexBucket))
 
-	UTXO := u.Blockchain.FindUTXO()
+		err := tx.Bucket([]byte(blocksBucket)).Put([]byte(chainstateVersionKey), []byte{chainstateVersion})
+		if err != nil {
+			log.Panic(err)
+		}
 
-	err = db.Update(func(tx *bolt.Tx) error {
-		b := tx.Bucket(bucketName)
+		for _, utxo := range UTXOs {
+			key := outpointKey(utxo.TxID, utxo.VOut)
 
-		for txID, outs := range UTXO {
-			key, err := hex.DecodeString(txID)
+			err := b.Put(key, utxo.SerializeEntry())
 			if err != nil {
 				log.Panic(err)
 			}
 
-			err = b.Put(key, outs.SerializeOutputs())
-			if err != nil {
-				log.Panic(err)
+			if idx != nil {
+				err = idx.Put(addrIndexKey(utxo.PubKeyHash, key), []byte{})
+				if err != nil {
+					log.Panic(err)
+				}
 			}
 		}
-		if err != nil {
-			log.Panic(err)
-		}
 
 		return nil
 	})
+	if err != nil {
+		log.Panic(err)
+	}
+}
+
+// upgradeChainstate rebuilds a chainstate stored in an older layout, and returns an error
+// for one written by a newer version
+func (u *UTXOSet) upgradeChainstate() error {
+	var version []byte
+
+	err := u.Blockchain.DB.View(func(tx *bolt.Tx) error {
+		version = append([]byte{}, tx.Bucket([]byte(blocksBucket)).Get([]byte(chainstateVersionKey))...)
+
+		return nil
+	})
+	if err != nil {
+		log.Panic(err)
+	}
+
+	if len(version) == 1 && version[0] == chainstateVersion {
+		return nil
+	}
+	if len(version) == 1 && version[0] > chainstateVersion {
+		return fmt.Errorf("the chainstate has layout version %d, this version only reads %d", version[0], chainstateVersion)
+	}
+
+	logging.Chain.Info("rebuilding the chainstate stored in an older layout")
+	u.Reindex()
+
+	return nil
 }
 
 // Update updates the UTXO set with transactions from the Block
@@ -145,27 +333,25 @@ func (u *UTXOSet) Update(block *Block) {
 
 	err := db.Update(func(tx *bolt.Tx) error {
 		b := tx.Bucket([]byte(utxoBucket))
+		idx := tx.Bucket([]byte(addrIndexBucket))
 
 		for _, tx := range block.Transactions {
 			if !tx.IsCoinbase() {
 				for _, VIn := range tx.VIn {
-					updatedOutputs := TxOutputs{}
-					outsbytes := b.Get(VIn.TxId)
-					outs := DeserializeOutputs(outsbytes)
+					key := outpointKey(VIn.TxId, VIn.VOut)
+					data := b.Get(key)
+					if data == nil {
+						log.Panicf("ERROR: Output %x:%d is not in the UTXO set", VIn.TxId, VIn.VOut)
+					}
+					spent := DeserializeEntry(data)
 
-					for outIdx, out := range outs.Outputs {
-						if outIdx != VIn.VOut {
-							updatedOutputs.Outputs = append(updatedOutputs.Outputs, out)
-						}
+					err := b.Delete(key)
+					if err != nil {
+						log.Panic(err)
 					}
 
-					if len(updatedOutputs.Outputs) == 0 {
-						err := b.Delete(VIn.TxId)
-						if err != nil {
-							log.Panic(err)
-						}
-					} else {
-						err := b.Put(VIn.TxId, updatedOutputs.SerializeOutputs())
+					if idx != nil {
+						err = idx.Delete(addrIndexKey(spent.PubKeyHash, key))
 						if err != nil {
 							log.Panic(err)
 						}
@@ -173,12 +359,21 @@ func (u *UTXOSet) Update(block *Block) {
 				}
 			}
 
-			newOutputs := TxOutputs{}
-			newOutputs.Outputs = append(newOutputs.Outputs, tx.VOut...)
+			for outIdx, out := range tx.VOut {
+				key := outpointKey(tx.ID, outIdx)
+				entry := UTXOEntry{out.Value, out.PubKeyHash, block.Height, tx.IsCoinbase()}
 
-			err := b.Put(tx.ID, newOutputs.SerializeOutputs())
-			if err != nil {
-				log.Panic(err)
+				err := b.Put(key, entry.SerializeEntry())
+				if err != nil {
+					log.Panic(err)
+				}
+
+				if idx != nil {
+					err = idx.Put(addrIndexKey(out.PubKeyHash, key), []byte{})
+					if err != nil {
+						log.Panic(err)
+					}
+				}
 			}
 		}
 
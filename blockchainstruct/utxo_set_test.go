@@ -0,0 +1,128 @@
+package blockchainstruct
+
+import (
+	"github.com/boltdb/bolt"
+	"github.com/cyprus09/blockchain/wallets"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+// mineTestBlock mines txs and a coinbase paying rewardTo into a new block and updates the
+// chainstate with it
+func mineTestBlock(UTXOSet *UTXOSet, rewardTo *wallets.Wallet, txs ...*Transaction) *Block {
+	height := UTXOSet.Blockchain.GetBestHeight() + 1
+	txs = append(txs, NewCoinbaseTx(string(rewardTo.GetAddress()), "", height))
+
+	block := UTXOSet.Blockchain.MineBlock(txs)
+	Expect(block).NotTo(BeNil())
+	UTXOSet.Update(block)
+
+	return block
+}
+
+// chainUnspent returns the unspent outputs of pubKeyHash found by walking every block
+func chainUnspent(bc *Blockchain, pubKeyHash []byte) []UTXO {
+	var utxos []UTXO
+
+	for _, utxo := range bc.FindUTXO() {
+		if string(utxo.PubKeyHash) == string(pubKeyHash) {
+			utxos = append(utxos, utxo)
+		}
+	}
+
+	return utxos
+}
+
+// bucketSize returns the number of keys of the bucket called name
+func bucketSize(UTXOSet *UTXOSet, name string) int {
+	size := 0
+
+	err := UTXOSet.Blockchain.DB.View(func(tx *bolt.Tx) error {
+		size = tx.Bucket([]byte(name)).Stats().KeyN
+		return nil
+	})
+	Expect(err).NotTo(HaveOccurred())
+
+	return size
+}
+
+var _ = Describe("Address index", func() {
+	var alice, bob, carol *wallets.Wallet
+	var UTXOSet *UTXOSet
+
+	// pay sends amount coins from one wallet to another and mines the transaction
+	pay := func(from, to *wallets.Wallet, amount int) {
+		tx := NewUTXOTTransaction(from, string(to.GetAddress()), amount, UTXOSet, nil)
+		mineTestBlock(UTXOSet, to, tx)
+	}
+
+	BeforeEach(func() {
+		alice = wallets.NewWallet(wallets.KeyTypeP256)
+		bob = wallets.NewWallet(wallets.KeyTypeSecp256k1)
+		carol = wallets.NewWallet(wallets.KeyTypeLegacy)
+		UTXOSet = newTestChain(alice)
+
+		pay(alice, bob, 3)
+		pay(bob, carol, 4)
+		pay(alice, carol, 6)
+	})
+
+	It("should find the same outputs as a scan of the chainstate", func() {
+		scanned := make(map[string][]UTXO)
+		for _, wallet := range []*wallets.Wallet{alice, bob, carol} {
+			pubKeyHash := wallets.HashPubKey(wallet.PublicKey)
+			scanned[string(pubKeyHash)] = UTXOSet.ListUnspent(pubKeyHash)
+			Expect(scanned[string(pubKeyHash)]).NotTo(BeEmpty())
+		}
+
+		Expect(UTXOSet.HasAddrIndex()).To(BeFalse())
+		UTXOSet.EnableAddrIndex()
+		UTXOSet.Reindex()
+		Expect(UTXOSet.HasAddrIndex()).To(BeTrue())
+
+		for pubKeyHash, utxos := range scanned {
+			Expect(UTXOSet.ListUnspent([]byte(pubKeyHash))).To(Equal(utxos))
+		}
+	})
+
+	It("should stay in step with the chain as blocks spend and create outputs", func() {
+		UTXOSet.EnableAddrIndex()
+		UTXOSet.Reindex()
+
+		pay(carol, alice, 9)
+		pay(bob, alice, 8)
+
+		for _, wallet := range []*wallets.Wallet{alice, bob, carol} {
+			pubKeyHash := wallets.HashPubKey(wallet.PublicKey)
+			Expect(UTXOSet.ListUnspent(pubKeyHash)).To(ConsistOf(chainUnspent(UTXOSet.Blockchain, pubKeyHash)))
+		}
+		Expect(bucketSize(UTXOSet, addrIndexBucket)).To(Equal(bucketSize(UTXOSet, utxoBucket)))
+	})
+
+	It("should key entries by the pubkey hash followed by the outpoint key", func() {
+		UTXOSet.EnableAddrIndex()
+		UTXOSet.Reindex()
+
+		err := UTXOSet.Blockchain.DB.View(func(tx *bolt.Tx) error {
+			b := tx.Bucket([]byte(utxoBucket))
+			idx := tx.Bucket([]byte(addrIndexBucket))
+
+			return b.ForEach(func(key, data []byte) error {
+				entry := DeserializeEntry(data)
+				Expect(idx.Get(addrIndexKey(entry.PubKeyHash, key))).NotTo(BeNil())
+				Expect(addrIndexKey(entry.PubKeyHash, key)).To(Equal(append(append([]byte{}, entry.PubKeyHash...), key...)))
+				return nil
+			})
+		})
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	It("should return nothing for an address without outputs", func() {
+		stranger := wallets.HashPubKey(wallets.NewWallet(wallets.KeyTypeP256).PublicKey)
+		Expect(UTXOSet.ListUnspent(stranger)).To(BeEmpty())
+
+		UTXOSet.EnableAddrIndex()
+		UTXOSet.Reindex()
+		Expect(UTXOSet.ListUnspent(stranger)).To(BeEmpty())
+	})
+})
@@ -0,0 +1,119 @@
+// Package chaincfg defines the parameters that tell one network apart from another
+package chaincfg
+
+import "fmt"
+
+// Params holds everything that differs between networks. The magics and Bech32 prefixes
+// are unique to this chain, so its messages are never mistaken for Bitcoin's. Mainnet
+// keeps the version byte and genesis block the chain had before it had networks, so
+// existing mainnet wallets and chains stay valid
+type Params struct {
+	Name string
+	// Net is the magic that starts every peer message of the network
+	Net uint32
+	// DefaultPort is the port of the default seed node
+	DefaultPort string
+	// DataDirName is the subdirectory of the data directory holding the network files,
+	// empty for the main network
+	DataDirName string
+
+	// GenesisCoinbaseData is the data of the genesis block coinbase input
+	GenesisCoinbaseData string
+	// TargetBits is the number of leading zero bits a block hash needs
+	TargetBits int
+	// BaseSubsidy is the coinbase reward of the first blocks, halved every
+	// SubsidyHalvingInterval blocks. An interval of 0 never halves it
+	BaseSubsidy            int
+	SubsidyHalvingInterval int
+
+	// PubKeyHashAddrID is the version byte of Base58Check addresses, which sets their first
+	// character: 1 on mainnet, t on testnet and r on regtest
+	PubKeyHashAddrID byte
+	// Bech32HRP is the human readable part of Bech32 addresses
+	Bech32HRP string
+}
+
+// MainNetParams are the parameters of the main network
+var MainNetParams = Params{
+	Name:        "mainnet",
+	Net:         0xc3b2a1f0,
+	DefaultPort: "3000",
+
+	GenesisCoinbaseData:    "The Times 03/Jan/2009 Chancellor on brink of second bailout for banks",
+	TargetBits:             20,
+	BaseSubsidy:            10,
+	SubsidyHalvingInterval: 210000,
+
+	PubKeyHashAddrID: 0x00,
+	Bech32HRP:        "cy",
+}
+
+// TestNetParams are the parameters of the public test network
+var TestNetParams = Params{
+	Name:        "testnet",
+	Net:         0xc3b2a1f1,
+	DefaultPort: "4000",
+	DataDirName: "testnet",
+
+	GenesisCoinbaseData:    "Test network genesis block",
+	TargetBits:             16,
+	BaseSubsidy:            10,
+	SubsidyHalvingInterval: 210000,
+
+	PubKeyHashAddrID: 0x7f,
+	Bech32HRP:        "tcy",
+}
+
+// RegTestParams are the parameters of the local regression test network. Blocks
+// are found almost instantly, so tests can mine them on demand
+var RegTestParams = Params{
+	Name:        "regtest",
+	Net:         0xc3b2a1f2,
+	DefaultPort: "5000",
+	DataDirName: "regtest",
+
+	GenesisCoinbaseData:    "Regression test genesis block",
+	TargetBits:             1,
+	BaseSubsidy:            10,
+	SubsidyHalvingInterval: 150,
+
+	PubKeyHashAddrID: 0x7a,
+	Bech32HRP:        "rcy",
+}
+
+// Networks lists the parameters of every known network
+var Networks = []*Params{&MainNetParams, &TestNetParams, &RegTestParams}
+
+// activeParams are the parameters of the network the process runs on
+var activeParams = &MainNetParams
+
+// ActiveParams returns the parameters of the network the process runs on
+func ActiveParams() *Params {
+	return activeParams
+}
+
+// SetActiveNetwork makes the network called name the one the process runs on
+func SetActiveNetwork(name string) error {
+	for _, params := range Networks {
+		if params.Name == name {
+			activeParams = params
+			return nil
+		}
+	}
+
+	return fmt.Errorf("unknown network %q, use mainnet, testnet or regtest", name)
+}
+
+// Subsidy returns the coinbase reward of the block at height
+func (p *Params) Subsidy(height int) int {
+	if p.SubsidyHalvingInterval == 0 {
+		return p.BaseSubsidy
+	}
+
+	halvings := height / p.SubsidyHalvingInterval
+	if halvings >= 63 {
+		return 0
+	}
+
+	return p.BaseSubsidy >> uint(halvings)
+}
@@ -0,0 +1,287 @@
+package cli
+
+import (
+	"encoding/binary"
+	"hash/fnv"
+	"math/rand"
+	"net"
+	"time"
+)
+
+const (
+	// newBucketCount and triedBucketCount are how many buckets hold the addresses never
+	// reached and those the node talked to. An address of a host always lands in the
+	// same new bucket, so a single host cannot fill the table
+	newBucketCount   = 64
+	triedBucketCount = 16
+	bucketSize       = 32
+	// addrHorizon is how long an address is kept without being heard of
+	addrHorizon = 7 * 24 * time.Hour
+	// maxAddrFailures is how many attempts in a row may fail before an address is forgotten
+	maxAddrFailures = 3
+)
+
+// netAddress is a peer address with the time it was last heard of, as sent in addr messages
+type netAddress struct {
+	Addr      string
+	Timestamp int64
+}
+
+// knownAddress is an address of the address manager
+type knownAddress struct {
+	Addr        string
+	Timestamp   int64
+	Attempts    int
+	LastAttempt int64
+	LastSuccess int64
+	Tried       bool
+}
+
+// addrManager keeps a bounded set of peer addresses, split between new addresses heard
+// of from other nodes and tried addresses the node reached itself. Like the rest of the
+// node state it is guarded by nodeLock
+type addrManager struct {
+	key          uint64
+	addrs        map[string]*knownAddress
+	newBuckets   [newBucketCount]map[string]*knownAddress
+	triedBuckets [triedBucketCount]map[string]*knownAddress
+}
+
+// addrMgr holds the addresses the node knows of
+var addrMgr = newAddrManager()
+
+func newAddrManager() *addrManager {
+	a := &addrManager{key: rand.Uint64(), addrs: make(map[string]*knownAddress)}
+	for i := range a.newBuckets {
+		a.newBuckets[i] = make(map[string]*knownAddress)
+	}
+	for i := range a.triedBuckets {
+		a.triedBuckets[i] = make(map[string]*knownAddress)
+	}
+
+	return a
+}
+
+// bucket returns the index among count buckets of name, salted with the key of the
+// manager so peers cannot choose where their addresses go
+func (a *addrManager) bucket(name string, count int) int {
+	salt := make([]byte, 8)
+	binary.LittleEndian.PutUint64(salt, a.key)
+
+	h := fnv.New64a()
+	h.Write(salt)
+	h.Write([]byte(name))
+
+	return int(h.Sum64() % uint64(count))
+}
+
+func (a *addrManager) newBucket(addr string) map[string]*knownAddress {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		host = addr
+	}
+
+	return a.newBuckets[a.bucket(host, newBucketCount)]
+}
+
+func (a *addrManager) triedBucket(addr string) map[string]*knownAddress {
+	return a.triedBuckets[a.bucket(addr, triedBucketCount)]
+}
+
+// add records addr as heard of at timestamp. It returns whether the address was new
+// or its timestamp newer, that is whether it is worth passing on
+func (a *addrManager) add(addr string, timestamp int64) bool {
+	if _, _, err := net.SplitHostPort(addr); err != nil {
+		return false
+	}
+	now := time.Now()
+	if timestamp > now.Add(10*time.Minute).Unix() {
+		timestamp = now.Unix()
+	}
+	if time.Unix(timestamp, 0).Before(now.Add(-addrHorizon)) {
+		return false
+	}
+
+	if ka, ok := a.addrs[addr]; ok {
+		if timestamp <= ka.Timestamp {
+			return false
+		}
+		ka.Timestamp = timestamp
+		return true
+	}
+
+	ka := &knownAddress{Addr: addr, Timestamp: timestamp}
+	a.insertNew(ka)
+
+	return true
+}
+
+// insertNew puts ka in its new bucket, making room by forgetting the oldest address
+func (a *addrManager) insertNew(ka *knownAddress) {
+	bucket := a.newBucket(ka.Addr)
+	if len(bucket) >= bucketSize {
+		oldest := oldestAddress(bucket)
+		delete(bucket, oldest.Addr)
+		delete(a.addrs, oldest.Addr)
+	}
+
+	ka.Tried = false
+	bucket[ka.Addr] = ka
+	a.addrs[ka.Addr] = ka
+}
+
+// good records that the node reached addr, moving it to the tried addresses. When its
+// tried bucket is full the oldest address there goes back to the new ones
+func (a *addrManager) good(addr string) {
+	now := time.Now().Unix()
+
+	ka, ok := a.addrs[addr]
+	if !ok {
+		if !a.add(addr, now) {
+			return
+		}
+		ka = a.addrs[addr]
+	}
+	ka.Timestamp = now
+	ka.LastAttempt = now
+	ka.LastSuccess = now
+	ka.Attempts = 0
+	if ka.Tried {
+		return
+	}
+
+	delete(a.newBucket(addr), addr)
+	bucket := a.triedBucket(addr)
+	if len(bucket) >= bucketSize {
+		oldest := oldestAddress(bucket)
+		delete(bucket, oldest.Addr)
+		a.insertNew(oldest)
+	}
+
+	ka.Tried = true
+	bucket[addr] = ka
+}
+
+// failed records that the node could not reach addr, forgetting it after maxAddrFailures
+// attempts in a row
+func (a *addrManager) failed(addr string) {
+	ka, ok := a.addrs[addr]
+	if !ok {
+		return
+	}
+
+	ka.Attempts++
+	ka.LastAttempt = time.Now().Unix()
+	if ka.Attempts >= maxAddrFailures {
+		a.remove(ka)
+	}
+}
+
+func (a *addrManager) remove(ka *knownAddress) {
+	if ka.Tried {
+		delete(a.triedBucket(ka.Addr), ka.Addr)
+	} else {
+		delete(a.newBucket(ka.Addr), ka.Addr)
+	}
+	delete(a.addrs, ka.Addr)
+}
+
+// pick returns a random address to connect to that skip does not reject, taken from the
+// tried addresses half of the time, or an empty string when there is none
+func (a *addrManager) pick(skip func(addr string) bool) string {
+	var tried, fresh []string
+
+	for addr, ka := range a.addrs {
+		if skip(addr) {
+			continue
+		}
+		if ka.Tried {
+			tried = append(tried, addr)
+		} else {
+			fresh = append(fresh, addr)
+		}
+	}
+
+	candidates := fresh
+	if len(tried) > 0 && (len(fresh) == 0 || rand.Intn(2) == 0) {
+		candidates = tried
+	}
+	if len(candidates) == 0 {
+		return ""
+	}
+
+	return candidates[rand.Intn(len(candidates))]
+}
+
+// sample returns up to n random addresses heard of within the horizon
+func (a *addrManager) sample(n int) []netAddress {
+	var addrs []netAddress
+	horizon := time.Now().Add(-addrHorizon).Unix()
+
+	for _, ka := range a.addrs {
+		if ka.Timestamp >= horizon {
+			addrs = append(addrs, netAddress{ka.Addr, ka.Timestamp})
+		}
+	}
+	rand.Shuffle(len(addrs), func(i, j int) { addrs[i], addrs[j] = addrs[j], addrs[i] })
+
+	if len(addrs) > n {
+		addrs = addrs[:n]
+	}
+	return addrs
+}
+
+// counts returns how many new and tried addresses the manager holds
+func (a *addrManager) counts() (int, int) {
+	tried := 0
+	for _, ka := range a.addrs {
+		if ka.Tried {
+			tried++
+		}
+	}
+
+	return len(a.addrs) - tried, tried
+}
+
+// all returns a copy of every address, for saving them
+func (a *addrManager) all() []knownAddress {
+	addrs := make([]knownAddress, 0, len(a.addrs))
+	for _, ka := range a.addrs {
+		addrs = append(addrs, *ka)
+	}
+
+	return addrs
+}
+
+// load puts saved addresses back in the manager, dropping those past the horizon
+func (a *addrManager) load(addrs []knownAddress) {
+	for _, saved := range addrs {
+		if !a.add(saved.Addr, saved.Timestamp) {
+			continue
+		}
+
+		ka := a.addrs[saved.Addr]
+		ka.Attempts, ka.LastAttempt, ka.LastSuccess = saved.Attempts, saved.LastAttempt, saved.LastSuccess
+		if saved.Tried {
+			bucket := a.triedBucket(ka.Addr)
+			if len(bucket) < bucketSize {
+				delete(a.newBucket(ka.Addr), ka.Addr)
+				ka.Tried = true
+				bucket[ka.Addr] = ka
+			}
+		}
+	}
+}
+
+// oldestAddress returns the address of bucket heard of the longest time ago
+func oldestAddress(bucket map[string]*knownAddress) *knownAddress {
+	var oldest *knownAddress
+
+	for _, ka := range bucket {
+		if oldest == nil || ka.Timestamp < oldest.Timestamp {
+			oldest = ka
+		}
+	}
+
+	return oldest
+}
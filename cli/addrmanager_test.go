@@ -0,0 +1,202 @@
+package cli
+
+import (
+	"fmt"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/cyprus09/blockchain/blockchainstruct"
+	"github.com/cyprus09/blockchain/chaincfg"
+	"github.com/cyprus09/blockchain/utils"
+	"github.com/cyprus09/blockchain/wallets"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+func TestCli(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "Cli Suite")
+}
+
+var _ = BeforeSuite(func() {
+	// regtest blocks are mined almost instantly
+	Expect(chaincfg.SetActiveNetwork("regtest")).To(Succeed())
+})
+
+// addrVectors are addresses heard of some time ago, and whether the manager keeps them
+var addrVectors = []struct {
+	addr string
+	age  time.Duration
+	kept bool
+}{
+	{"localhost:3000", 0, true},
+	{"10.0.0.1:3000", time.Hour, true},
+	{"[::1]:3000", addrHorizon - time.Hour, true},
+	{"10.0.0.2:3000", addrHorizon + time.Hour, false},
+	{"10.0.0.3", 0, false},
+	{"", 0, false},
+}
+
+var _ = Describe("Address manager", func() {
+	var a *addrManager
+	var now int64
+
+	BeforeEach(func() {
+		a = newAddrManager()
+		now = time.Now().Unix()
+	})
+
+	Describe("add", func() {
+		It("should keep addresses with a port heard of within the horizon", func() {
+			for _, vector := range addrVectors {
+				Expect(a.add(vector.addr, now-int64(vector.age.Seconds()))).To(Equal(vector.kept), vector.addr)
+				_, ok := a.addrs[vector.addr]
+				Expect(ok).To(Equal(vector.kept), vector.addr)
+			}
+		})
+
+		It("should only pass on an address again once heard of later", func() {
+			Expect(a.add("localhost:3000", now-60)).To(BeTrue())
+			Expect(a.add("localhost:3000", now-60)).To(BeFalse())
+			Expect(a.add("localhost:3000", now-120)).To(BeFalse())
+			Expect(a.add("localhost:3000", now)).To(BeTrue())
+
+			Expect(a.addrs["localhost:3000"].Timestamp).To(Equal(now))
+			Expect(a.counts()).To(Equal(1))
+		})
+
+		It("should not trust timestamps from the future", func() {
+			Expect(a.add("localhost:3000", now+int64(time.Hour.Seconds()))).To(BeTrue())
+
+			Expect(a.addrs["localhost:3000"].Timestamp).To(BeNumerically("~", now, 5))
+		})
+
+		It("should forget the oldest address of a full bucket", func() {
+			// every port of a host lands in the same new bucket
+			for i := 0; i <= bucketSize; i++ {
+				Expect(a.add(fmt.Sprintf("10.0.0.1:%d", 3000+i), now-int64(bucketSize-i))).To(BeTrue())
+			}
+
+			Expect(a.newBucket("10.0.0.1:3000")).To(HaveLen(bucketSize))
+			Expect(a.addrs).To(HaveLen(bucketSize))
+			Expect(a.addrs).NotTo(HaveKey("10.0.0.1:3000"))
+			Expect(a.addrs).To(HaveKey(fmt.Sprintf("10.0.0.1:%d", 3000+bucketSize)))
+		})
+	})
+
+	Describe("good", func() {
+		It("should move an address to the tried addresses", func() {
+			a.add("localhost:3000", now-60)
+			a.add("localhost:3001", now-60)
+
+			a.good("localhost:3000")
+
+			ka := a.addrs["localhost:3000"]
+			Expect(ka.Tried).To(BeTrue())
+			Expect(ka.LastSuccess).To(BeNumerically(">=", now))
+			Expect(a.newBucket("localhost:3000")).NotTo(HaveKey("localhost:3000"))
+			Expect(a.triedBucket("localhost:3000")).To(HaveKey("localhost:3000"))
+
+			fresh, tried := a.counts()
+			Expect(fresh).To(Equal(1))
+			Expect(tried).To(Equal(1))
+		})
+
+		It("should add an address it did not know of", func() {
+			a.good("localhost:3000")
+
+			Expect(a.addrs).To(HaveKey("localhost:3000"))
+			Expect(a.addrs["localhost:3000"].Tried).To(BeTrue())
+		})
+	})
+
+	Describe("failed", func() {
+		It("should forget an address after maxAddrFailures attempts in a row", func() {
+			a.add("localhost:3000", now)
+
+			for i := 1; i < maxAddrFailures; i++ {
+				a.failed("localhost:3000")
+				Expect(a.addrs["localhost:3000"].Attempts).To(Equal(i))
+			}
+			a.failed("localhost:3000")
+
+			Expect(a.addrs).To(BeEmpty())
+			Expect(a.newBucket("localhost:3000")).To(BeEmpty())
+		})
+
+		It("should count the attempts again after a success", func() {
+			a.add("localhost:3000", now)
+
+			for i := 1; i < maxAddrFailures; i++ {
+				a.failed("localhost:3000")
+			}
+			a.good("localhost:3000")
+			a.failed("localhost:3000")
+
+			Expect(a.addrs["localhost:3000"].Attempts).To(Equal(1))
+			Expect(a.triedBucket("localhost:3000")).To(HaveKey("localhost:3000"))
+		})
+
+		It("should forget a tried address from its tried bucket", func() {
+			a.good("localhost:3000")
+
+			for i := 0; i < maxAddrFailures; i++ {
+				a.failed("localhost:3000")
+			}
+
+			Expect(a.addrs).To(BeEmpty())
+			Expect(a.triedBucket("localhost:3000")).To(BeEmpty())
+		})
+	})
+
+	Describe("handleVersion", func() {
+		var bc *blockchainstruct.Blockchain
+
+		BeforeEach(func() {
+			dir, err := os.MkdirTemp("", "cli")
+			Expect(err).NotTo(HaveOccurred())
+			DeferCleanup(os.RemoveAll, dir)
+			Expect(utils.SetDataDir(dir)).To(Succeed())
+
+			wallet := wallets.NewWallet(wallets.KeyTypeP256)
+			bc = blockchainstruct.CreateBlockchain(string(wallet.GetAddress()), "test")
+			DeferCleanup(bc.DB.Close)
+
+			nodeAddress = "localhost:3000"
+			addrMgr = a
+			DeferCleanup(func() {
+				nodeAddress, knownNodes, outbox, addrMgr = "", []string{}, nil, newAddrManager()
+			})
+		})
+
+		// versionFrom returns the version message a peer at addr sends
+		versionFrom := func(addr string) []byte {
+			payload := gobEncode(version{addr, nodeVersion, bc.GetBestHeight()})
+			return append(commandToBytes("version"), payload...)
+		}
+
+		It("should make the node that sent it a peer", func() {
+			handleVersion(versionFrom("localhost:3001"), bc)
+			handleVersion(versionFrom("localhost:3001"), bc)
+			handleVersion(versionFrom(nodeAddress), bc)
+
+			Expect(knownNodes).To(Equal([]string{"localhost:3001"}))
+			Expect(a.addrs).To(HaveKey("localhost:3001"))
+		})
+
+		It("should take no more than maxPeers peers", func() {
+			knownNodes = []string{}
+			for i := 1; i < maxPeers; i++ {
+				knownNodes = append(knownNodes, fmt.Sprintf("localhost:%d", 4000+i))
+			}
+
+			handleVersion(versionFrom("localhost:3001"), bc)
+			handleVersion(versionFrom("localhost:3002"), bc)
+
+			Expect(knownNodes).To(HaveLen(maxPeers))
+			Expect(knownNodes).To(ContainElement("localhost:3001"))
+			Expect(knownNodes).NotTo(ContainElement("localhost:3002"))
+		})
+	})
+})
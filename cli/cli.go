@@ -5,6 +5,10 @@ import (
 	"fmt"
 	"log"
 	"os"
+	"path/filepath"
+
+	"github.com/cyprus09/blockchain/chaincfg"
+	"github.com/cyprus09/blockchain/utils"
 )
 
 // CLI struct helps process command line arguments
@@ -12,23 +16,75 @@ type CLI struct{}
 
 // printUsage prints all the available commands with their usage
 func (cli *CLI) printUsage() {
+	fmt.Println("Usage: blockchain [-config <file>] [-datadir <dir>] [-network <network>] [-rpcaddr <addr>] [-rpcuser <user>] [-rpcpassword <password>] <command> [flags]")
+	fmt.Println("")
 	fmt.Println("Commands:")
 	fmt.Println("")
 	fmt.Println("  createblockchain -address <address>                                   : Create a blockchain and genesis block reward to address")
 	fmt.Println("")
 	fmt.Println("  printchain                                                            :  Displays all the blocks in the blockchain in order.")
 	fmt.Println("")
-	fmt.Println("  createwallet                                                          : Generates a new key-pair and saves it into the wallet file")
+	fmt.Println("  createwallet -keytype <p256|secp256k1>                                : Derives the next HD address and saves it into the wallet file")
+	fmt.Println("")
+	fmt.Println("  restorewallet -mnemonic <phrase> -keytype <type> -gap <count>         : Regenerates the addresses of a recovery phrase, scanning until gap unused ones in a row")
+	fmt.Println("")
+	fmt.Println("  exportkey -address <address> -format <wif|pem>                        : Prints the private key of address")
+	fmt.Println("")
+	fmt.Println("  importkey -wif <key> | -pem <file> -rescan                            : Adds a private key to the wallet and rescans the chain for it")
+	fmt.Println("")
+	fmt.Println("  dumpwallet -file <file>                                               : Writes every key and the HD seed of the wallet to a JSON file")
+	fmt.Println("")
+	fmt.Println("  importwallet -file <file> -rescan                                     : Adds the keys of a JSON wallet dump and rescans the chain for them")
+	fmt.Println("")
+	fmt.Println("  importaddress -address <address> -rescan                              : Watches an address without its private key and rescans the chain for it")
+	fmt.Println("")
+	fmt.Println("  signmessage -address <address> -message <message>                     : Signs message with the key of address to prove control of it")
+	fmt.Println("")
+	fmt.Println("  verifymessage -address <address> -signature <sig> -message <message>  : Checks that signature was made over message by the key of address")
+	fmt.Println("")
+	fmt.Println("  listtransactions -address <address> -count <n> -skip <n>              : Lists wallet transactions newest first, for all addresses when -address is empty")
 	fmt.Println("")
-	fmt.Println("  listaddresses                                                         : Lists all addresses from the wallet file")
+	fmt.Println("  encryptwallet -passphrase <passphrase>                                : Encrypts the private keys in the wallet file under passphrase")
+	fmt.Println("")
+	fmt.Println("  walletpassphrase -passphrase <passphrase> -timeout <seconds>          : Unlocks the wallet of the running node until timeout. Offline commands ask for the passphrase instead")
+	fmt.Println("")
+	fmt.Println("  walletlock                                                            : Locks the wallet of the running node again before the timeout")
+	fmt.Println("")
+	fmt.Println("  changepassphrase -old <passphrase> -new <passphrase>                  : Re-encrypts the wallet under a new passphrase")
+	fmt.Println("")
+	fmt.Println("  listaddresses -bech32                                                 : Lists all addresses from the wallet file, in Bech32 form with -bech32")
 	fmt.Println("")
 	fmt.Println("  getbalance -address <address>                                         : Get balance of address")
 	fmt.Println("")
-	fmt.Println("  reindexutxo                                                     : Rebuilds the UTXO set")
+	fmt.Println("  listunspent -address <address>                                        : Lists the unspent outputs of address")
+	fmt.Println("")
+	fmt.Println("  reindexutxo -addrindex                                                : Rebuilds the UTXO set. Builds and maintains the address index, when -addrindex is set.")
 	fmt.Println("")
 	fmt.Println("  sendcoin -from <from_address> -to <to_address> -amount <amount> -mine : Send amount of coins from from_address to to_address. Mine on the same node, when -mine is set.")
+	fmt.Println("           -coinselect <largest|smallest|bnb|random> -dust <amount>     : Pick inputs with the given strategy. Change below -dust is left as fee.")
+	fmt.Println("           -inputs <txid:vout,...>                                      : Spend exactly the given outputs instead.")
+	fmt.Println("")
+	fmt.Println("  sendmany -from <from_address> -file <payments.csv|payments.json> -mine : Pay every address,amount row of the file in one transaction. Takes the sendcoin coin control flags.")
+	fmt.Println("")
+	fmt.Println("  createrawtx -from <address> -to <address> -amount <amount> -out <file> : Writes an unsigned transaction with the outputs it spends, without needing keys. Takes the sendcoin coin control flags.")
+	fmt.Println("")
+	fmt.Println("  signrawtx -in <file> -out <file>                                      : Signs a createrawtx transaction with the wallet file only, no chain needed")
+	fmt.Println("")
+	fmt.Println("  broadcastrawtx -in <file> -mine                                       : Submits a signed transaction to the network. Mine on the same node, when -mine is set.")
+	fmt.Println("")
+	fmt.Println("  generate -blocks <n> -address <address>                               : Mines n blocks (at most 1000) paying address right away on regtest, with the mempool of a running node, and prints their hashes")
+	fmt.Println("")
+	fmt.Println("  rpc -method <method> [params...]                                     : Calls a JSON-RPC method of the running node. Params that are not JSON are sent as strings.")
 	fmt.Println("")
 	fmt.Println(" startnode -miner <address> : Start a node with ID specified in nodeID env. var. -miner enables mining")
+	fmt.Println("           -listen <addr> -externaladdr <addr> -seeds <addr,...>     : Accept peers on listen, give them externaladdr and connect to seeds")
+	fmt.Println("           -loglevel <debug|info|warn|error> -logformat <text|json> : Log only records at or above the level, as text or JSON")
+	fmt.Println("")
+	fmt.Println("Settings are read from the -config JSON file, then the NETWORK env. var., then the flags")
+	fmt.Println("While the node runs, getbalance and sendcoin without -mine go through its RPC server, by default on port NODE_ID+10000")
+	fmt.Println("RPC clients authenticate with -rpcuser and -rpcpassword, or without them with the rpc_NODE_ID.cookie file the node writes to the data directory")
+	fmt.Println("The RPC port also serves the /rest/ explorer, the /events (ndjson) and /ws (WebSocket) notification streams and Prometheus /metrics, with the same authentication")
+	fmt.Println("The network picks the chain parameters of mainnet (default), testnet or regtest, whose blocks are mined instantly")
 }
 
 // validateArgs helps in validating the number of arguments within the cli
@@ -49,61 +105,266 @@ func (cli *CLI) Run() {
 		os.Exit(1)
 	}
 
+	globalFlags := flag.NewFlagSet("blockchain", flag.ExitOnError)
+	addConfigFlags(globalFlags)
+	err := globalFlags.Parse(os.Args[1:])
+	if err != nil {
+		log.Panic(err)
+	}
+	args := globalFlags.Args()
+	if len(args) == 0 {
+		cli.printUsage()
+		os.Exit(1)
+	}
+
+	config, err := LoadConfig(globalFlags.Lookup("config").Value.String(), nodeID)
+	if err != nil {
+		log.Panic(err)
+	}
+	if network := os.Getenv("NETWORK"); network != "" {
+		config.Network = network
+	}
+	config.applyFlags(globalFlags)
+	nodeConfig = config
+
+	if config.Network != "" {
+		if err := chaincfg.SetActiveNetwork(config.Network); err != nil {
+			log.Panic(err)
+		}
+	}
+	dataDir := filepath.Join(config.DataDir, chaincfg.ActiveParams().DataDirName)
+	if err := utils.SetDataDir(dataDir); err != nil {
+		log.Panic(err)
+	}
+
 	getBalanceCmd := flag.NewFlagSet("getBalance", flag.ExitOnError)
 	createBlockchainCmd := flag.NewFlagSet("createblockchain", flag.ExitOnError)
 	createWalletCmd := flag.NewFlagSet("createwallet", flag.ExitOnError)
 	listAddressesCmd := flag.NewFlagSet("listaddresses", flag.ExitOnError)
+	restoreWalletCmd := flag.NewFlagSet("restorewallet", flag.ExitOnError)
+	exportKeyCmd := flag.NewFlagSet("exportkey", flag.ExitOnError)
+	importKeyCmd := flag.NewFlagSet("importkey", flag.ExitOnError)
+	dumpWalletCmd := flag.NewFlagSet("dumpwallet", flag.ExitOnError)
+	importWalletCmd := flag.NewFlagSet("importwallet", flag.ExitOnError)
+	importAddressCmd := flag.NewFlagSet("importaddress", flag.ExitOnError)
+	signMessageCmd := flag.NewFlagSet("signmessage", flag.ExitOnError)
+	verifyMessageCmd := flag.NewFlagSet("verifymessage", flag.ExitOnError)
+	listTransactionsCmd := flag.NewFlagSet("listtransactions", flag.ExitOnError)
+	encryptWalletCmd := flag.NewFlagSet("encryptwallet", flag.ExitOnError)
+	walletPassphraseCmd := flag.NewFlagSet("walletpassphrase", flag.ExitOnError)
+	walletLockCmd := flag.NewFlagSet("walletlock", flag.ExitOnError)
+	changePassphraseCmd := flag.NewFlagSet("changepassphrase", flag.ExitOnError)
 	sendCoinCmd := flag.NewFlagSet("sendcoin", flag.ExitOnError)
+	sendManyCmd := flag.NewFlagSet("sendmany", flag.ExitOnError)
+	createRawTxCmd := flag.NewFlagSet("createrawtx", flag.ExitOnError)
+	signRawTxCmd := flag.NewFlagSet("signrawtx", flag.ExitOnError)
+	broadcastRawTxCmd := flag.NewFlagSet("broadcastrawtx", flag.ExitOnError)
+	generateCmd := flag.NewFlagSet("generate", flag.ExitOnError)
+	rpcCmd := flag.NewFlagSet("rpc", flag.ExitOnError)
 	startNodeCmd := flag.NewFlagSet("startnode", flag.ExitOnError)
 	printChainCmd := flag.NewFlagSet("printchain", flag.ExitOnError)
 	reindexUTXOCmd := flag.NewFlagSet("reindexutxo", flag.ExitOnError)
+	listUnspentCmd := flag.NewFlagSet("listunspent", flag.ExitOnError)
 
 	getBalanceAddress := getBalanceCmd.String("address", "", "The address to get balance for")
 	createBlockchainAddress := createBlockchainCmd.String("address", "", "The address to send genesis block reward to")
+	createWalletKeyType := createWalletCmd.String("keytype", "", "Key type of a new HD seed: p256 or secp256k1")
+	listAddressesBech32 := listAddressesCmd.Bool("bech32", false, "Print Bech32 addresses")
+	restoreMnemonic := restoreWalletCmd.String("mnemonic", "", "Recovery phrase of the wallet")
+	restoreKeyType := restoreWalletCmd.String("keytype", "p256", "Key type the seed derives: p256, secp256k1 or legacy")
+	restoreGap := restoreWalletCmd.Int("gap", 20, "Stop after this many unused addresses in a row")
+	exportKeyAddress := exportKeyCmd.String("address", "", "The address to export the private key of")
+	exportKeyFormat := exportKeyCmd.String("format", "wif", "Key format: wif or pem")
+	importKeyWIF := importKeyCmd.String("wif", "", "WIF encoded private key")
+	importKeyPEM := importKeyCmd.String("pem", "", "File with a PKCS8 or SEC1 PEM encoded private key")
+	importKeyRescan := importKeyCmd.Bool("rescan", true, "Rescan the chain for the imported address")
+	dumpWalletFile := dumpWalletCmd.String("file", "", "File to write the wallet dump to")
+	importWalletFile := importWalletCmd.String("file", "", "Wallet dump to import")
+	importWalletRescan := importWalletCmd.Bool("rescan", true, "Rescan the chain for the imported addresses")
+	importAddressAddress := importAddressCmd.String("address", "", "The address to watch")
+	importAddressRescan := importAddressCmd.Bool("rescan", true, "Rescan the chain for the watched address")
+	signMessageAddress := signMessageCmd.String("address", "", "The address to sign with")
+	signMessageMessage := signMessageCmd.String("message", "", "The message to sign")
+	verifyMessageAddress := verifyMessageCmd.String("address", "", "The address that signed the message")
+	verifyMessageSignature := verifyMessageCmd.String("signature", "", "Base64 signature of signmessage")
+	verifyMessageMessage := verifyMessageCmd.String("message", "", "The signed message")
+	listTxAddress := listTransactionsCmd.String("address", "", "Only list transactions of this address")
+	listTxCount := listTransactionsCmd.Int("count", defaultTxCount, "Number of entries to show")
+	listTxSkip := listTransactionsCmd.Int("skip", 0, "Number of newest entries to skip")
+	encryptPassphrase := encryptWalletCmd.String("passphrase", "", "Passphrase to encrypt the wallet with, prompted for when empty")
+	unlockPassphrase := walletPassphraseCmd.String("passphrase", "", "Wallet passphrase, prompted for when empty")
+	unlockTimeout := walletPassphraseCmd.Int("timeout", 60, "Seconds to keep the wallet unlocked")
+	oldPassphrase := changePassphraseCmd.String("old", "", "Current wallet passphrase, prompted for when empty")
+	newPassphrase := changePassphraseCmd.String("new", "", "New wallet passphrase, prompted for when empty")
 	sendFrom := sendCoinCmd.String("from", "", "Source wallet address")
 	sendTo := sendCoinCmd.String("to", "", "Destination wallet address")
 	sendAmount := sendCoinCmd.Int("amount", 0, "Amount to send")
 	sendMine := sendCoinCmd.Bool("mine", false, "Mine immediately on the same node")
-	startNodeMiner := startNodeCmd.String("miner", "", "Enable mining node and send reward to <address>")
+	sendCoinSelect := sendCoinCmd.String("coinselect", "largest", "Coin selection strategy: largest, smallest, bnb or random")
+	sendDust := sendCoinCmd.Int("dust", 0, "Change below this amount is left as fee")
+	sendInputs := sendCoinCmd.String("inputs", "", "Comma separated <txid>:<vout> outputs to spend")
+	sendManyFrom := sendManyCmd.String("from", "", "Source wallet address")
+	sendManyFile := sendManyCmd.String("file", "", "CSV or JSON file with the address and amount of each payment")
+	sendManyMine := sendManyCmd.Bool("mine", false, "Mine immediately on the same node")
+	sendManyCoinSelect := sendManyCmd.String("coinselect", "largest", "Coin selection strategy: largest, smallest, bnb or random")
+	sendManyDust := sendManyCmd.Int("dust", 0, "Change below this amount is left as fee")
+	sendManyInputs := sendManyCmd.String("inputs", "", "Comma separated <txid>:<vout> outputs to spend")
+	createRawTxFrom := createRawTxCmd.String("from", "", "Source address, its keys are not needed")
+	createRawTxTo := createRawTxCmd.String("to", "", "Destination address")
+	createRawTxAmount := createRawTxCmd.Int("amount", 0, "Amount to send")
+	createRawTxOut := createRawTxCmd.String("out", "", "File to write the unsigned transaction to, printed when empty")
+	createRawTxCoinSelect := createRawTxCmd.String("coinselect", "largest", "Coin selection strategy: largest, smallest, bnb or random")
+	createRawTxDust := createRawTxCmd.Int("dust", 0, "Change below this amount is left as fee")
+	createRawTxInputs := createRawTxCmd.String("inputs", "", "Comma separated <txid>:<vout> outputs to spend")
+	signRawTxIn := signRawTxCmd.String("in", "", "File with the transaction to sign")
+	signRawTxOut := signRawTxCmd.String("out", "", "File to write the signed transaction to, the input file when empty")
+	broadcastRawTxIn := broadcastRawTxCmd.String("in", "", "File with the signed transaction")
+	broadcastRawTxMine := broadcastRawTxCmd.Bool("mine", false, "Mine immediately on the same node")
+	generateBlocks := generateCmd.Int("blocks", 1, "Number of blocks to mine")
+	generateAddress := generateCmd.String("address", "", "The address receiving the block rewards")
+	rpcMethod := rpcCmd.String("method", "", "The JSON-RPC method to call")
+	addNodeFlags(startNodeCmd)
+	reindexAddrIndex := reindexUTXOCmd.Bool("addrindex", false, "Build and maintain the address index")
+	listUnspentAddress := listUnspentCmd.String("address", "", "The address to list unspent outputs for")
 
-	switch os.Args[1] {
+	switch args[0] {
 	case "getbalance":
-		err := getBalanceCmd.Parse(os.Args[2:])
+		err := getBalanceCmd.Parse(args[1:])
 		if err != nil {
 			log.Panic(err)
 		}
 	case "createblockchain":
-		err := createBlockchainCmd.Parse(os.Args[2:])
+		err := createBlockchainCmd.Parse(args[1:])
 		if err != nil {
 			log.Panic(err)
 		}
 	case "createwallet":
-		err := createWalletCmd.Parse(os.Args[2:])
+		err := createWalletCmd.Parse(args[1:])
 		if err != nil {
 			log.Panic(err)
 		}
 	case "listaddresses":
-		err := listAddressesCmd.Parse(os.Args[2:])
+		err := listAddressesCmd.Parse(args[1:])
+		if err != nil {
+			log.Panic(err)
+		}
+	case "restorewallet":
+		err := restoreWalletCmd.Parse(args[1:])
+		if err != nil {
+			log.Panic(err)
+		}
+	case "exportkey":
+		err := exportKeyCmd.Parse(args[1:])
+		if err != nil {
+			log.Panic(err)
+		}
+	case "importkey":
+		err := importKeyCmd.Parse(args[1:])
+		if err != nil {
+			log.Panic(err)
+		}
+	case "dumpwallet":
+		err := dumpWalletCmd.Parse(args[1:])
+		if err != nil {
+			log.Panic(err)
+		}
+	case "importwallet":
+		err := importWalletCmd.Parse(args[1:])
+		if err != nil {
+			log.Panic(err)
+		}
+	case "importaddress":
+		err := importAddressCmd.Parse(args[1:])
+		if err != nil {
+			log.Panic(err)
+		}
+	case "signmessage":
+		err := signMessageCmd.Parse(args[1:])
+		if err != nil {
+			log.Panic(err)
+		}
+	case "verifymessage":
+		err := verifyMessageCmd.Parse(args[1:])
+		if err != nil {
+			log.Panic(err)
+		}
+	case "listtransactions":
+		err := listTransactionsCmd.Parse(args[1:])
+		if err != nil {
+			log.Panic(err)
+		}
+	case "encryptwallet":
+		err := encryptWalletCmd.Parse(args[1:])
+		if err != nil {
+			log.Panic(err)
+		}
+	case "walletpassphrase":
+		err := walletPassphraseCmd.Parse(args[1:])
+		if err != nil {
+			log.Panic(err)
+		}
+	case "walletlock":
+		err := walletLockCmd.Parse(args[1:])
+		if err != nil {
+			log.Panic(err)
+		}
+	case "changepassphrase":
+		err := changePassphraseCmd.Parse(args[1:])
 		if err != nil {
 			log.Panic(err)
 		}
 	case "printchain":
-		err := printChainCmd.Parse(os.Args[2:])
+		err := printChainCmd.Parse(args[1:])
 		if err != nil {
 			log.Panic(err)
 		}
 	case "reindexutxo":
-		err := reindexUTXOCmd.Parse(os.Args[2:])
+		err := reindexUTXOCmd.Parse(args[1:])
+		if err != nil {
+			log.Panic(err)
+		}
+	case "listunspent":
+		err := listUnspentCmd.Parse(args[1:])
 		if err != nil {
 			log.Panic(err)
 		}
 	case "sendcoin":
-		err := sendCoinCmd.Parse(os.Args[2:])
+		err := sendCoinCmd.Parse(args[1:])
+		if err != nil {
+			log.Panic(err)
+		}
+	case "sendmany":
+		err := sendManyCmd.Parse(args[1:])
+		if err != nil {
+			log.Panic(err)
+		}
+	case "createrawtx":
+		err := createRawTxCmd.Parse(args[1:])
+		if err != nil {
+			log.Panic(err)
+		}
+	case "signrawtx":
+		err := signRawTxCmd.Parse(args[1:])
+		if err != nil {
+			log.Panic(err)
+		}
+	case "broadcastrawtx":
+		err := broadcastRawTxCmd.Parse(args[1:])
+		if err != nil {
+			log.Panic(err)
+		}
+	case "generate":
+		err := generateCmd.Parse(args[1:])
+		if err != nil {
+			log.Panic(err)
+		}
+	case "rpc":
+		err := rpcCmd.Parse(args[1:])
 		if err != nil {
 			log.Panic(err)
 		}
 	case "startnode":
-		err := startNodeCmd.Parse(os.Args[2:])
+		err := startNodeCmd.Parse(args[1:])
 		if err != nil {
 			log.Panic(err)
 		}
@@ -129,11 +390,103 @@ func (cli *CLI) Run() {
 	}
 
 	if createWalletCmd.Parsed() {
-		cli.createWallet(nodeID)
+		cli.createWallet(*createWalletKeyType, nodeID)
 	}
 
 	if listAddressesCmd.Parsed() {
-		cli.listAddresses(nodeID)
+		cli.listAddresses(*listAddressesBech32, nodeID)
+	}
+
+	if restoreWalletCmd.Parsed() {
+		if *restoreMnemonic == "" || *restoreGap <= 0 {
+			restoreWalletCmd.Usage()
+			os.Exit(1)
+		}
+		cli.restoreWallet(*restoreMnemonic, *restoreKeyType, *restoreGap, nodeID)
+	}
+
+	if exportKeyCmd.Parsed() {
+		if *exportKeyAddress == "" {
+			exportKeyCmd.Usage()
+			os.Exit(1)
+		}
+		cli.exportKey(*exportKeyAddress, *exportKeyFormat, nodeID)
+	}
+
+	if importKeyCmd.Parsed() {
+		if (*importKeyWIF == "") == (*importKeyPEM == "") {
+			importKeyCmd.Usage()
+			os.Exit(1)
+		}
+		cli.importKey(*importKeyWIF, *importKeyPEM, *importKeyRescan, nodeID)
+	}
+
+	if dumpWalletCmd.Parsed() {
+		if *dumpWalletFile == "" {
+			dumpWalletCmd.Usage()
+			os.Exit(1)
+		}
+		cli.dumpWallet(*dumpWalletFile, nodeID)
+	}
+
+	if importWalletCmd.Parsed() {
+		if *importWalletFile == "" {
+			importWalletCmd.Usage()
+			os.Exit(1)
+		}
+		cli.importWallet(*importWalletFile, *importWalletRescan, nodeID)
+	}
+
+	if importAddressCmd.Parsed() {
+		if *importAddressAddress == "" {
+			importAddressCmd.Usage()
+			os.Exit(1)
+		}
+		cli.importAddress(*importAddressAddress, *importAddressRescan, nodeID)
+	}
+
+	if signMessageCmd.Parsed() {
+		if *signMessageAddress == "" {
+			signMessageCmd.Usage()
+			os.Exit(1)
+		}
+		cli.signMessage(*signMessageAddress, *signMessageMessage, nodeID)
+	}
+
+	if verifyMessageCmd.Parsed() {
+		if *verifyMessageAddress == "" || *verifyMessageSignature == "" {
+			verifyMessageCmd.Usage()
+			os.Exit(1)
+		}
+		cli.verifyMessage(*verifyMessageAddress, *verifyMessageSignature, *verifyMessageMessage)
+	}
+
+	if listTransactionsCmd.Parsed() {
+		if *listTxCount <= 0 || *listTxSkip < 0 {
+			listTransactionsCmd.Usage()
+			os.Exit(1)
+		}
+		cli.listTransactions(*listTxAddress, *listTxCount, *listTxSkip, nodeID)
+	}
+
+	if encryptWalletCmd.Parsed() {
+		cli.encryptWallet(*encryptPassphrase, nodeID)
+	}
+
+	if walletPassphraseCmd.Parsed() {
+		if *unlockTimeout <= 0 {
+			walletPassphraseCmd.Usage()
+			os.Exit(1)
+		}
+		cli.walletPassphrase(*unlockPassphrase, *unlockTimeout)
+	}
+
+	if walletLockCmd.Parsed() {
+		cli.walletLock()
+	}
+
+	if changePassphraseCmd.Parsed() {
+		cli.changePassphrase(*oldPassphrase, *newPassphrase, nodeID)
 	}
 
 	if printChainCmd.Parsed() {
@@ -141,7 +494,15 @@ func (cli *CLI) Run() {
 	}
 
 	if reindexUTXOCmd.Parsed() {
-		cli.reindexUTXO(nodeID)
+		cli.reindexUTXO(nodeID, *reindexAddrIndex)
+	}
+
+	if listUnspentCmd.Parsed() {
+		if *listUnspentAddress == "" {
+			listUnspentCmd.Usage()
+			os.Exit(1)
+		}
+		cli.listUnspent(*listUnspentAddress, nodeID)
 	}
 
 	if sendCoinCmd.Parsed() {
@@ -149,7 +510,70 @@ func (cli *CLI) Run() {
 			sendCoinCmd.Usage()
 			os.Exit(1)
 		}
-		cli.sendCoin(*sendFrom, *sendTo, *sendAmount, nodeID, *sendMine)
+		if !*sendMine && nodeIsRunning() {
+			cli.sendCoinRPC(*sendFrom, *sendTo, *sendAmount, *sendCoinSelect, *sendDust, *sendInputs)
+			return
+		}
+		coinControl := parseCoinControl(*sendCoinSelect, *sendDust, *sendInputs)
+		cli.sendCoin(*sendFrom, *sendTo, *sendAmount, nodeID, *sendMine, coinControl)
+	}
+
+	if sendManyCmd.Parsed() {
+		if *sendManyFrom == "" || *sendManyFile == "" {
+			sendManyCmd.Usage()
+			os.Exit(1)
+		}
+		if !*sendManyMine && nodeIsRunning() {
+			cli.sendManyRPC(*sendManyFrom, *sendManyFile, *sendManyCoinSelect, *sendManyDust, *sendManyInputs)
+			return
+		}
+		coinControl := parseCoinControl(*sendManyCoinSelect, *sendManyDust, *sendManyInputs)
+		cli.sendMany(*sendManyFrom, *sendManyFile, nodeID, *sendManyMine, coinControl)
+	}
+
+	if createRawTxCmd.Parsed() {
+		if *createRawTxFrom == "" || *createRawTxTo == "" || *createRawTxAmount <= 0 {
+			createRawTxCmd.Usage()
+			os.Exit(1)
+		}
+		coinControl := parseCoinControl(*createRawTxCoinSelect, *createRawTxDust, *createRawTxInputs)
+		cli.createRawTx(*createRawTxFrom, *createRawTxTo, *createRawTxAmount, *createRawTxOut, nodeID, coinControl)
+	}
+
+	if signRawTxCmd.Parsed() {
+		if *signRawTxIn == "" {
+			signRawTxCmd.Usage()
+			os.Exit(1)
+		}
+		cli.signRawTx(*signRawTxIn, *signRawTxOut, nodeID)
+	}
+
+	if broadcastRawTxCmd.Parsed() {
+		if *broadcastRawTxIn == "" {
+			broadcastRawTxCmd.Usage()
+			os.Exit(1)
+		}
+		if !*broadcastRawTxMine && nodeIsRunning() {
+			cli.broadcastRawTxRPC(*broadcastRawTxIn)
+			return
+		}
+		cli.broadcastRawTx(*broadcastRawTxIn, nodeID, *broadcastRawTxMine)
+	}
+
+	if generateCmd.Parsed() {
+		if *generateAddress == "" || *generateBlocks <= 0 {
+			generateCmd.Usage()
+			os.Exit(1)
+		}
+		cli.generate(*generateBlocks, *generateAddress, nodeID)
+	}
+
+	if rpcCmd.Parsed() {
+		if *rpcMethod == "" {
+			rpcCmd.Usage()
+			os.Exit(1)
+		}
+		cli.rpc(*rpcMethod, rpcCmd.Args())
 	}
 
 	if startNodeCmd.Parsed() {
@@ -158,6 +582,7 @@ func (cli *CLI) Run() {
 			startNodeCmd.Usage()
 			os.Exit(1)
 		}
-		cli.startNode(nodeID, *startNodeMiner)
+		nodeConfig.applyFlags(startNodeCmd)
+		cli.startNode(nodeID, nodeConfig)
 	}
 }
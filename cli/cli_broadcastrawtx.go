@@ -0,0 +1,75 @@
+package cli
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+
+	"github.com/cyprus09/blockchain/blockchainstruct"
+	"github.com/cyprus09/blockchain/wallets"
+)
+
+// broadcastRawTx submits a transaction signed by signrawtx
+func (cli *CLI) broadcastRawTx(in, nodeID string, mineNow bool) {
+	ptx, err := readRawTx(in)
+	if err != nil {
+		fmt.Printf("ERROR: %v\n", err)
+		os.Exit(1)
+	}
+
+	if !ptx.IsSigned() {
+		fmt.Println("ERROR: Transaction is not signed, run signrawtx on it first")
+		os.Exit(1)
+	}
+
+	bc := openBlockchain(nodeID)
+	UTXOSet := blockchainstruct.UTXOSet{Blockchain: bc}
+	defer bc.DB.Close()
+
+	for _, VIn := range ptx.Tx.VIn {
+		_, err := UTXOSet.GetUTXO(blockchainstruct.Outpoint{TxID: VIn.TxId, VOut: VIn.VOut})
+		if err != nil {
+			fmt.Printf("ERROR: Input %x:%d is already spent or unknown\n", VIn.TxId, VIn.VOut)
+			os.Exit(1)
+		}
+	}
+	if !bc.VerifyTransaction(&ptx.Tx) {
+		fmt.Println("ERROR: Transaction signatures do not match the chain")
+		os.Exit(1)
+	}
+
+	from := wallets.EncodeAddress(ptx.Inputs[0].PubKeyHash)
+	submitTx(&ptx.Tx, from, &UTXOSet, mineNow)
+
+	fmt.Printf("Success sent transaction %x\n", ptx.Tx.ID)
+}
+
+// broadcastRawTxRPC hands a transaction signed by signrawtx to the running node
+func (cli *CLI) broadcastRawTxRPC(in string) {
+	ptx, err := readRawTx(in)
+	if err != nil {
+		fmt.Printf("ERROR: %v\n", err)
+		os.Exit(1)
+	}
+
+	if !ptx.IsSigned() {
+		fmt.Println("ERROR: Transaction is not signed, run signrawtx on it first")
+		os.Exit(1)
+	}
+
+	result, err := callRPC("sendrawtransaction", hex.EncodeToString(ptx.Tx.SerializeTransaction()))
+	if err != nil {
+		fmt.Printf("ERROR: %v\n", err)
+		os.Exit(1)
+	}
+
+	var txID string
+	err = json.Unmarshal(result, &txID)
+	if err != nil {
+		log.Panic(err)
+	}
+
+	fmt.Printf("Success sent transaction %s\n", txID)
+}
@@ -0,0 +1,38 @@
+package cli
+
+import (
+	"fmt"
+	"log"
+	"os"
+
+	"github.com/cyprus09/blockchain/wallets"
+)
+
+func (cli *CLI) changePassphrase(oldPassphrase, newPassphrase, nodeID string) {
+	ws, err := wallets.NewWallets(nodeID)
+	if err != nil {
+		log.Panic(err)
+	}
+
+	if oldPassphrase == "" {
+		oldPassphrase = readPassphrase("Current wallet passphrase: ")
+	}
+	if newPassphrase == "" {
+		newPassphrase = readPassphrase("New wallet passphrase: ")
+	}
+
+	err = ws.ChangePassphrase(oldPassphrase, newPassphrase)
+	if err != nil {
+		fmt.Printf("ERROR: %v\n", err)
+		os.Exit(1)
+	}
+	ws.SaveToFile(nodeID)
+
+	// a running node may hold the old key
+	_, err = callRPC("walletlock")
+	if err != nil && err != errNodeNotRunning {
+		log.Panic(err)
+	}
+
+	fmt.Println("Done! Wallet passphrase changed, the wallet is locked.")
+}
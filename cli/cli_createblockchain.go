@@ -9,8 +9,8 @@ import (
 )
 
 func (cli *CLI) createBlockchain(address string, nodeID string) {
-	if !wallets.ValidateAddress(address) {
-		log.Panic("ERROR: Address is not valid")
+	if err := wallets.ValidateAddress(address); err != nil {
+		log.Panic("ERROR: Address is not valid: ", err)
 	}
 	bc := blockchainstruct.CreateBlockchain(address, nodeID)
 	defer bc.DB.Close()
@@ -0,0 +1,68 @@
+package cli
+
+import (
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"strings"
+
+	"github.com/cyprus09/blockchain/blockchainstruct"
+	"github.com/cyprus09/blockchain/utils"
+	"github.com/cyprus09/blockchain/wallets"
+)
+
+// writeRawTx writes ptx hex encoded to file, or prints it when file is empty
+func writeRawTx(ptx *blockchainstruct.PartialTransaction, file string) {
+	encoded := hex.EncodeToString(ptx.Serialize())
+
+	if file == "" {
+		fmt.Println(encoded)
+		return
+	}
+
+	err := utils.WriteFileAtomic(file, []byte(encoded+"\n"), 0644)
+	if err != nil {
+		log.Panic(err)
+	}
+}
+
+// readRawTx reads a hex encoded partial transaction written by writeRawTx
+func readRawTx(file string) (*blockchainstruct.PartialTransaction, error) {
+	content, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := hex.DecodeString(strings.TrimSpace(string(content)))
+	if err != nil {
+		return nil, fmt.Errorf("%s is not a hex encoded transaction: %v", file, err)
+	}
+
+	return blockchainstruct.DeserializePartialTransaction(data)
+}
+
+// createRawTx builds an unsigned transaction on the online node, where only the chain
+// and the sender's address are needed
+func (cli *CLI) createRawTx(from, to string, amount int, file, nodeID string, coinControl *blockchainstruct.CoinControl) {
+	if err := wallets.ValidateAddress(from); err != nil {
+		log.Panic("ERROR: Sender Address is not valid: ", err)
+	}
+
+	if err := wallets.ValidateAddress(to); err != nil {
+		log.Panic("ERROR: Recipient Address is not valid: ", err)
+	}
+
+	bc := openBlockchain(nodeID)
+	UTXOSet := blockchainstruct.UTXOSet{Blockchain: bc}
+	defer bc.DB.Close()
+
+	recipients := []blockchainstruct.Recipient{{Address: to, Amount: amount}}
+	coinControl.Spent = savedMempoolSpent(nodeID)
+	ptx := blockchainstruct.NewPartialTransaction(from, recipients, &UTXOSet, coinControl)
+	writeRawTx(ptx, file)
+
+	if file != "" {
+		fmt.Printf("Unsigned transaction with %d inputs and fee %d written to %s\n", len(ptx.Tx.VIn), ptx.Fee(), file)
+	}
+}
@@ -2,14 +2,58 @@ package cli
 
 import (
 	"fmt"
+	"os"
 
 	"github.com/cyprus09/blockchain/wallets"
 )
 
-func (cli *CLI) createWallet(nodeID string) {
-	wallets, _ := wallets.NewWallets(nodeID)
-	address := wallets.CreateWallet()
-	wallets.SaveToFile(nodeID)
+func (cli *CLI) createWallet(keyTypeName, nodeID string) {
+	ws, _ := wallets.NewWallets(nodeID)
+	unlockWallets(ws)
+	hadSeed := ws.HasHDSeed()
+	// keys made before the wallet had a seed are not derived from it
+	legacyKeys := 0
+	if !hadSeed {
+		legacyKeys = len(ws.GetAddresses())
+	}
+
+	if keyTypeName != "" {
+		keyType, err := wallets.ParseKeyType(keyTypeName)
+		if err != nil {
+			fmt.Printf("ERROR: %v\n", err)
+			os.Exit(1)
+		}
+
+		if hadSeed && keyType != ws.HDKeyType() {
+			fmt.Printf("ERROR: The HD seed of this wallet derives %s keys\n", ws.HDKeyType())
+			os.Exit(1)
+		}
+		if !hadSeed {
+			err = ws.NewHDSeed(keyType)
+			if err != nil {
+				fmt.Printf("ERROR: %v\n", err)
+				os.Exit(1)
+			}
+		}
+	}
+
+	address, err := ws.CreateWallet()
+	if err != nil {
+		fmt.Printf("ERROR: %v\n", err)
+		os.Exit(1)
+	}
+	ws.SaveToFile(nodeID)
+
+	if !hadSeed {
+		mnemonic, err := ws.Mnemonic()
+		if err == nil {
+			fmt.Println("A new HD seed was generated. Write down this recovery phrase, it restores every address of the wallet:")
+			fmt.Printf("\n  %s\n\n", mnemonic)
+		}
+		if legacyKeys > 0 {
+			fmt.Printf("WARNING: The recovery phrase does not restore the keys the wallet held before it (%d). Back them up with dumpwallet.\n", legacyKeys)
+		}
+	}
 
 	fmt.Printf("Your new address: %s\n", address)
-}
\ No newline at end of file
+}
@@ -0,0 +1,38 @@
+package cli
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+
+	"github.com/cyprus09/blockchain/utils"
+	"github.com/cyprus09/blockchain/wallets"
+)
+
+func (cli *CLI) dumpWallet(file, nodeID string) {
+	ws, err := wallets.NewWallets(nodeID)
+	if err != nil {
+		log.Panic(err)
+	}
+	unlockWallets(ws)
+
+	dump, err := ws.Dump()
+	if err != nil {
+		fmt.Printf("ERROR: %v\n", err)
+		os.Exit(1)
+	}
+
+	data, err := json.MarshalIndent(dump, "", "  ")
+	if err != nil {
+		log.Panic(err)
+	}
+
+	// the dump holds every private key in the clear
+	err = utils.WriteFileAtomic(file, data, 0600)
+	if err != nil {
+		log.Panic(err)
+	}
+
+	fmt.Printf("Done! Dumped %d keys to %s\n", len(dump.Keys), file)
+}
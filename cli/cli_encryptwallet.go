@@ -0,0 +1,27 @@
+package cli
+
+import (
+	"fmt"
+	"log"
+
+	"github.com/cyprus09/blockchain/wallets"
+)
+
+func (cli *CLI) encryptWallet(passphrase, nodeID string) {
+	wallets, err := wallets.NewWallets(nodeID)
+	if err != nil {
+		log.Panic(err)
+	}
+
+	if passphrase == "" {
+		passphrase = readPassphrase("New wallet passphrase: ")
+	}
+
+	err = wallets.Encrypt(passphrase)
+	if err != nil {
+		log.Panic(err)
+	}
+	wallets.SaveToFile(nodeID)
+
+	fmt.Println("Done! Wallet is encrypted. Commands that need its keys ask for the passphrase, and walletpassphrase unlocks it on a running node.")
+}
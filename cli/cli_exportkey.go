@@ -0,0 +1,37 @@
+package cli
+
+import (
+	"fmt"
+	"log"
+	"os"
+
+	"github.com/cyprus09/blockchain/wallets"
+)
+
+func (cli *CLI) exportKey(address, format, nodeID string) {
+	ws, err := wallets.NewWallets(nodeID)
+	if err != nil {
+		log.Panic(err)
+	}
+	unlockWallets(ws)
+
+	wallet, err := ws.GetWallet(address)
+	if err != nil {
+		fmt.Printf("ERROR: %v\n", err)
+		os.Exit(1)
+	}
+
+	switch format {
+	case "wif":
+		fmt.Println(wallets.EncodeWIF(wallet.PrivateKey, wallet.KeyType))
+	case "pem":
+		data, err := wallets.EncodePEM(wallet.PrivateKey, wallet.KeyType)
+		if err != nil {
+			log.Panic(err)
+		}
+		fmt.Print(string(data))
+	default:
+		fmt.Printf("ERROR: Unknown key format %q, use wif or pem\n", format)
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,67 @@
+package cli
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+
+	"github.com/cyprus09/blockchain/blockchainstruct"
+	"github.com/cyprus09/blockchain/chaincfg"
+	"github.com/cyprus09/blockchain/wallets"
+)
+
+// maxGenerateBlocks bounds the blocks a single generate mines
+const maxGenerateBlocks = 1000
+
+// checkGenerate returns why blocks cannot be generated, nil when they can. Mining on
+// demand is only meant for tests, so it is limited to regtest
+func checkGenerate(blocks int) error {
+	if chaincfg.ActiveParams() != &chaincfg.RegTestParams {
+		return fmt.Errorf("generate only works on regtest, not on %s", chaincfg.ActiveParams().Name)
+	}
+	if blocks <= 0 || blocks > maxGenerateBlocks {
+		return fmt.Errorf("blocks must be between 1 and %d", maxGenerateBlocks)
+	}
+
+	return nil
+}
+
+// generate mines blocks paying address right away on regtest and prints their hashes.
+// A running node mines them, with its mempool, otherwise they are mined on the local chain
+func (cli *CLI) generate(blocks int, address, nodeID string) {
+	if err := checkGenerate(blocks); err != nil {
+		log.Panic("ERROR: ", err)
+	}
+	if err := wallets.ValidateAddress(address); err != nil {
+		log.Panic("ERROR: Address is not valid: ", err)
+	}
+
+	var hashes []string
+
+	result, err := callRPC("generate", blocks, address)
+	if err == nil {
+		err = json.Unmarshal(result, &hashes)
+		if err != nil {
+			log.Panic(err)
+		}
+	} else if err == errNodeNotRunning {
+		bc := openBlockchain(nodeID)
+		UTXOSet := blockchainstruct.UTXOSet{Blockchain: bc}
+		defer bc.DB.Close()
+
+		for i := 0; i < blocks; i++ {
+			cbTx := blockchainstruct.NewCoinbaseTx(address, "", bc.GetBestHeight()+1)
+			newBlock := bc.MineBlock([]*blockchainstruct.Transaction{cbTx})
+			UTXOSet.Update(newBlock)
+
+			hashes = append(hashes, hex.EncodeToString(newBlock.CurrHash))
+		}
+	} else {
+		log.Panic(err)
+	}
+
+	for _, hash := range hashes {
+		fmt.Println(hash)
+	}
+}
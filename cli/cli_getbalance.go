@@ -5,23 +5,30 @@ import (
 	"log"
 
 	"github.com/cyprus09/blockchain/blockchainstruct"
-	"github.com/cyprus09/blockchain/utils"
 	"github.com/cyprus09/blockchain/wallets"
 )
 
 func (cli *CLI) getBalance(address string, nodeID string) {
-	if !wallets.ValidateAddress(address) {
-		log.Panic("ERROR: Address is not valid")
+	pubKeyHash, err := wallets.DecodeAddress(address)
+	if err != nil {
+		log.Panic("ERROR: Address is not valid: ", err)
 	}
 
-	bc := blockchainstruct.NewBlockchain(nodeID)
+	// a running node holds the database, so ask it instead
+	result, err := callRPC("getbalance", address)
+	if err == nil {
+		fmt.Printf("Balance of '%s': %s\n", address, result)
+		return
+	} else if err != errNodeNotRunning {
+		log.Panic(err)
+	}
+
+	bc := openBlockchain(nodeID)
 	UTXOSet := blockchainstruct.UTXOSet{Blockchain: bc}
 	defer bc.DB.Close()
 
 	balance := 0
 
-	pubKeyHash := utils.Base58Decode([]byte(address))
-	pubKeyHash = pubKeyHash[1 : len(pubKeyHash)-4]
 	UTXOs := UTXOSet.FindUTXO(pubKeyHash)
 
 	for _, out := range UTXOs {
@@ -0,0 +1,26 @@
+package cli
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/cyprus09/blockchain/wallets"
+)
+
+// importAddress adds a watch-only address to the wallet
+func (cli *CLI) importAddress(address string, rescanChain bool, nodeID string) {
+	ws, _ := wallets.NewWallets(nodeID)
+
+	err := ws.AddWatchOnly(address)
+	if err != nil {
+		fmt.Printf("ERROR: %v\n", err)
+		os.Exit(1)
+	}
+
+	ws.SaveToFile(nodeID)
+	fmt.Printf("Watching address: %s\n", address)
+
+	if rescanChain {
+		rescan([]string{address}, nodeID)
+	}
+}
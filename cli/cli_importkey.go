@@ -0,0 +1,47 @@
+package cli
+
+import (
+	"crypto/ecdsa"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+
+	"github.com/cyprus09/blockchain/wallets"
+)
+
+func (cli *CLI) importKey(wif, pemFile string, rescanChain bool, nodeID string) {
+	var privKey ecdsa.PrivateKey
+	var keyType wallets.KeyType
+	var err error
+
+	if wif != "" {
+		privKey, keyType, err = wallets.DecodeWIF(wif)
+	} else {
+		var data []byte
+		data, err = ioutil.ReadFile(pemFile)
+		if err != nil {
+			log.Panic(err)
+		}
+		privKey, keyType, err = wallets.DecodePEM(data)
+	}
+	if err != nil {
+		fmt.Printf("ERROR: %v\n", err)
+		os.Exit(1)
+	}
+
+	ws, _ := wallets.NewWallets(nodeID)
+	unlockWallets(ws)
+	address, err := ws.ImportKey(privKey, keyType)
+	if err != nil {
+		fmt.Printf("ERROR: %v\n", err)
+		os.Exit(1)
+	}
+
+	ws.SaveToFile(nodeID)
+	fmt.Printf("Imported address: %s\n", address)
+
+	if rescanChain {
+		rescan([]string{address}, nodeID)
+	}
+}
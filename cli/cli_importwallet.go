@@ -0,0 +1,40 @@
+package cli
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+
+	"github.com/cyprus09/blockchain/wallets"
+)
+
+func (cli *CLI) importWallet(file string, rescanChain bool, nodeID string) {
+	data, err := ioutil.ReadFile(file)
+	if err != nil {
+		log.Panic(err)
+	}
+
+	var dump wallets.WalletDump
+	err = json.Unmarshal(data, &dump)
+	if err != nil {
+		fmt.Printf("ERROR: %s is not a wallet dump: %v\n", file, err)
+		os.Exit(1)
+	}
+
+	ws, _ := wallets.NewWallets(nodeID)
+	unlockWallets(ws)
+	addresses, err := ws.ImportDump(&dump)
+	if err != nil {
+		fmt.Printf("ERROR: %v\n", err)
+		os.Exit(1)
+	}
+
+	ws.SaveToFile(nodeID)
+	fmt.Printf("Done! Imported %d keys from %s\n", len(addresses), file)
+
+	if rescanChain {
+		rescan(addresses, nodeID)
+	}
+}
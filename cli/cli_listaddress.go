@@ -7,15 +7,27 @@ import (
 	"github.com/cyprus09/blockchain/wallets"
 )
 
-func (cli *CLI) listAddresses(nodeID string) {
-	wallets, err := wallets.NewWallets(nodeID)
+func (cli *CLI) listAddresses(bech32 bool, nodeID string) {
+	ws, err := wallets.NewWallets(nodeID)
 	if err != nil {
 		log.Panic(err)
 	}
 
-	addresses := wallets.GetAddresses()
+	addresses := ws.GetAddresses()
 
 	for _, address := range addresses {
-		fmt.Println(address)
+		if bech32 {
+			fmt.Println(ws.Wallets[address].GetBech32Address())
+		} else {
+			fmt.Println(address)
+		}
+	}
+
+	for _, address := range ws.GetWatchOnlyAddresses() {
+		if bech32 {
+			pubKeyHash, _ := wallets.DecodeAddress(address)
+			address = wallets.EncodeBech32Address(pubKeyHash)
+		}
+		fmt.Printf("%s (watch-only)\n", address)
 	}
 }
@@ -0,0 +1,118 @@
+package cli
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"sort"
+	"time"
+
+	"github.com/cyprus09/blockchain/blockchainstruct"
+	"github.com/cyprus09/blockchain/wallets"
+)
+
+// defaultTxCount is how many history entries listtransactions shows by default
+const defaultTxCount = 10
+
+// txEntryJSON is a wallet history entry, the amount one address received or sent in a transaction
+type txEntryJSON struct {
+	Time          int64  `json:"time"`
+	Category      string `json:"category"`
+	Address       string `json:"address"`
+	Amount        int    `json:"amount"`
+	Confirmations int    `json:"confirmations"`
+	Height        int    `json:"height"`
+	TxID          string `json:"txid"`
+}
+
+// walletEntries returns the history of the synced wallet newest first, one entry per
+// address and direction, limited to address when it is set
+func walletEntries(bc *blockchainstruct.Blockchain, ws *wallets.Wallets, address string) []txEntryJSON {
+	bestHeight := bc.GetBestHeight()
+	entries := []txEntryJSON{}
+
+	for _, wtx := range ws.History().Transactions(address) {
+		confirmations := bestHeight - wtx.Height + 1
+		txID := hex.EncodeToString(wtx.TxID)
+		category := "receive"
+		if wtx.Coinbase {
+			category = "generate"
+		}
+
+		for _, entry := range sortedEntries(wtx.Received, address) {
+			entries = append(entries, txEntryJSON{wtx.Time, category, entry, wtx.Received[entry], confirmations, wtx.Height, txID})
+		}
+		for _, entry := range sortedEntries(wtx.Sent, address) {
+			entries = append(entries, txEntryJSON{wtx.Time, "send", entry, -wtx.Sent[entry], confirmations, wtx.Height, txID})
+		}
+	}
+
+	return entries
+}
+
+// pageEntries returns the count entries that follow the skip first ones
+func pageEntries(entries []txEntryJSON, count, skip int) []txEntryJSON {
+	if skip > len(entries) {
+		skip = len(entries)
+	}
+	end := skip + count
+	if end > len(entries) {
+		end = len(entries)
+	}
+
+	return entries[skip:end]
+}
+
+// listTransactions prints the wallet history newest first, one line per address and direction
+func (cli *CLI) listTransactions(address string, count, skip int, nodeID string) {
+	var entries []txEntryJSON
+
+	// a running node holds the database, so ask it instead
+	result, err := callRPC("listtransactions", address, count, skip)
+	if err == nil {
+		err = json.Unmarshal(result, &entries)
+		if err != nil {
+			log.Panic(err)
+		}
+	} else if err != errNodeNotRunning {
+		log.Panic(err)
+	} else {
+		ws, err := wallets.NewWallets(nodeID)
+		if err != nil {
+			log.Panic(err)
+		}
+
+		bc := openBlockchain(nodeID)
+		defer bc.DB.Close()
+
+		syncWallet(bc, ws)
+		ws.SaveToFile(nodeID)
+		entries = pageEntries(walletEntries(bc, ws, address), count, skip)
+	}
+
+	if len(entries) == 0 {
+		fmt.Println("No transactions.")
+		return
+	}
+
+	for _, entry := range entries {
+		when := time.Unix(entry.Time, 0).Format(time.RFC3339)
+		fmt.Printf("%s  %-8s %s  %+d  confirmations: %d  height: %d  tx: %s\n", when, entry.Category, entry.Address, entry.Amount, entry.Confirmations, entry.Height, entry.TxID)
+	}
+	fmt.Printf("Showing entries %d-%d\n", skip+1, skip+len(entries))
+}
+
+// sortedEntries returns the addresses of an amount map, limited to address when it is set
+func sortedEntries(amounts map[string]int, address string) []string {
+	var entries []string
+
+	for entry := range amounts {
+		if address == "" || entry == address {
+			entries = append(entries, entry)
+		}
+	}
+	sort.Strings(entries)
+
+	return entries
+}
@@ -0,0 +1,45 @@
+package cli
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+
+	"github.com/cyprus09/blockchain/blockchainstruct"
+	"github.com/cyprus09/blockchain/wallets"
+)
+
+// listUnspent prints every unspent output locked to the address
+func (cli *CLI) listUnspent(address string, nodeID string) {
+	pubKeyHash, err := wallets.DecodeAddress(address)
+	if err != nil {
+		log.Panic("ERROR: Address is not valid: ", err)
+	}
+
+	var utxos []utxoJSON
+
+	// a running node holds the database, so ask it instead
+	result, err := callRPC("listunspent", address)
+	if err == nil {
+		err = json.Unmarshal(result, &utxos)
+		if err != nil {
+			log.Panic(err)
+		}
+	} else if err != errNodeNotRunning {
+		log.Panic(err)
+	} else {
+		bc := openBlockchain(nodeID)
+		UTXOSet := blockchainstruct.UTXOSet{Blockchain: bc}
+		defer bc.DB.Close()
+
+		for _, utxo := range UTXOSet.ListUnspent(pubKeyHash) {
+			utxos = append(utxos, utxoJSON{hex.EncodeToString(utxo.TxID), utxo.VOut, utxo.Value, utxo.Height})
+		}
+	}
+
+	for _, utxo := range utxos {
+		fmt.Printf("%s:%d  value: %d  height: %d\n", utxo.TxID, utxo.VOut, utxo.Value, utxo.Height)
+	}
+	fmt.Printf("%d unspent outputs for '%s'\n", len(utxos), address)
+}
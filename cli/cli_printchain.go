@@ -10,7 +10,7 @@ import (
 // printChain iterates through the entire blockchain starting from the tip all the way to the start and prints the values
 func (cli *CLI) printChain(nodeID string) {
 
-	bc := blockchainstruct.NewBlockchain(nodeID)
+	bc := openBlockchain(nodeID)
 	defer bc.DB.Close()
 
 	bci := bc.Iterator()
@@ -6,11 +6,19 @@ import (
 	"github.com/cyprus09/blockchain/blockchainstruct"
 )
 
-func (cli *CLI) reindexUTXO(nodeID string) {
-	bc := blockchainstruct.NewBlockchain(nodeID)
+func (cli *CLI) reindexUTXO(nodeID string, addrIndex bool) {
+	bc := openBlockchain(nodeID)
 	UTXOSet := blockchainstruct.UTXOSet{Blockchain: bc}
+	defer bc.DB.Close()
+
+	if addrIndex {
+		UTXOSet.EnableAddrIndex()
+	}
 	UTXOSet.Reindex()
 
 	count := UTXOSet.CountTransactions()
 	fmt.Printf("Done! There are %d transactions in the UTXO set.\n", count)
-}
\ No newline at end of file
+	if UTXOSet.HasAddrIndex() {
+		fmt.Println("Address index is enabled.")
+	}
+}
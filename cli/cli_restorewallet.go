@@ -0,0 +1,97 @@
+package cli
+
+import (
+	"encoding/hex"
+	"fmt"
+	"log"
+	"os"
+
+	"github.com/cyprus09/blockchain/blockchainstruct"
+	"github.com/cyprus09/blockchain/wallets"
+)
+
+// usedPubKeyHashes returns every pubkey hash that ever received an output on the chain
+func usedPubKeyHashes(bc *blockchainstruct.Blockchain) map[string]bool {
+	used := make(map[string]bool)
+	bci := bc.Iterator()
+
+	for {
+		block := bci.Next()
+
+		for _, tx := range block.Transactions {
+			for _, out := range tx.VOut {
+				used[hex.EncodeToString(out.PubKeyHash)] = true
+			}
+		}
+
+		if len(block.PrevBlockHash) == 0 {
+			break
+		}
+	}
+
+	return used
+}
+
+// restoreWallet rebuilds an HD wallet from its mnemonic. Addresses are derived until gapLimit
+// of them in a row have never been used on the chain
+func (cli *CLI) restoreWallet(mnemonic, keyTypeName string, gapLimit int, nodeID string) {
+	keyType, err := wallets.ParseKeyType(keyTypeName)
+	if err != nil {
+		fmt.Printf("ERROR: %v\n", err)
+		os.Exit(1)
+	}
+
+	ws, err := wallets.NewWallets(nodeID)
+	if err == nil && len(ws.Wallets) > 0 {
+		fmt.Println("ERROR: A wallet file already exists for this node, move it away before restoring.")
+		os.Exit(1)
+	}
+
+	err = ws.SetMnemonic(mnemonic, keyType)
+	if err != nil {
+		fmt.Printf("ERROR: %v\n", err)
+		os.Exit(1)
+	}
+
+	bc := openBlockchain(nodeID)
+	UTXOSet := blockchainstruct.UTXOSet{Blockchain: bc}
+	defer bc.DB.Close()
+
+	used := usedPubKeyHashes(bc)
+	count := 0
+
+	for index, gap := uint32(0), 0; gap < gapLimit; index++ {
+		wallet, err := ws.DeriveWallet(index)
+		if err != nil {
+			log.Panic(err)
+		}
+
+		if used[hex.EncodeToString(wallet.HashPubKey(wallet.PublicKey))] {
+			count = int(index) + 1
+			gap = 0
+		} else {
+			gap++
+		}
+	}
+	if count == 0 {
+		count = 1
+	}
+
+	for i := 0; i < count; i++ {
+		address, err := ws.CreateWallet()
+		if err != nil {
+			log.Panic(err)
+		}
+
+		wallet := ws.Wallets[address]
+		balance := 0
+		for _, utxo := range UTXOSet.ListUnspent(wallet.HashPubKey(wallet.PublicKey)) {
+			balance += utxo.Value
+		}
+		fmt.Printf("%s  balance: %d\n", address, balance)
+	}
+	syncWallet(bc, ws)
+	ws.SaveToFile(nodeID)
+
+	fmt.Printf("Done! Restored %d addresses.\n", count)
+}
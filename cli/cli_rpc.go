@@ -0,0 +1,35 @@
+package cli
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log"
+)
+
+// rpc calls method on the running node. Every param that is valid JSON is sent as it is,
+// anything else as a string
+func (cli *CLI) rpc(method string, args []string) {
+	params := []interface{}{}
+
+	for _, arg := range args {
+		if json.Valid([]byte(arg)) {
+			params = append(params, json.RawMessage(arg))
+		} else {
+			params = append(params, arg)
+		}
+	}
+
+	result, err := callRPC(method, params...)
+	if err != nil {
+		log.Panic(err)
+	}
+
+	var out bytes.Buffer
+	err = json.Indent(&out, result, "", "  ")
+	if err != nil {
+		log.Panic(err)
+	}
+
+	fmt.Println(out.String())
+}
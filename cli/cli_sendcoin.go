@@ -1,20 +1,40 @@
 package cli
 
 import (
+	"encoding/json"
 	"fmt"
 	"log"
+	"os"
 
 	"github.com/cyprus09/blockchain/blockchainstruct"
 	"github.com/cyprus09/blockchain/wallets"
 )
 
-func (cli *CLI) sendCoin(from, to string, amount int, nodeID string, mineNow bool) {
-	if !wallets.ValidateAddress(from) {
-		log.Panic("ERROR: Sender Address is not valid")
+// parseCoinControl builds the coin control options of sendcoin from its flags
+func parseCoinControl(strategy string, dustThreshold int, inputs string) *blockchainstruct.CoinControl {
+	selector, err := blockchainstruct.NewCoinSelector(strategy, dustThreshold)
+	if err != nil {
+		log.Panic(err)
 	}
+	coinControl := &blockchainstruct.CoinControl{Selector: selector, DustThreshold: dustThreshold}
 
-	if !wallets.ValidateAddress(to) {
-		log.Panic("ERROR: Recipient Address is not valid")
+	if inputs != "" {
+		coinControl.Outpoints, err = blockchainstruct.ParseOutpoints(inputs)
+		if err != nil {
+			log.Panic(err)
+		}
+	}
+
+	return coinControl
+}
+
+func (cli *CLI) sendCoin(from, to string, amount int, nodeID string, mineNow bool, coinControl *blockchainstruct.CoinControl) {
+	if err := wallets.ValidateAddress(from); err != nil {
+		log.Panic("ERROR: Sender Address is not valid: ", err)
+	}
+
+	if err := wallets.ValidateAddress(to); err != nil {
+		log.Panic("ERROR: Recipient Address is not valid: ", err)
 	}
 
 	if to == from {
@@ -23,27 +43,58 @@ func (cli *CLI) sendCoin(from, to string, amount int, nodeID string, mineNow boo
 		log.Panic("ERROR: You cannot send coins to yourself")
 	}
 
-	bc := blockchainstruct.NewBlockchain(nodeID)
+	wallets, err := wallets.NewWallets(nodeID)
+	if err != nil {
+		log.Panic(err)
+	}
+	unlockWallets(wallets)
+	wallet, err := wallets.GetWallet(from)
+	if err != nil {
+		fmt.Printf("ERROR: %v\n", err)
+		os.Exit(1)
+	}
+
+	bc := openBlockchain(nodeID)
 	UTXOSet := blockchainstruct.UTXOSet{Blockchain: bc}
 	defer bc.DB.Close()
 
-	wallets, err := wallets.NewWallets(nodeID)
+	coinControl.Spent = savedMempoolSpent(nodeID)
+	tx := blockchainstruct.NewUTXOTTransaction(&wallet, to, amount, &UTXOSet, coinControl)
+	submitTx(tx, from, &UTXOSet, mineNow)
+
+	fmt.Printf("Success sent %d coins from %s to %s\n", amount, from, to)
+}
+
+// sendCoinRPC has the running node create and relay the transaction, with the coin
+// control flags of sendcoin
+func (cli *CLI) sendCoinRPC(from, to string, amount int, strategy string, dustThreshold int, inputs string) {
+	result, err := callRPC("sendtoaddress", from, to, amount, strategy, dustThreshold, inputs)
+	if err != nil {
+		fmt.Printf("ERROR: %v\n", err)
+		os.Exit(1)
+	}
+
+	var txID string
+	err = json.Unmarshal(result, &txID)
 	if err != nil {
 		log.Panic(err)
 	}
-	wallet := wallets.GetWallet(from)
 
-	tx := blockchainstruct.NewUTXOTTransaction(&wallet, to, amount, &UTXOSet)
+	fmt.Printf("Success sent %d coins from %s to %s in transaction %s\n", amount, from, to, txID)
+}
 
+// submitTx mines tx into a new block on this node when mineNow is set, and hands it to the network otherwise
+func submitTx(tx *blockchainstruct.Transaction, from string, UTXOSet *blockchainstruct.UTXOSet, mineNow bool) {
 	if mineNow {
-		cbTx := blockchainstruct.NewCoinbaseTx(from, "")
+		cbTx := blockchainstruct.NewCoinbaseTx(from, "", UTXOSet.Blockchain.GetBestHeight()+1)
 		txs := []*blockchainstruct.Transaction{cbTx, tx}
 
-		newBlock := bc.MineBlock(txs)
+		newBlock := UTXOSet.Blockchain.MineBlock(txs)
 		UTXOSet.Update(newBlock)
 	} else {
-		sendTx(knownNodes[0], tx)
+		for _, seed := range nodeConfig.seeds() {
+			sendTx(seed, tx)
+		}
+		flushOutbox()
 	}
-
-	fmt.Printf("Success sent %d coins from %s to %s\n", amount, from, to)
 }
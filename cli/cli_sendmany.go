@@ -0,0 +1,147 @@
+package cli
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/cyprus09/blockchain/blockchainstruct"
+	"github.com/cyprus09/blockchain/wallets"
+)
+
+// readRecipients loads the payments of a sendmany call. JSON files hold a list of
+// {"address": ..., "amount": ...} objects, anything else is read as CSV with address,amount rows
+func readRecipients(file string) ([]blockchainstruct.Recipient, error) {
+	var recipients []blockchainstruct.Recipient
+
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	if strings.EqualFold(filepath.Ext(file), ".json") {
+		err = json.NewDecoder(f).Decode(&recipients)
+		return recipients, err
+	}
+
+	reader := csv.NewReader(f)
+	reader.FieldsPerRecord = 2
+	reader.TrimLeadingSpace = true
+
+	for line := 1; ; line++ {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		amount, err := strconv.Atoi(strings.TrimSpace(record[1]))
+		if err != nil {
+			// the first row may be a header
+			if line == 1 {
+				continue
+			}
+			return nil, fmt.Errorf("line %d: invalid amount %q", line, record[1])
+		}
+		recipients = append(recipients, blockchainstruct.Recipient{Address: strings.TrimSpace(record[0]), Amount: amount})
+	}
+
+	return recipients, nil
+}
+
+// checkRecipients validates the payments of a sendmany call from the address from and
+// returns their total
+func checkRecipients(from string, recipients []blockchainstruct.Recipient) (int, error) {
+	if len(recipients) == 0 {
+		return 0, errors.New("no recipients")
+	}
+
+	total := 0
+	for _, recipient := range recipients {
+		if err := wallets.ValidateAddress(recipient.Address); err != nil {
+			return 0, fmt.Errorf("recipient address %s is not valid: %v", recipient.Address, err)
+		}
+		if recipient.Address == from {
+			return 0, errors.New("you cannot send coins to yourself")
+		}
+		if recipient.Amount <= 0 {
+			return 0, fmt.Errorf("amount for %s must be positive", recipient.Address)
+		}
+		total += recipient.Amount
+	}
+
+	return total, nil
+}
+
+func (cli *CLI) sendMany(from, file string, nodeID string, mineNow bool, coinControl *blockchainstruct.CoinControl) {
+	if err := wallets.ValidateAddress(from); err != nil {
+		log.Panic("ERROR: Sender Address is not valid: ", err)
+	}
+
+	recipients, err := readRecipients(file)
+	if err != nil {
+		log.Panic(err)
+	}
+	total, err := checkRecipients(from, recipients)
+	if err != nil {
+		log.Panic("ERROR: ", err)
+	}
+
+	wallets, err := wallets.NewWallets(nodeID)
+	if err != nil {
+		log.Panic(err)
+	}
+	unlockWallets(wallets)
+	wallet, err := wallets.GetWallet(from)
+	if err != nil {
+		fmt.Printf("ERROR: %v\n", err)
+		os.Exit(1)
+	}
+
+	bc := openBlockchain(nodeID)
+	UTXOSet := blockchainstruct.UTXOSet{Blockchain: bc}
+	defer bc.DB.Close()
+
+	coinControl.Spent = savedMempoolSpent(nodeID)
+	tx := blockchainstruct.NewBatchTransaction(&wallet, recipients, &UTXOSet, coinControl)
+	submitTx(tx, from, &UTXOSet, mineNow)
+
+	fmt.Printf("Success sent %d coins from %s to %d recipients in transaction %x\n", total, from, len(recipients), tx.ID)
+}
+
+// sendManyRPC has the running node create and relay the transaction paying the recipients
+// of file, with the coin control flags of sendmany
+func (cli *CLI) sendManyRPC(from, file string, strategy string, dustThreshold int, inputs string) {
+	recipients, err := readRecipients(file)
+	if err != nil {
+		log.Panic(err)
+	}
+
+	result, err := callRPC("sendmany", from, recipients, strategy, dustThreshold, inputs)
+	if err != nil {
+		fmt.Printf("ERROR: %v\n", err)
+		os.Exit(1)
+	}
+
+	var txID string
+	err = json.Unmarshal(result, &txID)
+	if err != nil {
+		log.Panic(err)
+	}
+
+	total := 0
+	for _, recipient := range recipients {
+		total += recipient.Amount
+	}
+	fmt.Printf("Success sent %d coins from %s to %d recipients in transaction %s\n", total, from, len(recipients), txID)
+}
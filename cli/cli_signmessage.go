@@ -0,0 +1,32 @@
+package cli
+
+import (
+	"fmt"
+	"log"
+	"os"
+
+	"github.com/cyprus09/blockchain/wallets"
+)
+
+// signMessage proves control of address by signing message with its key
+func (cli *CLI) signMessage(address, message, nodeID string) {
+	ws, err := wallets.NewWallets(nodeID)
+	if err != nil {
+		log.Panic(err)
+	}
+	unlockWallets(ws)
+
+	wallet, err := ws.GetWallet(address)
+	if err != nil {
+		fmt.Printf("ERROR: %v\n", err)
+		os.Exit(1)
+	}
+
+	signature, err := wallet.SignMessage(message)
+	if err != nil {
+		fmt.Printf("ERROR: %v\n", err)
+		os.Exit(1)
+	}
+
+	fmt.Println(signature)
+}
@@ -0,0 +1,52 @@
+package cli
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/cyprus09/blockchain/wallets"
+)
+
+// signRawTx signs a transaction of createrawtx with the wallet file alone, for nodes
+// that keep their keys offline and have no chain
+func (cli *CLI) signRawTx(in, out, nodeID string) {
+	ptx, err := readRawTx(in)
+	if err != nil {
+		fmt.Printf("ERROR: %v\n", err)
+		os.Exit(1)
+	}
+
+	ws, err := wallets.NewWallets(nodeID)
+	if err != nil {
+		fmt.Printf("ERROR: %v\n", err)
+		os.Exit(1)
+	}
+
+	err = ptx.CheckInputs()
+	if err != nil {
+		fmt.Printf("ERROR: %v\n", err)
+		os.Exit(1)
+	}
+
+	for i, utxo := range ptx.Inputs {
+		fmt.Printf("Input %d  : %x:%d  %d from %s\n", i, utxo.TxID, utxo.VOut, utxo.Value, wallets.EncodeAddress(utxo.PubKeyHash))
+	}
+	for i, output := range ptx.Tx.VOut {
+		fmt.Printf("Output %d : %d to %s\n", i, output.Value, wallets.EncodeAddress(output.PubKeyHash))
+	}
+	fmt.Printf("Fee      : %d\n", ptx.Fee())
+	unlockWallets(ws)
+
+	err = ptx.Sign(ws)
+	if err != nil {
+		fmt.Printf("ERROR: %v\n", err)
+		os.Exit(1)
+	}
+
+	if out == "" {
+		out = in
+	}
+	writeRawTx(ptx, out)
+
+	fmt.Printf("Signed transaction %x written to %s\n", ptx.Tx.ID, out)
+}
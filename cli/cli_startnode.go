@@ -1,20 +1,25 @@
 package cli
 
 import (
-	"fmt"
 	"log"
+	"os"
 
+	"github.com/cyprus09/blockchain/logging"
 	"github.com/cyprus09/blockchain/wallets"
 )
 
-func (cli *CLI) startNode(nodeID, minerAddess string) {
-	fmt.Printf("Starting node %s\n", nodeID)
-	if len(minerAddess) > 0 {
-		if wallets.ValidateAddress(minerAddess) {
-			fmt.Println("Mining is on. Address to recieve rewards: ", minerAddess)
-		}else {
-			log.Panic("Wrong miner address!")
+func (cli *CLI) startNode(nodeID string, config *Config) {
+	if err := logging.Configure(os.Stderr, config.LogLevel, config.LogFormat); err != nil {
+		log.Panic(err)
+	}
+
+	logging.P2P.Info("starting node", "id", nodeID, "listen", config.Listen, "external", config.externalAddr(), "seeds", config.seeds())
+	if len(config.MiningAddr) > 0 {
+		if err := wallets.ValidateAddress(config.MiningAddr); err == nil {
+			logging.Miner.Info("mining is on", "address", config.MiningAddr)
+		} else {
+			log.Panic("Wrong miner address! ", err)
 		}
 	}
-	StartServer(nodeID, minerAddess)
-}
\ No newline at end of file
+	StartServer(nodeID, config)
+}
@@ -0,0 +1,19 @@
+package cli
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/cyprus09/blockchain/wallets"
+)
+
+// verifyMessage checks a signature of signmessage against address
+func (cli *CLI) verifyMessage(address, signature, message string) {
+	err := wallets.VerifyMessage(address, signature, message)
+	if err != nil {
+		fmt.Printf("Signature is NOT valid: %v\n", err)
+		os.Exit(1)
+	}
+
+	fmt.Println("Signature is valid")
+}
@@ -0,0 +1,16 @@
+package cli
+
+import (
+	"fmt"
+	"os"
+)
+
+func (cli *CLI) walletLock() {
+	_, err := callRPC("walletlock")
+	if err != nil && err != errNodeNotRunning {
+		fmt.Printf("ERROR: %v\n", err)
+		os.Exit(1)
+	}
+
+	fmt.Println("Wallet locked.")
+}
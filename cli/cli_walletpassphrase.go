@@ -0,0 +1,46 @@
+package cli
+
+import (
+	"bufio"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+)
+
+// readPassphrase prompts for a passphrase on stdin when it wasn't passed as a flag
+func readPassphrase(prompt string) string {
+	fmt.Print(prompt)
+
+	line, err := bufio.NewReader(os.Stdin).ReadString('\n')
+	if err != nil && line == "" {
+		log.Panic(err)
+	}
+
+	passphrase := strings.TrimRight(line, "\r\n")
+	if passphrase == "" {
+		fmt.Println("ERROR: The passphrase must not be empty")
+		os.Exit(1)
+	}
+
+	return passphrase
+}
+
+// walletPassphrase has the running node keep the wallet unlocked in its memory until timeout
+func (cli *CLI) walletPassphrase(passphrase string, timeout int) {
+	if passphrase == "" {
+		passphrase = readPassphrase("Wallet passphrase: ")
+	}
+
+	_, err := callRPC("walletpassphrase", passphrase, timeout)
+	if err == errNodeNotRunning {
+		fmt.Println("ERROR: walletpassphrase unlocks the wallet of a running node. Without one, commands ask for the passphrase when they need the keys.")
+		os.Exit(1)
+	}
+	if err != nil {
+		fmt.Printf("ERROR: %v\n", err)
+		os.Exit(1)
+	}
+
+	fmt.Printf("Wallet unlocked for %d seconds.\n", timeout)
+}
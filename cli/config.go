@@ -0,0 +1,159 @@
+package cli
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/cyprus09/blockchain/chaincfg"
+	"github.com/cyprus09/blockchain/utils"
+)
+
+// Config holds the settings of a node. They are read from a JSON config file and
+// can be overridden by command line flags
+type Config struct {
+	// DataDir holds the chain, wallet and node files, the working directory when empty
+	DataDir string `json:"datadir"`
+	// Network picks the address format: mainnet, testnet or regtest
+	Network string `json:"network"`
+	// Listen is the address the node accepts peers on
+	Listen string `json:"listen"`
+	// ExternalAddr is the address the node gives its peers, Listen when empty
+	ExternalAddr string `json:"externaladdr"`
+	// Seeds are the peers the node connects to when it has none, on startup or after
+	// losing them all. When unset the default seed of the network is used
+	Seeds []string `json:"seeds"`
+	// MiningAddr, when set, makes the node mine and receive the rewards
+	MiningAddr string `json:"miningaddr"`
+	// RPCListen is the address of the RPC server, also used by the CLI to reach the node
+	RPCListen string `json:"rpclisten"`
+	// RPCUser and RPCPassword are required from RPC clients. When both are empty the
+	// node makes up a password and writes it to a cookie file the CLI reads
+	RPCUser     string `json:"rpcuser"`
+	RPCPassword string `json:"rpcpassword"`
+	LogLevel    string `json:"loglevel"`
+	LogFormat   string `json:"logformat"`
+
+	nodeID string
+}
+
+// nodeConfig is the configuration of the command being run
+var nodeConfig = defaultConfig("3000")
+
+// defaultConfig returns the settings a node gets without config file or flags
+func defaultConfig(nodeID string) *Config {
+	return &Config{
+		Listen:    fmt.Sprintf("localhost:%s", nodeID),
+		RPCListen: rpcAddress(nodeID),
+		LogLevel:  "info",
+		LogFormat: "text",
+		nodeID:    nodeID,
+	}
+}
+
+// LoadConfig returns the default settings of nodeID overridden by those of the JSON
+// config file at path. An empty path loads no file
+func LoadConfig(path, nodeID string) (*Config, error) {
+	config := defaultConfig(nodeID)
+	if path == "" {
+		return config, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+	err = decoder.Decode(config)
+	if err != nil {
+		return nil, fmt.Errorf("invalid config file %s: %v", path, err)
+	}
+
+	return config, nil
+}
+
+// externalAddr returns the address the node gives its peers
+func (c *Config) externalAddr() string {
+	if c.ExternalAddr != "" {
+		return c.ExternalAddr
+	}
+
+	return c.Listen
+}
+
+// cookiePath returns the file holding the RPC credentials of a node run without an RPC
+// user and password
+func (c *Config) cookiePath() string {
+	return utils.DataPath(fmt.Sprintf(rpcCookieFile, c.nodeID))
+}
+
+// seeds returns the configured seeds, or the default seed node of the active network
+func (c *Config) seeds() []string {
+	if c.Seeds == nil {
+		return []string{fmt.Sprintf("localhost:%s", chaincfg.ActiveParams().DefaultPort)}
+	}
+
+	return c.Seeds
+}
+
+// addConfigFlags defines on fs the flags of the settings every command shares
+func addConfigFlags(fs *flag.FlagSet) {
+	fs.String("config", "", "JSON config file")
+	fs.String("datadir", "", "Directory of the chain, wallet and node files")
+	fs.String("network", "", "Network: mainnet, testnet or regtest")
+	fs.String("rpcaddr", "", "Address of the RPC server")
+	fs.String("rpcuser", "", "User required from RPC clients")
+	fs.String("rpcpassword", "", "Password required from RPC clients")
+}
+
+// addNodeFlags defines on fs the flags of the settings only a running node uses
+func addNodeFlags(fs *flag.FlagSet) {
+	fs.String("listen", "", "Address to accept peers on")
+	fs.String("externaladdr", "", "Address to give peers, the listen address when empty")
+	fs.String("seeds", "", "Comma separated peers to connect to on startup")
+	fs.String("miner", "", "Enable mining node and send reward to <address>")
+	fs.String("loglevel", "", "Lowest level to log: debug, info, warn or error")
+	fs.String("logformat", "", "Log format: text or json")
+}
+
+// applyFlags overrides the settings with the flags set on fs
+func (c *Config) applyFlags(fs *flag.FlagSet) {
+	fs.Visit(func(f *flag.Flag) {
+		value := f.Value.String()
+
+		switch f.Name {
+		case "datadir":
+			c.DataDir = value
+		case "network":
+			c.Network = value
+		case "rpcaddr":
+			c.RPCListen = value
+		case "rpcuser":
+			c.RPCUser = value
+		case "rpcpassword":
+			c.RPCPassword = value
+		case "listen":
+			c.Listen = value
+		case "externaladdr":
+			c.ExternalAddr = value
+		case "seeds":
+			c.Seeds = []string{}
+			for _, seed := range strings.Split(value, ",") {
+				if seed = strings.TrimSpace(seed); seed != "" {
+					c.Seeds = append(c.Seeds, seed)
+				}
+			}
+		case "miner":
+			c.MiningAddr = value
+		case "loglevel":
+			c.LogLevel = value
+		case "logformat":
+			c.LogFormat = value
+		}
+	})
+}
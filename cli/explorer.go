@@ -0,0 +1,483 @@
+package cli
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/cyprus09/blockchain/blockchainstruct"
+	"github.com/cyprus09/blockchain/logging"
+	"github.com/cyprus09/blockchain/wallets"
+)
+
+const (
+	explorerPrefix       = "/rest/"
+	explorerDefaultLimit = 20
+	explorerMaxLimit     = 100
+)
+
+// explorer serves read-only JSON views of the chain and mempool under /rest/
+type explorer struct {
+	bc *blockchainstruct.Blockchain
+}
+
+// pageJSON is the envelope of every paginated list
+type pageJSON struct {
+	Total  int         `json:"total"`
+	Offset int         `json:"offset"`
+	Limit  int         `json:"limit"`
+	Items  interface{} `json:"items"`
+}
+
+// blockDetailJSON is a block with its transactions in full
+type blockDetailJSON struct {
+	blockJSON
+	Transactions []txJSON `json:"transactions"`
+}
+
+// txDetailJSON is a transaction with the block that confirmed it
+type txDetailJSON struct {
+	txJSON
+	BlockHash     string `json:"blockhash,omitempty"`
+	Height        int    `json:"height"`
+	Confirmations int    `json:"confirmations"`
+}
+
+// addressJSON is the summary of an address
+type addressJSON struct {
+	Address string `json:"address"`
+	Balance int    `json:"balance"`
+	Unspent int    `json:"unspent"`
+}
+
+// addressTxJSON is a transaction of the history of an address
+type addressTxJSON struct {
+	TxID      string `json:"txid"`
+	BlockHash string `json:"blockhash"`
+	Height    int    `json:"height"`
+	Time      int64  `json:"time"`
+	Received  int    `json:"received"`
+	Sent      int    `json:"sent"`
+}
+
+// utxoJSON is an unspent output
+type utxoJSON struct {
+	TxID   string `json:"txid"`
+	VOut   int    `json:"vout"`
+	Value  int    `json:"value"`
+	Height int    `json:"height"`
+}
+
+// chainStatsJSON summarizes the chain and the mempool
+type chainStatsJSON struct {
+	Height        int    `json:"height"`
+	BestBlockHash string `json:"bestblockhash"`
+	TipTime       int64  `json:"tiptime"`
+	MempoolSize   int    `json:"mempoolsize"`
+	MempoolBytes  int    `json:"mempoolbytes"`
+	UTXOTxCount   int    `json:"utxotransactions"`
+}
+
+// httpError is an error answered with a status code
+type httpError struct {
+	status  int
+	message string
+}
+
+func (e *httpError) Error() string {
+	return e.message
+}
+
+func notFound(format string, a ...interface{}) error {
+	return &httpError{http.StatusNotFound, fmt.Sprintf(format, a...)}
+}
+
+func badRequest(format string, a ...interface{}) error {
+	return &httpError{http.StatusBadRequest, fmt.Sprintf(format, a...)}
+}
+
+// ServeHTTP routes the explorer endpoints:
+//
+//	/rest/chaininfo
+//	/rest/blocks?offset=&limit=
+//	/rest/block/<hash>
+//	/rest/block/height/<height>
+//	/rest/tx/<txid>
+//	/rest/address/<address>
+//	/rest/address/<address>/txs?offset=&limit=
+//	/rest/address/<address>/utxos?offset=&limit=
+//	/rest/mempool?offset=&limit=
+func (e *explorer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeJSONError(w, http.StatusMethodNotAllowed, "explorer endpoints only answer GET")
+		return
+	}
+
+	result, err := e.route(r)
+	if err != nil {
+		status := http.StatusInternalServerError
+		if httpErr, ok := err.(*httpError); ok {
+			status = httpErr.status
+		}
+		writeJSONError(w, status, err.Error())
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	err = json.NewEncoder(w).Encode(result)
+	if err != nil {
+		logging.RPC.Warn("cannot write response", "err", err)
+	}
+}
+
+// route runs the endpoint of the request path, turning the panics of the chain code into errors
+func (e *explorer) route(r *http.Request) (result interface{}, err error) {
+	defer func() {
+		if p := recover(); p != nil {
+			err = fmt.Errorf("%v", p)
+		}
+	}()
+
+	parts := strings.Split(strings.Trim(strings.TrimPrefix(r.URL.Path, explorerPrefix), "/"), "/")
+
+	nodeLock.RLock()
+	defer nodeLock.RUnlock()
+
+	switch {
+	case len(parts) == 1 && parts[0] == "chaininfo":
+		return e.chainInfo(), nil
+	case len(parts) == 1 && parts[0] == "blocks":
+		return e.blocks(r)
+	case len(parts) == 2 && parts[0] == "block":
+		return e.blockByHash(parts[1])
+	case len(parts) == 3 && parts[0] == "block" && parts[1] == "height":
+		return e.blockByHeight(parts[2])
+	case len(parts) == 2 && parts[0] == "tx":
+		return e.transaction(parts[1])
+	case len(parts) == 2 && parts[0] == "address":
+		return e.address(parts[1])
+	case len(parts) == 3 && parts[0] == "address" && parts[2] == "txs":
+		return e.addressTxs(parts[1], r)
+	case len(parts) == 3 && parts[0] == "address" && parts[2] == "utxos":
+		return e.addressUTXOs(parts[1], r)
+	case len(parts) == 1 && parts[0] == "mempool":
+		return e.mempool(r)
+	}
+
+	return nil, notFound("unknown endpoint %s", r.URL.Path)
+}
+
+func writeJSONError(w http.ResponseWriter, status int, message string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+
+	err := json.NewEncoder(w).Encode(map[string]string{"error": message})
+	if err != nil {
+		logging.RPC.Warn("cannot write response", "err", err)
+	}
+}
+
+// pagination reads the offset and limit query params
+func pagination(r *http.Request) (int, int, error) {
+	offset, limit := 0, explorerDefaultLimit
+	query := r.URL.Query()
+
+	if value := query.Get("offset"); value != "" {
+		n, err := strconv.Atoi(value)
+		if err != nil || n < 0 {
+			return 0, 0, badRequest("offset must be a non-negative integer")
+		}
+		offset = n
+	}
+	if value := query.Get("limit"); value != "" {
+		n, err := strconv.Atoi(value)
+		if err != nil || n <= 0 || n > explorerMaxLimit {
+			return 0, 0, badRequest("limit must be between 1 and %d", explorerMaxLimit)
+		}
+		limit = n
+	}
+
+	return offset, limit, nil
+}
+
+// pageBounds returns the slice bounds of a page of a list of total items
+func pageBounds(total, offset, limit int) (int, int) {
+	if offset > total {
+		offset = total
+	}
+	end := offset + limit
+	if end > total {
+		end = total
+	}
+
+	return offset, end
+}
+
+func (e *explorer) chainInfo() chainStatsJSON {
+	bci := e.bc.Iterator()
+	tip := bci.Next()
+	UTXOSet := blockchainstruct.UTXOSet{Blockchain: e.bc}
+
+	stats := chainStatsJSON{
+		Height:        tip.Height,
+		BestBlockHash: hex.EncodeToString(tip.CurrHash),
+		TipTime:       tip.Timestamp,
+		UTXOTxCount:   UTXOSet.CountTransactions(),
+	}
+	stats.MempoolSize = len(memPool)
+	for _, tx := range memPool {
+		stats.MempoolBytes += len(tx.SerializeTransaction())
+	}
+
+	return stats
+}
+
+// blocks lists the blocks of the best chain newest first
+func (e *explorer) blocks(r *http.Request) (interface{}, error) {
+	offset, limit, err := pagination(r)
+	if err != nil {
+		return nil, err
+	}
+
+	items := []blockJSON{}
+	bci := e.bc.Iterator()
+	tip := bci.Next()
+	total := tip.Height + 1
+
+	for block := tip; len(items) < limit; block = bci.Next() {
+		if tip.Height-block.Height >= offset {
+			items = append(items, newBlockJSON(block))
+		}
+
+		if len(block.PrevBlockHash) == 0 {
+			break
+		}
+	}
+
+	return pageJSON{total, offset, limit, items}, nil
+}
+
+func (e *explorer) blockByHash(hashHex string) (interface{}, error) {
+	hash, err := hex.DecodeString(hashHex)
+	if err != nil {
+		return nil, badRequest("block hash must be hex")
+	}
+
+	block, err := e.bc.GetBlock(hash)
+	if err != nil {
+		return nil, notFound("block %s not found", hashHex)
+	}
+
+	return newBlockDetailJSON(&block), nil
+}
+
+func (e *explorer) blockByHeight(heightText string) (interface{}, error) {
+	height, err := strconv.Atoi(heightText)
+	if err != nil || height < 0 {
+		return nil, badRequest("block height must be a non-negative integer")
+	}
+
+	hash, err := e.bc.GetBlockHash(height)
+	if err != nil {
+		return nil, notFound("%v", err)
+	}
+
+	block, err := e.bc.GetBlock(hash)
+	if err != nil {
+		return nil, err
+	}
+
+	return newBlockDetailJSON(&block), nil
+}
+
+func newBlockDetailJSON(block *blockchainstruct.Block) blockDetailJSON {
+	detail := blockDetailJSON{blockJSON: newBlockJSON(block), Transactions: []txJSON{}}
+
+	for _, tx := range block.Transactions {
+		detail.Transactions = append(detail.Transactions, newTxJSON(tx))
+	}
+
+	return detail
+}
+
+// transaction looks txid up in the mempool, then in the blocks of the best chain
+func (e *explorer) transaction(txIDHex string) (interface{}, error) {
+	txID, err := hex.DecodeString(txIDHex)
+	if err != nil {
+		return nil, badRequest("transaction ID must be hex")
+	}
+
+	tx, inMempool := memPool[hex.EncodeToString(txID)]
+	if inMempool {
+		detail := txDetailJSON{txJSON: newTxJSON(&tx), Height: -1}
+		detail.Mempool = true
+		return detail, nil
+	}
+
+	bci := e.bc.Iterator()
+	tip := bci.Next()
+
+	for block := tip; ; block = bci.Next() {
+		for _, tx := range block.Transactions {
+			if bytes.Equal(tx.ID, txID) {
+				return txDetailJSON{
+					txJSON:        newTxJSON(tx),
+					BlockHash:     hex.EncodeToString(block.CurrHash),
+					Height:        block.Height,
+					Confirmations: tip.Height - block.Height + 1,
+				}, nil
+			}
+		}
+
+		if len(block.PrevBlockHash) == 0 {
+			break
+		}
+	}
+
+	return nil, notFound("transaction %s not found", txIDHex)
+}
+
+func (e *explorer) address(address string) (interface{}, error) {
+	pubKeyHash, err := wallets.DecodeAddress(address)
+	if err != nil {
+		return nil, badRequest("address is not valid: %v", err)
+	}
+
+	UTXOSet := blockchainstruct.UTXOSet{Blockchain: e.bc}
+	summary := addressJSON{Address: address}
+
+	for _, utxo := range UTXOSet.ListUnspent(pubKeyHash) {
+		summary.Balance += utxo.Value
+		summary.Unspent++
+	}
+
+	return summary, nil
+}
+
+// addressTxs lists the confirmed transactions paying to or spending from address, newest first
+func (e *explorer) addressTxs(address string, r *http.Request) (interface{}, error) {
+	pubKeyHash, err := wallets.DecodeAddress(address)
+	if err != nil {
+		return nil, badRequest("address is not valid: %v", err)
+	}
+	offset, limit, err := pagination(r)
+	if err != nil {
+		return nil, err
+	}
+
+	var blocks []*blockchainstruct.Block
+	bci := e.bc.Iterator()
+	for {
+		block := bci.Next()
+		blocks = append(blocks, block)
+
+		if len(block.PrevBlockHash) == 0 {
+			break
+		}
+	}
+
+	// walk from genesis, remembering the outputs of address to value what spends them
+	outputs := make(map[string]int)
+	var history []addressTxJSON
+
+	for i := len(blocks) - 1; i >= 0; i-- {
+		block := blocks[i]
+
+		for _, tx := range block.Transactions {
+			entry := addressTxJSON{
+				TxID:      hex.EncodeToString(tx.ID),
+				BlockHash: hex.EncodeToString(block.CurrHash),
+				Height:    block.Height,
+				Time:      block.Timestamp,
+			}
+			found := false
+
+			if !tx.IsCoinbase() {
+				for _, in := range tx.VIn {
+					outpoint := fmt.Sprintf("%x:%d", in.TxId, in.VOut)
+					if value, ok := outputs[outpoint]; ok {
+						entry.Sent += value
+						found = true
+						delete(outputs, outpoint)
+					}
+				}
+			}
+
+			for outIdx, out := range tx.VOut {
+				if bytes.Equal(out.PubKeyHash, pubKeyHash) {
+					entry.Received += out.Value
+					found = true
+					outputs[fmt.Sprintf("%x:%d", tx.ID, outIdx)] = out.Value
+				}
+			}
+
+			if found {
+				history = append(history, entry)
+			}
+		}
+	}
+
+	items := []addressTxJSON{}
+	start, end := pageBounds(len(history), offset, limit)
+	for i := len(history) - 1 - start; i > len(history)-1-end; i-- {
+		items = append(items, history[i])
+	}
+
+	return pageJSON{len(history), offset, limit, items}, nil
+}
+
+func (e *explorer) addressUTXOs(address string, r *http.Request) (interface{}, error) {
+	pubKeyHash, err := wallets.DecodeAddress(address)
+	if err != nil {
+		return nil, badRequest("address is not valid: %v", err)
+	}
+	offset, limit, err := pagination(r)
+	if err != nil {
+		return nil, err
+	}
+
+	UTXOSet := blockchainstruct.UTXOSet{Blockchain: e.bc}
+	utxos := UTXOSet.ListUnspent(pubKeyHash)
+	sort.Slice(utxos, func(i, j int) bool {
+		if c := bytes.Compare(utxos[i].TxID, utxos[j].TxID); c != 0 {
+			return c < 0
+		}
+		return utxos[i].VOut < utxos[j].VOut
+	})
+
+	items := []utxoJSON{}
+	start, end := pageBounds(len(utxos), offset, limit)
+	for _, utxo := range utxos[start:end] {
+		items = append(items, utxoJSON{hex.EncodeToString(utxo.TxID), utxo.VOut, utxo.Value, utxo.Height})
+	}
+
+	return pageJSON{len(utxos), offset, limit, items}, nil
+}
+
+// mempool lists the unconfirmed transactions ordered by ID
+func (e *explorer) mempool(r *http.Request) (interface{}, error) {
+	offset, limit, err := pagination(r)
+	if err != nil {
+		return nil, err
+	}
+
+	var ids []string
+	for id := range memPool {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	items := []txJSON{}
+	start, end := pageBounds(len(ids), offset, limit)
+	for _, id := range ids[start:end] {
+		tx := memPool[id]
+		items = append(items, newTxJSON(&tx))
+	}
+
+	return pageJSON{len(ids), offset, limit, items}, nil
+}
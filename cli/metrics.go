@@ -0,0 +1,186 @@
+package cli
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/cyprus09/blockchain/blockchainstruct"
+)
+
+// blockValidationBuckets are the upper bounds in seconds of the block validation histogram
+var blockValidationBuckets = []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}
+
+// nodeMetrics counts what the node did since it started
+var nodeMetrics = &metrics{
+	messagesIn:       make(map[string]uint64),
+	messagesOut:      make(map[string]uint64),
+	validationCounts: make([]uint64, len(blockValidationBuckets)),
+}
+
+// metrics holds the counters that are not read from the chain when scraped
+type metrics struct {
+	mu          sync.Mutex
+	messagesIn  map[string]uint64
+	messagesOut map[string]uint64
+	// validationCounts holds the non-cumulative count of each histogram bucket
+	validationCounts []uint64
+	validationCount  uint64
+	validationSum    float64
+}
+
+func (m *metrics) messageIn(command string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.messagesIn[command]++
+}
+
+func (m *metrics) messageOut(command string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.messagesOut[command]++
+}
+
+// observeBlockValidation records how long a received block took to validate and store
+func (m *metrics) observeBlockValidation(d time.Duration) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	seconds := d.Seconds()
+	for i, bound := range blockValidationBuckets {
+		if seconds <= bound {
+			m.validationCounts[i]++
+			break
+		}
+	}
+	m.validationCount++
+	m.validationSum += seconds
+}
+
+// metricsHandler serves the metrics of the node in the Prometheus text format
+type metricsHandler struct {
+	bc *blockchainstruct.Blockchain
+}
+
+func (h *metricsHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+
+	nodeLock.RLock()
+	defer nodeLock.RUnlock()
+
+	tip, err := h.bc.GetBlock(h.bc.GetBestHash())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	UTXOSet := blockchainstruct.UTXOSet{Blockchain: h.bc}
+
+	writeGauge(w, "blockchain_height", "Height of the best block.", float64(tip.Height))
+	writeGauge(w, "blockchain_tip_age_seconds", "Seconds since the timestamp of the best block.", time.Since(time.Unix(tip.Timestamp, 0)).Seconds())
+	writeGauge(w, "blockchain_utxo_transactions", "Transactions with unspent outputs in the UTXO set.", float64(UTXOSet.CountTransactions()))
+
+	mempoolBytes := 0
+	for _, tx := range memPool {
+		mempoolBytes += len(tx.SerializeTransaction())
+	}
+	mempoolSize := len(memPool)
+	peers := 0
+	for _, node := range knownNodes {
+		if node != nodeAddress {
+			peers++
+		}
+	}
+	newAddrs, triedAddrs := addrMgr.counts()
+
+	writeGauge(w, "blockchain_mempool_transactions", "Transactions in the mempool.", float64(mempoolSize))
+	writeGauge(w, "blockchain_mempool_bytes", "Serialized size of the mempool transactions.", float64(mempoolBytes))
+	writeGauge(w, "blockchain_peers", "Known peers of the node.", float64(peers))
+	writeGauge(w, "blockchain_addresses_new", "Addresses heard of but never reached.", float64(newAddrs))
+	writeGauge(w, "blockchain_addresses_tried", "Addresses the node reached.", float64(triedAddrs))
+
+	hashes, elapsed := blockchainstruct.PoWStats()
+	hashrate := 0.0
+	if elapsed > 0 {
+		hashrate = float64(hashes) / elapsed.Seconds()
+	}
+	writeCounter(w, "blockchain_pow_hashes_total", "Hashes tried by proof of work.", float64(hashes))
+	writeCounter(w, "blockchain_pow_seconds_total", "Seconds spent on proof of work.", elapsed.Seconds())
+	writeGauge(w, "blockchain_pow_hashrate", "Average hashes per second of proof of work.", hashrate)
+
+	nodeMetrics.write(w)
+	writeDBStats(w, h.bc)
+}
+
+// write prints the message counters and the block validation histogram
+func (m *metrics) write(w io.Writer) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	writeHeader(w, "blockchain_messages_received_total", "Peer messages received by command.", "counter")
+	for _, command := range sortedKeys(m.messagesIn) {
+		fmt.Fprintf(w, "blockchain_messages_received_total{command=%q} %d\n", command, m.messagesIn[command])
+	}
+	writeHeader(w, "blockchain_messages_sent_total", "Peer messages sent by command.", "counter")
+	for _, command := range sortedKeys(m.messagesOut) {
+		fmt.Fprintf(w, "blockchain_messages_sent_total{command=%q} %d\n", command, m.messagesOut[command])
+	}
+
+	name := "blockchain_block_validation_seconds"
+	writeHeader(w, name, "Time to validate and store a block received from a peer.", "histogram")
+	cumulative := uint64(0)
+	for i, bound := range blockValidationBuckets {
+		cumulative += m.validationCounts[i]
+		fmt.Fprintf(w, "%s_bucket{le=\"%g\"} %d\n", name, bound, cumulative)
+	}
+	fmt.Fprintf(w, "%s_bucket{le=\"+Inf\"} %d\n", name, m.validationCount)
+	fmt.Fprintf(w, "%s_sum %g\n", name, m.validationSum)
+	fmt.Fprintf(w, "%s_count %d\n", name, m.validationCount)
+}
+
+// writeDBStats prints the statistics of the bolt database
+func writeDBStats(w io.Writer, bc *blockchainstruct.Blockchain) {
+	stats := bc.DB.Stats()
+
+	writeCounter(w, "blockchain_db_read_tx_total", "Read transactions started on the database.", float64(stats.TxN))
+	writeGauge(w, "blockchain_db_open_read_tx", "Read transactions currently open on the database.", float64(stats.OpenTxN))
+	writeGauge(w, "blockchain_db_free_pages", "Free pages of the database.", float64(stats.FreePageN))
+	writeGauge(w, "blockchain_db_pending_pages", "Pages freed but still in use by open transactions.", float64(stats.PendingPageN))
+	writeGauge(w, "blockchain_db_free_alloc_bytes", "Bytes allocated in free pages.", float64(stats.FreeAlloc))
+	writeCounter(w, "blockchain_db_page_alloc_bytes_total", "Bytes allocated for pages by write transactions.", float64(stats.TxStats.PageAlloc))
+	writeCounter(w, "blockchain_db_writes_total", "Pages written by write transactions.", float64(stats.TxStats.Write))
+
+	if info, err := os.Stat(bc.DB.Path()); err == nil {
+		writeGauge(w, "blockchain_db_size_bytes", "Size of the database file.", float64(info.Size()))
+	}
+}
+
+func writeHeader(w io.Writer, name, help, kind string) {
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", name, help, name, kind)
+}
+
+func writeGauge(w io.Writer, name, help string, value float64) {
+	writeHeader(w, name, help, "gauge")
+	fmt.Fprintf(w, "%s %g\n", name, value)
+}
+
+func writeCounter(w io.Writer, name, help string, value float64) {
+	writeHeader(w, name, help, "counter")
+	fmt.Fprintf(w, "%s %g\n", name, value)
+}
+
+func sortedKeys(counts map[string]uint64) []string {
+	var keys []string
+
+	for key := range counts {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	return keys
+}
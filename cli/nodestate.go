@@ -0,0 +1,173 @@
+package cli
+
+import (
+	"bytes"
+	"encoding/gob"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/cyprus09/blockchain/blockchainstruct"
+	"github.com/cyprus09/blockchain/logging"
+	"github.com/cyprus09/blockchain/utils"
+)
+
+const (
+	peersFile   = "peers_%s.dat"
+	mempoolFile = "mempool_%s.dat"
+)
+
+// savePeers writes the addresses of the address manager to the peers file of node nodeID
+func savePeers(nodeID string) error {
+	return utils.WriteFileAtomic(utils.DataPath(fmt.Sprintf(peersFile, nodeID)), gobEncode(addrMgr.all()), 0600)
+}
+
+// loadPeers returns the addresses saved by savePeers, none when nothing was saved yet
+func loadPeers(nodeID string) ([]knownAddress, error) {
+	var peers []knownAddress
+
+	data, err := os.ReadFile(utils.DataPath(fmt.Sprintf(peersFile, nodeID)))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	err = gob.NewDecoder(bytes.NewReader(data)).Decode(&peers)
+	if err != nil {
+		return nil, fmt.Errorf("invalid peers file: %v", err)
+	}
+
+	return peers, nil
+}
+
+// saveMempool writes the mempool transactions to the mempool file of node nodeID
+func saveMempool(nodeID string) error {
+	txs := make([]blockchainstruct.Transaction, 0, len(memPool))
+	for _, tx := range memPool {
+		txs = append(txs, tx)
+	}
+
+	return utils.WriteFileAtomic(utils.DataPath(fmt.Sprintf(mempoolFile, nodeID)), gobEncode(txs), 0600)
+}
+
+// readMempool returns the transactions saved by saveMempool, none when nothing was saved yet
+func readMempool(nodeID string) ([]blockchainstruct.Transaction, error) {
+	var txs []blockchainstruct.Transaction
+
+	data, err := os.ReadFile(utils.DataPath(fmt.Sprintf(mempoolFile, nodeID)))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	err = gob.NewDecoder(bytes.NewReader(data)).Decode(&txs)
+	if err != nil {
+		return nil, fmt.Errorf("invalid mempool file: %v", err)
+	}
+
+	return txs, nil
+}
+
+// loadMempool puts the transactions saved by saveMempool back in the mempool. Those
+// spending outputs the chainstate no longer holds, or already spent by another loaded
+// transaction, or whose signatures do not verify are dropped. It returns how many
+// transactions were loaded and dropped
+func loadMempool(nodeID string, bc *blockchainstruct.Blockchain) (int, int, error) {
+	txs, err := readMempool(nodeID)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	UTXOSet := blockchainstruct.UTXOSet{Blockchain: bc}
+	spent := make(map[string]bool)
+	loaded := 0
+
+	for i := range txs {
+		tx := &txs[i]
+		if err := checkMempoolTx(tx, &UTXOSet, spent); err != nil {
+			logging.Mempool.Debug("dropped saved transaction", "txid", hex.EncodeToString(tx.ID), "err", err)
+			continue
+		}
+
+		markSpent(tx, spent)
+		memPool[hex.EncodeToString(tx.ID)] = *tx
+		loaded++
+	}
+
+	return loaded, len(txs) - loaded, nil
+}
+
+// checkMempoolTx checks that tx may join a mempool whose transactions spend the outpoints
+// in spent: it is not a coinbase, every input spends an output of the chainstate that is
+// not in spent, and its signatures verify
+func checkMempoolTx(tx *blockchainstruct.Transaction, UTXOSet *blockchainstruct.UTXOSet, spent map[string]bool) error {
+	if tx.IsCoinbase() {
+		return errors.New("a coinbase transaction cannot join the mempool")
+	}
+
+	for _, in := range tx.VIn {
+		outpoint := blockchainstruct.Outpoint{TxID: in.TxId, VOut: in.VOut}
+		if spent[outpoint.String()] {
+			return fmt.Errorf("input %s is already spent by a mempool transaction", outpoint)
+		}
+		if _, err := UTXOSet.GetUTXO(outpoint); err != nil {
+			return fmt.Errorf("input %s is already spent or unknown", outpoint)
+		}
+	}
+
+	if !UTXOSet.Blockchain.VerifyTransaction(tx) {
+		return errors.New("transaction signatures do not match the chain")
+	}
+
+	return nil
+}
+
+// markSpent adds the outpoints tx spends to spent
+func markSpent(tx *blockchainstruct.Transaction, spent map[string]bool) {
+	for _, in := range tx.VIn {
+		spent[blockchainstruct.Outpoint{TxID: in.TxId, VOut: in.VOut}.String()] = true
+	}
+}
+
+// mempoolSpent returns the outpoints spent by the mempool transactions. nodeLock must be held
+func mempoolSpent() map[string]bool {
+	spent := make(map[string]bool)
+	for id := range memPool {
+		tx := memPool[id]
+		markSpent(&tx, spent)
+	}
+
+	return spent
+}
+
+// savedMempoolSpent returns the outpoints spent by the mempool a stopped node saved, so
+// commands run without the node do not spend them again
+func savedMempoolSpent(nodeID string) map[string]bool {
+	spent := make(map[string]bool)
+
+	txs, err := readMempool(nodeID)
+	if err != nil {
+		logging.Mempool.Warn("cannot read the saved mempool", "err", err)
+	}
+	for i := range txs {
+		markSpent(&txs[i], spent)
+	}
+
+	return spent
+}
+
+// openBlockchain opens the chain of the node, exiting when this version cannot read it
+func openBlockchain(nodeID string) *blockchainstruct.Blockchain {
+	bc, err := blockchainstruct.NewBlockchain(nodeID)
+	if err != nil {
+		fmt.Printf("ERROR: %v. Upgrade the node.\n", err)
+		os.Exit(1)
+	}
+
+	return bc
+}
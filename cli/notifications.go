@@ -0,0 +1,293 @@
+package cli
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/cyprus09/blockchain/blockchainstruct"
+	"github.com/cyprus09/blockchain/wallets"
+	"golang.org/x/net/websocket"
+)
+
+// Event names of the notification stream
+const (
+	eventBlockConnected    = "blockconnected"
+	eventBlockDisconnected = "blockdisconnected"
+	eventTxAccepted        = "txaccepted"
+	eventTxConfirmed       = "txconfirmed"
+)
+
+// subscriberQueueLen is how many events a subscriber may fall behind before it is dropped
+const subscriberQueueLen = 256
+
+var eventNames = []string{eventBlockConnected, eventBlockDisconnected, eventTxAccepted, eventTxConfirmed}
+
+// notifications fans the events of the node out to the subscribers of /events and /ws
+var notifications = &notificationHub{subscribers: make(map[*subscriber]struct{})}
+
+// eventJSON is one notification. Block events carry the block, transaction events the
+// transaction and, once confirmed, the block that holds it
+type eventJSON struct {
+	Event     string     `json:"event"`
+	Block     *blockJSON `json:"block,omitempty"`
+	Tx        *txJSON    `json:"tx,omitempty"`
+	BlockHash string     `json:"blockhash,omitempty"`
+	Height    int        `json:"height,omitempty"`
+}
+
+// subscriber receives the events it asked for on its queue
+type subscriber struct {
+	queue chan []byte
+	// events and addresses filter what is sent, nil passes everything
+	events    map[string]bool
+	addresses map[string]bool
+}
+
+// notificationHub keeps the subscribers and publishes events to them
+type notificationHub struct {
+	mu          sync.Mutex
+	subscribers map[*subscriber]struct{}
+}
+
+// newSubscriber reads the comma separated events and address filters of the query
+func newSubscriber(r *http.Request) (*subscriber, error) {
+	sub := &subscriber{queue: make(chan []byte, subscriberQueueLen)}
+	query := r.URL.Query()
+
+	if events := query.Get("events"); events != "" {
+		sub.events = make(map[string]bool)
+		for _, event := range strings.Split(events, ",") {
+			event = strings.TrimSpace(event)
+			if !contains(eventNames, event) {
+				return nil, badRequest("unknown event %q, expected one of %s", event, strings.Join(eventNames, ", "))
+			}
+			sub.events[event] = true
+		}
+	}
+
+	if addresses := query.Get("address"); addresses != "" {
+		sub.addresses = make(map[string]bool)
+		for _, address := range strings.Split(addresses, ",") {
+			pubKeyHash, err := wallets.DecodeAddress(strings.TrimSpace(address))
+			if err != nil {
+				return nil, badRequest("address is not valid: %v", err)
+			}
+			sub.addresses[wallets.EncodeAddress(pubKeyHash)] = true
+		}
+	}
+
+	return sub, nil
+}
+
+// wants reports whether the subscriber filters let an event about addresses through
+func (s *subscriber) wants(event string, addresses []string) bool {
+	if s.events != nil && !s.events[event] {
+		return false
+	}
+	if s.addresses == nil {
+		return true
+	}
+
+	for _, address := range addresses {
+		if s.addresses[address] {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (h *notificationHub) add(sub *subscriber) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.subscribers[sub] = struct{}{}
+}
+
+func (h *notificationHub) remove(sub *subscriber) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if _, ok := h.subscribers[sub]; ok {
+		delete(h.subscribers, sub)
+		close(sub.queue)
+	}
+}
+
+// closeAll ends every subscription, which makes the streams return
+func (h *notificationHub) closeAll() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	for sub := range h.subscribers {
+		delete(h.subscribers, sub)
+		close(sub.queue)
+	}
+}
+
+// publish queues event for every subscriber interested in addresses. A subscriber whose
+// queue is full is dropped rather than holding up the node
+func (h *notificationHub) publish(event eventJSON, addresses []string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if len(h.subscribers) == 0 {
+		return
+	}
+
+	data, err := json.Marshal(event)
+	if err != nil {
+		log.Panic(err)
+	}
+
+	for sub := range h.subscribers {
+		if !sub.wants(event.Event, addresses) {
+			continue
+		}
+
+		select {
+		case sub.queue <- data:
+		default:
+			delete(h.subscribers, sub)
+			close(sub.queue)
+		}
+	}
+}
+
+// txAddresses returns the addresses a transaction pays to or spends from
+func txAddresses(tx *blockchainstruct.Transaction) []string {
+	var addresses []string
+
+	if !tx.IsCoinbase() {
+		for _, in := range tx.VIn {
+			addresses = append(addresses, wallets.EncodeAddress(wallets.HashPubKey(in.PubKey)))
+		}
+	}
+	for _, out := range tx.VOut {
+		addresses = append(addresses, wallets.EncodeAddress(out.PubKeyHash))
+	}
+
+	return addresses
+}
+
+// publishChainEvent turns the chain events of the blockchain into notifications
+func publishChainEvent(event blockchainstruct.ChainEvent) {
+	block := newBlockJSON(event.Block)
+	var addresses []string
+
+	for _, tx := range event.Block.Transactions {
+		addresses = append(addresses, txAddresses(tx)...)
+	}
+
+	if event.Type == blockchainstruct.BlockDisconnected {
+		notifications.publish(eventJSON{Event: eventBlockDisconnected, Block: &block}, addresses)
+		return
+	}
+
+	notifications.publish(eventJSON{Event: eventBlockConnected, Block: &block}, addresses)
+	for _, tx := range event.Block.Transactions {
+		txData := newTxJSON(tx)
+		confirmed := eventJSON{Event: eventTxConfirmed, Tx: &txData, BlockHash: block.Hash, Height: block.Height}
+		notifications.publish(confirmed, txAddresses(tx))
+	}
+}
+
+// publishTxAccepted notifies the subscribers of a transaction entering the mempool
+func publishTxAccepted(tx *blockchainstruct.Transaction) {
+	txData := newTxJSON(tx)
+	notifications.publish(eventJSON{Event: eventTxAccepted, Tx: &txData}, txAddresses(tx))
+}
+
+// serveEventStream streams the events as newline delimited JSON until the client leaves
+func serveEventStream(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeJSONError(w, http.StatusMethodNotAllowed, "the event stream only answers GET")
+		return
+	}
+
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		writeJSONError(w, http.StatusInternalServerError, "streaming is not supported")
+		return
+	}
+
+	sub, err := newSubscriber(r)
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	notifications.add(sub)
+	defer notifications.remove(sub)
+
+	w.Header().Set("Content-Type", "application/x-ndjson")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	for {
+		select {
+		case data, ok := <-sub.queue:
+			if !ok {
+				return
+			}
+			if _, err := w.Write(append(data, '\n')); err != nil {
+				return
+			}
+			flusher.Flush()
+		case <-r.Context().Done():
+			return
+		}
+	}
+}
+
+// rejectOrigin fails the WebSocket handshake of requests made from a web page
+func rejectOrigin(config *websocket.Config, r *http.Request) error {
+	if origin := r.Header.Get("Origin"); origin != "" {
+		return fmt.Errorf("cross-origin WebSocket from %s refused", origin)
+	}
+
+	return nil
+}
+
+// serveEventSocket sends every event as a text message over a WebSocket
+func serveEventSocket(w http.ResponseWriter, r *http.Request) {
+	sub, err := newSubscriber(r)
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	// websocket.Server, unlike websocket.Handler, accepts clients that send no Origin.
+	// Browsers always send one, so rejecting it keeps web pages from subscribing
+	server := websocket.Server{Handshake: rejectOrigin, Handler: func(ws *websocket.Conn) {
+		notifications.add(sub)
+		defer notifications.remove(sub)
+
+		// the client only ever closes the socket, so a failed read means it left
+		closed := make(chan struct{})
+		go func() {
+			var discard []byte
+			for websocket.Message.Receive(ws, &discard) == nil {
+			}
+			close(closed)
+		}()
+
+		for {
+			select {
+			case data, ok := <-sub.queue:
+				if !ok {
+					return
+				}
+				if err := websocket.Message.Send(ws, string(data)); err != nil {
+					return
+				}
+			case <-closed:
+				return
+			}
+		}
+	}}
+	server.ServeHTTP(w, r)
+}
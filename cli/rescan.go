@@ -0,0 +1,79 @@
+package cli
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+
+	"github.com/cyprus09/blockchain/blockchainstruct"
+	"github.com/cyprus09/blockchain/wallets"
+)
+
+// rescanJSON is what a rescan found for one address
+type rescanJSON struct {
+	Address      string `json:"address"`
+	Transactions int    `json:"transactions"`
+	Balance      int    `json:"balance"`
+}
+
+// rescanWallet rebuilds the wallet history from the whole chain and returns how many
+// transactions each of the given addresses took part in along with its unspent balance
+func rescanWallet(bc *blockchainstruct.Blockchain, ws *wallets.Wallets, addresses []string) []rescanJSON {
+	UTXOSet := blockchainstruct.UTXOSet{Blockchain: bc}
+	pubKeyHashes := ws.PubKeyHashes()
+	results := []rescanJSON{}
+
+	ws.ResetHistory()
+	syncWallet(bc, ws)
+
+	for pubKeyHash, address := range pubKeyHashes {
+		if !contains(addresses, address) {
+			continue
+		}
+
+		balance := 0
+		for _, utxo := range UTXOSet.ListUnspent([]byte(pubKeyHash)) {
+			balance += utxo.Value
+		}
+		results = append(results, rescanJSON{address, len(ws.History().Transactions(address)), balance})
+	}
+
+	return results
+}
+
+// rescan rebuilds the history of the saved wallet file of nodeID, on the running node
+// when there is one since it holds the database, and prints what it found for addresses
+func rescan(addresses []string, nodeID string) {
+	var results []rescanJSON
+
+	result, err := callRPC("rescanwallet", addresses)
+	if err == nil {
+		err = json.Unmarshal(result, &results)
+		if err != nil {
+			log.Panic(err)
+		}
+	} else if err == errNodeNotRunning {
+		ws, _ := wallets.NewWallets(nodeID)
+		bc := openBlockchain(nodeID)
+		defer bc.DB.Close()
+
+		results = rescanWallet(bc, ws, addresses)
+		ws.SaveToFile(nodeID)
+	} else {
+		fmt.Printf("WARNING: The chain was not rescanned: %v\n", err)
+		return
+	}
+
+	for _, r := range results {
+		fmt.Printf("Rescanned %s: %d transactions, balance %d\n", r.Address, r.Transactions, r.Balance)
+	}
+}
+
+func contains(list []string, item string) bool {
+	for _, entry := range list {
+		if entry == item {
+			return true
+		}
+	}
+	return false
+}
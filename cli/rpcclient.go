@@ -0,0 +1,106 @@
+package cli
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+)
+
+const rpcDialTimeout = 2 * time.Second
+
+// errNodeNotRunning is returned by callRPC when no node answers on the RPC address
+var errNodeNotRunning = errors.New("node is not running")
+
+// nodeIsRunning reports whether a node answers on the configured RPC address
+func nodeIsRunning() bool {
+	conn, err := net.DialTimeout("tcp", nodeConfig.RPCListen, rpcDialTimeout)
+	if err != nil {
+		return false
+	}
+	conn.Close()
+
+	return true
+}
+
+// rpcCredentials returns the configured RPC user and password, or those of the cookie
+// file of a node run without them
+func rpcCredentials() (string, string, error) {
+	if nodeConfig.RPCUser != "" || nodeConfig.RPCPassword != "" {
+		return nodeConfig.RPCUser, nodeConfig.RPCPassword, nil
+	}
+
+	cookie, err := os.ReadFile(nodeConfig.cookiePath())
+	if err != nil {
+		return "", "", fmt.Errorf("cannot read the RPC cookie, set -rpcuser and -rpcpassword: %v", err)
+	}
+	user, password, ok := strings.Cut(strings.TrimSpace(string(cookie)), ":")
+	if !ok {
+		return "", "", fmt.Errorf("invalid RPC cookie file %s", nodeConfig.cookiePath())
+	}
+
+	return user, password, nil
+}
+
+// callRPC calls method on the node at the configured RPC address and returns the raw
+// JSON result
+func callRPC(method string, params ...interface{}) (json.RawMessage, error) {
+	if params == nil {
+		params = []interface{}{}
+	}
+
+	body, err := json.Marshal(map[string]interface{}{
+		"jsonrpc": "2.0",
+		"method":  method,
+		"params":  params,
+		"id":      1,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	request, err := http.NewRequest(http.MethodPost, fmt.Sprintf("http://%s/", nodeConfig.RPCListen), bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	request.Header.Set("Content-Type", "application/json")
+	user, password, err := rpcCredentials()
+	if err != nil {
+		// a node that is not running has no cookie
+		if !nodeIsRunning() {
+			return nil, errNodeNotRunning
+		}
+		return nil, err
+	}
+	request.SetBasicAuth(user, password)
+
+	resp, err := http.DefaultClient.Do(request)
+	if err != nil {
+		var netErr *net.OpError
+		if errors.As(err, &netErr) && netErr.Op == "dial" {
+			return nil, errNodeNotRunning
+		}
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusUnauthorized {
+		return nil, errors.New("the node rejected the RPC user or password")
+	}
+
+	var response rpcResponse
+	err = json.NewDecoder(resp.Body).Decode(&response)
+	if err != nil {
+		return nil, fmt.Errorf("invalid RPC response: %v", err)
+	}
+	if response.Error != nil {
+		return nil, response.Error
+	}
+
+	return response.Result, nil
+}
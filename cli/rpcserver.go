@@ -0,0 +1,885 @@
+package cli
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"mime"
+	"net/http"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/cyprus09/blockchain/blockchainstruct"
+	"github.com/cyprus09/blockchain/logging"
+	"github.com/cyprus09/blockchain/utils"
+	"github.com/cyprus09/blockchain/wallets"
+)
+
+const (
+	// rpcPortOffset puts the RPC server of node N on port N+rpcPortOffset
+	rpcPortOffset  = 10000
+	rpcMaxBodySize = 1 << 20
+	// rpcCookieFile holds user:password of a node run without RPC credentials
+	rpcCookieFile = "rpc_%s.cookie"
+	rpcCookieUser = "__cookie__"
+)
+
+// JSON-RPC 2.0 error codes
+const (
+	rpcParseError     = -32700
+	rpcInvalidRequest = -32600
+	rpcMethodNotFound = -32601
+	rpcInvalidParams  = -32602
+	rpcInternalError  = -32603
+	// rpcMiscError is returned when a method fails, e.g. for an unknown block
+	rpcMiscError = -1
+)
+
+// nodeLock guards the node state shared by peer handlers and RPC calls, such as memPool,
+// knownNodes and the chain tip. Calls that only read it, including reads of the chainstate,
+// hold the read lock
+var nodeLock sync.RWMutex
+
+// rpcRequest is a JSON-RPC 2.0 request. A request without ID is a notification
+type rpcRequest struct {
+	JSONRPC string          `json:"jsonrpc"`
+	Method  string          `json:"method"`
+	Params  json.RawMessage `json:"params"`
+	ID      json.RawMessage `json:"id"`
+}
+
+// rpcResponse is a JSON-RPC 2.0 response
+type rpcResponse struct {
+	JSONRPC string          `json:"jsonrpc"`
+	Result  json.RawMessage `json:"result,omitempty"`
+	Error   *rpcError       `json:"error,omitempty"`
+	ID      json.RawMessage `json:"id"`
+}
+
+// rpcError is the error object of a JSON-RPC 2.0 response
+type rpcError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+func (e *rpcError) Error() string {
+	return e.Message
+}
+
+// rpcHandler runs a method with its positional params
+type rpcHandler func(s *rpcServer, params []json.RawMessage) (interface{}, error)
+
+var rpcHandlers = map[string]rpcHandler{
+	"getblock":           rpcGetBlock,
+	"getblockhash":       rpcGetBlockHash,
+	"getbestblockhash":   rpcGetBestBlockHash,
+	"gettransaction":     rpcGetTransaction,
+	"getbalance":         rpcGetBalance,
+	"sendtoaddress":      rpcSendToAddress,
+	"sendmany":           rpcSendMany,
+	"sendrawtransaction": rpcSendRawTransaction,
+	"listunspent":        rpcListUnspent,
+	"listtransactions":   rpcListTransactions,
+	"rescanwallet":       rpcRescanWallet,
+	"walletpassphrase":   rpcWalletPassphrase,
+	"walletlock":         rpcWalletLock,
+	"getmempoolinfo":     rpcGetMempoolInfo,
+	"getpeerinfo":        rpcGetPeerInfo,
+	"generate":           rpcGenerate,
+}
+
+// rpcServer answers JSON-RPC calls against the chain of a running node
+type rpcServer struct {
+	bc     *blockchainstruct.Blockchain
+	nodeID string
+	// user and password, when set, are required with HTTP basic authentication
+	user     string
+	password string
+}
+
+// blockJSON is the JSON form of a block
+type blockJSON struct {
+	Hash          string   `json:"hash"`
+	Height        int      `json:"height"`
+	PrevBlockHash string   `json:"previousblockhash"`
+	Timestamp     int64    `json:"time"`
+	Nonce         int      `json:"nonce"`
+	Tx            []string `json:"tx"`
+}
+
+// txJSON is the JSON form of a transaction
+type txJSON struct {
+	TxID     string      `json:"txid"`
+	Coinbase bool        `json:"coinbase"`
+	VIn      []txInJSON  `json:"vin"`
+	VOut     []txOutJSON `json:"vout"`
+	Mempool  bool        `json:"mempool,omitempty"`
+}
+
+// txInJSON is the JSON form of a transaction input
+type txInJSON struct {
+	TxID      string `json:"txid,omitempty"`
+	VOut      int    `json:"vout"`
+	PubKey    string `json:"pubkey,omitempty"`
+	Signature string `json:"signature,omitempty"`
+}
+
+// txOutJSON is the JSON form of a transaction output
+type txOutJSON struct {
+	Value   int    `json:"value"`
+	Address string `json:"address"`
+}
+
+func newBlockJSON(block *blockchainstruct.Block) blockJSON {
+	result := blockJSON{
+		Hash:          hex.EncodeToString(block.CurrHash),
+		Height:        block.Height,
+		PrevBlockHash: hex.EncodeToString(block.PrevBlockHash),
+		Timestamp:     block.Timestamp,
+		Nonce:         block.Nonce,
+		Tx:            []string{},
+	}
+	for _, tx := range block.Transactions {
+		result.Tx = append(result.Tx, hex.EncodeToString(tx.ID))
+	}
+
+	return result
+}
+
+func newTxJSON(tx *blockchainstruct.Transaction) txJSON {
+	result := txJSON{TxID: hex.EncodeToString(tx.ID), Coinbase: tx.IsCoinbase()}
+
+	for _, in := range tx.VIn {
+		result.VIn = append(result.VIn, txInJSON{
+			TxID:      hex.EncodeToString(in.TxId),
+			VOut:      in.VOut,
+			PubKey:    hex.EncodeToString(in.PubKey),
+			Signature: hex.EncodeToString(in.Signature),
+		})
+	}
+	for _, out := range tx.VOut {
+		result.VOut = append(result.VOut, txOutJSON{out.Value, wallets.EncodeAddress(out.PubKeyHash)})
+	}
+
+	return result
+}
+
+// rpcAddress returns the address the RPC server of node nodeID listens on
+func rpcAddress(nodeID string) string {
+	port, err := strconv.Atoi(nodeID)
+	if err != nil || port+rpcPortOffset > 65535 {
+		log.Panicf("cannot derive an RPC port from node ID %s", nodeID)
+	}
+
+	return fmt.Sprintf("localhost:%d", port+rpcPortOffset)
+}
+
+// startRPCServer serves JSON-RPC, the explorer endpoints, the event streams and the
+// metrics on addr in the background, and returns the server so it can be shut down
+func startRPCServer(config *Config, nodeID string, bc *blockchainstruct.Blockchain) *http.Server {
+	addr := config.RPCListen
+	user, password := config.RPCUser, config.RPCPassword
+	if user == "" && password == "" {
+		user, password = writeCookie(config.cookiePath())
+	}
+
+	rpc := &rpcServer{bc, nodeID, user, password}
+	mux := http.NewServeMux()
+	mux.Handle("/", rpc)
+	mux.Handle(explorerPrefix, rpc.requireAuth(&explorer{bc}))
+	mux.Handle("/events", rpc.requireAuth(http.HandlerFunc(serveEventStream)))
+	mux.Handle("/ws", rpc.requireAuth(http.HandlerFunc(serveEventSocket)))
+	mux.Handle("/metrics", rpc.requireAuth(&metricsHandler{bc}))
+
+	server := &http.Server{Addr: addr, Handler: mux}
+	// event streams never go idle on their own, so end them when the server shuts down
+	server.RegisterOnShutdown(notifications.closeAll)
+	if config.RPCUser == "" && config.RPCPassword == "" {
+		server.RegisterOnShutdown(func() { os.Remove(config.cookiePath()) })
+	}
+
+	go func() {
+		err := server.ListenAndServe()
+		if err != nil && err != http.ErrServerClosed {
+			log.Panic(err)
+		}
+	}()
+	logging.RPC.Info("RPC server listening", "addr", addr)
+
+	return server
+}
+
+// writeCookie makes up RPC credentials and writes them to path, readable by the owner only
+func writeCookie(path string) (string, string) {
+	secret := make([]byte, 32)
+	_, err := rand.Read(secret)
+	if err != nil {
+		log.Panic(err)
+	}
+	password := hex.EncodeToString(secret)
+
+	err = utils.WriteFileAtomic(path, []byte(rpcCookieUser+":"+password), 0600)
+	if err != nil {
+		log.Panic(err)
+	}
+
+	return rpcCookieUser, password
+}
+
+// ServeHTTP answers a single JSON-RPC request or a batch of them
+func (s *rpcServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "JSON-RPC requests must be POSTed", http.StatusMethodNotAllowed)
+		return
+	}
+	if !s.authorized(r) {
+		unauthorized(w)
+		return
+	}
+	// a web page can only send a cross-site POST without a preflight as a form or plain text
+	mediaType, _, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if err != nil || mediaType != "application/json" {
+		http.Error(w, "JSON-RPC requests must be sent as application/json", http.StatusUnsupportedMediaType)
+		return
+	}
+
+	body, err := io.ReadAll(io.LimitReader(r.Body, rpcMaxBodySize))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	body = bytes.TrimSpace(body)
+
+	var result interface{}
+	if len(body) > 0 && body[0] == '[' {
+		var batch []json.RawMessage
+		if err := json.Unmarshal(body, &batch); err != nil || len(batch) == 0 {
+			result = errorResponse(nil, rpcInvalidRequest, "invalid batch")
+		} else {
+			var responses []*rpcResponse
+			for _, raw := range batch {
+				if response := s.handle(raw); response != nil {
+					responses = append(responses, response)
+				}
+			}
+			if len(responses) == 0 {
+				w.WriteHeader(http.StatusNoContent)
+				return
+			}
+			result = responses
+		}
+	} else {
+		response := s.handle(body)
+		if response == nil {
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		result = response
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	err = json.NewEncoder(w).Encode(result)
+	if err != nil {
+		logging.RPC.Warn("cannot write response", "err", err)
+	}
+}
+
+// requireAuth serves next only to clients with the RPC user and password
+func (s *rpcServer) requireAuth(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !s.authorized(r) {
+			unauthorized(w)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func unauthorized(w http.ResponseWriter) {
+	w.Header().Set("WWW-Authenticate", `Basic realm="rpc"`)
+	http.Error(w, "wrong RPC user or password", http.StatusUnauthorized)
+}
+
+// authorized checks the basic authentication of r
+func (s *rpcServer) authorized(r *http.Request) bool {
+	user, password, ok := r.BasicAuth()
+	userOK := subtle.ConstantTimeCompare([]byte(user), []byte(s.user)) == 1
+	passwordOK := subtle.ConstantTimeCompare([]byte(password), []byte(s.password)) == 1
+
+	return ok && userOK && passwordOK
+}
+
+// handle runs one request and returns its response, nil for notifications
+func (s *rpcServer) handle(raw json.RawMessage) *rpcResponse {
+	var request rpcRequest
+
+	if err := json.Unmarshal(raw, &request); err != nil {
+		return errorResponse(nil, rpcParseError, err.Error())
+	}
+	if request.JSONRPC != "2.0" || request.Method == "" {
+		return errorResponse(request.ID, rpcInvalidRequest, "not a JSON-RPC 2.0 request")
+	}
+
+	handler, ok := rpcHandlers[request.Method]
+	if !ok {
+		return errorResponse(request.ID, rpcMethodNotFound, fmt.Sprintf("method %q not found", request.Method))
+	}
+
+	var params []json.RawMessage
+	if len(request.Params) > 0 && string(request.Params) != "null" {
+		if err := json.Unmarshal(request.Params, &params); err != nil {
+			return errorResponse(request.ID, rpcInvalidParams, "params must be an array")
+		}
+	}
+
+	logging.RPC.Debug("call", "method", request.Method)
+	result, err := s.call(handler, params)
+	if len(request.ID) == 0 {
+		return nil
+	}
+	if err != nil {
+		var rpcErr *rpcError
+		if errors.As(err, &rpcErr) {
+			return errorResponse(request.ID, rpcErr.Code, rpcErr.Message)
+		}
+		return errorResponse(request.ID, rpcMiscError, err.Error())
+	}
+
+	encoded, err := json.Marshal(result)
+	if err != nil {
+		return errorResponse(request.ID, rpcInternalError, err.Error())
+	}
+
+	return &rpcResponse{JSONRPC: "2.0", Result: encoded, ID: request.ID}
+}
+
+// call runs handler, turning the panics of the chain code into errors
+func (s *rpcServer) call(handler rpcHandler, params []json.RawMessage) (result interface{}, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = &rpcError{rpcInternalError, fmt.Sprint(r)}
+		}
+	}()
+
+	return handler(s, params)
+}
+
+func errorResponse(id json.RawMessage, code int, message string) *rpcResponse {
+	if len(id) == 0 {
+		id = json.RawMessage("null")
+	}
+
+	return &rpcResponse{JSONRPC: "2.0", Error: &rpcError{code, message}, ID: id}
+}
+
+// stringParam returns the string param at index, failing when a required one is missing
+func stringParam(params []json.RawMessage, index int, name string, required bool) (string, error) {
+	var value string
+
+	if index >= len(params) {
+		if required {
+			return "", &rpcError{rpcInvalidParams, fmt.Sprintf("missing param %s", name)}
+		}
+		return "", nil
+	}
+	if err := json.Unmarshal(params[index], &value); err != nil {
+		return "", &rpcError{rpcInvalidParams, fmt.Sprintf("param %s must be a string", name)}
+	}
+
+	return value, nil
+}
+
+// intParam returns the integer param at index, failing when a required one is missing
+func intParam(params []json.RawMessage, index int, name string, required bool) (int, error) {
+	var value int
+
+	if index >= len(params) {
+		if required {
+			return 0, &rpcError{rpcInvalidParams, fmt.Sprintf("missing param %s", name)}
+		}
+		return 0, nil
+	}
+	if err := json.Unmarshal(params[index], &value); err != nil {
+		return 0, &rpcError{rpcInvalidParams, fmt.Sprintf("param %s must be an integer", name)}
+	}
+
+	return value, nil
+}
+
+// hexParam returns the hex encoded param at index
+func hexParam(params []json.RawMessage, index int, name string) ([]byte, error) {
+	value, err := stringParam(params, index, name, true)
+	if err != nil {
+		return nil, err
+	}
+
+	hash, err := hex.DecodeString(value)
+	if err != nil {
+		return nil, &rpcError{rpcInvalidParams, fmt.Sprintf("param %s must be hex", name)}
+	}
+
+	return hash, nil
+}
+
+// coinControlParams reads the coin control options of sendcoin, the coin selection
+// strategy, dust threshold and comma separated inputs, from the params at index
+func coinControlParams(params []json.RawMessage, index int) (*blockchainstruct.CoinControl, error) {
+	strategy, err := stringParam(params, index, "coinselect", false)
+	if err != nil {
+		return nil, err
+	}
+	dust, err := intParam(params, index+1, "dust", false)
+	if err != nil {
+		return nil, err
+	}
+	inputs, err := stringParam(params, index+2, "inputs", false)
+	if err != nil {
+		return nil, err
+	}
+
+	selector, err := blockchainstruct.NewCoinSelector(strategy, dust)
+	if err != nil {
+		return nil, &rpcError{rpcInvalidParams, err.Error()}
+	}
+	coinControl := &blockchainstruct.CoinControl{Selector: selector, DustThreshold: dust}
+	if inputs != "" {
+		coinControl.Outpoints, err = blockchainstruct.ParseOutpoints(inputs)
+		if err != nil {
+			return nil, &rpcError{rpcInvalidParams, err.Error()}
+		}
+	}
+
+	return coinControl, nil
+}
+
+// wallet returns the wallet of address from the wallet file of the node
+func (s *rpcServer) wallet(address string) (wallets.Wallet, error) {
+	ws, err := nodeWallets(s.nodeID)
+	if err != nil {
+		return wallets.Wallet{}, err
+	}
+
+	return ws.GetWallet(address)
+}
+
+// relay announces a transaction the node admitted to its mempool in the background, since
+// relaying it may mine a block, which the caller should not wait for
+func (s *rpcServer) relay(tx *blockchainstruct.Transaction) {
+	handlers.Add(1)
+	go func() {
+		defer handlers.Done()
+		defer flushOutbox()
+		nodeLock.Lock()
+		defer nodeLock.Unlock()
+
+		relayTx(tx, nodeAddress, s.bc)
+	}()
+}
+
+func rpcGetBlock(s *rpcServer, params []json.RawMessage) (interface{}, error) {
+	hash, err := hexParam(params, 0, "blockhash")
+	if err != nil {
+		return nil, err
+	}
+
+	nodeLock.RLock()
+	defer nodeLock.RUnlock()
+
+	block, err := s.bc.GetBlock(hash)
+	if err != nil {
+		return nil, err
+	}
+
+	return newBlockJSON(&block), nil
+}
+
+func rpcGetBlockHash(s *rpcServer, params []json.RawMessage) (interface{}, error) {
+	height, err := intParam(params, 0, "height", true)
+	if err != nil {
+		return nil, err
+	}
+
+	nodeLock.RLock()
+	defer nodeLock.RUnlock()
+
+	hash, err := s.bc.GetBlockHash(height)
+	if err != nil {
+		return nil, err
+	}
+
+	return hex.EncodeToString(hash), nil
+}
+
+func rpcGetBestBlockHash(s *rpcServer, params []json.RawMessage) (interface{}, error) {
+	nodeLock.RLock()
+	defer nodeLock.RUnlock()
+
+	return hex.EncodeToString(s.bc.GetBestHash()), nil
+}
+
+func rpcGetTransaction(s *rpcServer, params []json.RawMessage) (interface{}, error) {
+	txID, err := hexParam(params, 0, "txid")
+	if err != nil {
+		return nil, err
+	}
+
+	nodeLock.RLock()
+	defer nodeLock.RUnlock()
+
+	tx, inMempool := memPool[hex.EncodeToString(txID)]
+	if inMempool {
+		result := newTxJSON(&tx)
+		result.Mempool = true
+		return result, nil
+	}
+
+	tx, err = s.bc.FindTransaction(txID)
+	if err != nil {
+		return nil, err
+	}
+
+	return newTxJSON(&tx), nil
+}
+
+func rpcGetBalance(s *rpcServer, params []json.RawMessage) (interface{}, error) {
+	address, err := stringParam(params, 0, "address", true)
+	if err != nil {
+		return nil, err
+	}
+
+	pubKeyHash, err := wallets.DecodeAddress(address)
+	if err != nil {
+		return nil, &rpcError{rpcInvalidParams, fmt.Sprintf("address is not valid: %v", err)}
+	}
+
+	nodeLock.RLock()
+	defer nodeLock.RUnlock()
+
+	balance := 0
+	UTXOSet := blockchainstruct.UTXOSet{Blockchain: s.bc}
+	for _, out := range UTXOSet.FindUTXO(pubKeyHash) {
+		balance += out.Value
+	}
+
+	return balance, nil
+}
+
+// rpcSendToAddress pays amount from an address of the node's wallet. The optional params
+// after the amount are the coin control options of sendcoin
+func rpcSendToAddress(s *rpcServer, params []json.RawMessage) (interface{}, error) {
+	from, err := stringParam(params, 0, "from", true)
+	if err != nil {
+		return nil, err
+	}
+	to, err := stringParam(params, 1, "to", true)
+	if err != nil {
+		return nil, err
+	}
+	amount, err := intParam(params, 2, "amount", true)
+	if err != nil {
+		return nil, err
+	}
+	coinControl, err := coinControlParams(params, 3)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := wallets.ValidateAddress(from); err != nil {
+		return nil, &rpcError{rpcInvalidParams, fmt.Sprintf("sender address is not valid: %v", err)}
+	}
+	if err := wallets.ValidateAddress(to); err != nil {
+		return nil, &rpcError{rpcInvalidParams, fmt.Sprintf("recipient address is not valid: %v", err)}
+	}
+	if from == to {
+		return nil, &rpcError{rpcInvalidParams, "you cannot send coins to yourself"}
+	}
+	if amount <= 0 {
+		return nil, &rpcError{rpcInvalidParams, "amount must be positive"}
+	}
+
+	// the mempool is locked from picking the inputs until the transaction joins it
+	nodeLock.Lock()
+	defer nodeLock.Unlock()
+
+	wallet, err := s.wallet(from)
+	if err != nil {
+		return nil, err
+	}
+
+	UTXOSet := blockchainstruct.UTXOSet{Blockchain: s.bc}
+	coinControl.Spent = mempoolSpent()
+	tx := blockchainstruct.NewUTXOTTransaction(&wallet, to, amount, &UTXOSet, coinControl)
+	if err := admitTx(tx, nodeAddress, s.bc); err != nil {
+		return nil, err
+	}
+	s.relay(tx)
+
+	logging.Wallet.Info("created transaction", "txid", hex.EncodeToString(tx.ID), "from", from, "to", to, "amount", amount)
+	return hex.EncodeToString(tx.ID), nil
+}
+
+// rpcSendMany pays a list of {"address": ..., "amount": ...} recipients from an address
+// of the node's wallet in one transaction, with the coin control options of sendcoin
+func rpcSendMany(s *rpcServer, params []json.RawMessage) (interface{}, error) {
+	from, err := stringParam(params, 0, "from", true)
+	if err != nil {
+		return nil, err
+	}
+	if len(params) < 2 {
+		return nil, &rpcError{rpcInvalidParams, "missing param recipients"}
+	}
+	var recipients []blockchainstruct.Recipient
+	if err := json.Unmarshal(params[1], &recipients); err != nil {
+		return nil, &rpcError{rpcInvalidParams, "param recipients must be a list of address and amount objects"}
+	}
+	coinControl, err := coinControlParams(params, 2)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := wallets.ValidateAddress(from); err != nil {
+		return nil, &rpcError{rpcInvalidParams, fmt.Sprintf("sender address is not valid: %v", err)}
+	}
+	total, err := checkRecipients(from, recipients)
+	if err != nil {
+		return nil, &rpcError{rpcInvalidParams, err.Error()}
+	}
+
+	nodeLock.Lock()
+	defer nodeLock.Unlock()
+
+	wallet, err := s.wallet(from)
+	if err != nil {
+		return nil, err
+	}
+
+	UTXOSet := blockchainstruct.UTXOSet{Blockchain: s.bc}
+	coinControl.Spent = mempoolSpent()
+	tx := blockchainstruct.NewBatchTransaction(&wallet, recipients, &UTXOSet, coinControl)
+	if err := admitTx(tx, nodeAddress, s.bc); err != nil {
+		return nil, err
+	}
+	s.relay(tx)
+
+	logging.Wallet.Info("created transaction", "txid", hex.EncodeToString(tx.ID), "from", from, "recipients", len(recipients), "amount", total)
+	return hex.EncodeToString(tx.ID), nil
+}
+
+// rpcSendRawTransaction relays a hex encoded signed transaction once its inputs are
+// unspent, also by the mempool, and its signatures verify
+func rpcSendRawTransaction(s *rpcServer, params []json.RawMessage) (interface{}, error) {
+	data, err := hexParam(params, 0, "hexstring")
+	if err != nil {
+		return nil, err
+	}
+
+	tx := blockchainstruct.DeserializeTransaction(data)
+	if tx.IsCoinbase() {
+		return nil, &rpcError{rpcInvalidParams, "a coinbase transaction cannot be sent"}
+	}
+
+	nodeLock.Lock()
+	defer nodeLock.Unlock()
+
+	if err := admitTx(&tx, nodeAddress, s.bc); err != nil {
+		return nil, err
+	}
+	s.relay(&tx)
+
+	return hex.EncodeToString(tx.ID), nil
+}
+
+func rpcListUnspent(s *rpcServer, params []json.RawMessage) (interface{}, error) {
+	address, err := stringParam(params, 0, "address", true)
+	if err != nil {
+		return nil, err
+	}
+
+	pubKeyHash, err := wallets.DecodeAddress(address)
+	if err != nil {
+		return nil, &rpcError{rpcInvalidParams, fmt.Sprintf("address is not valid: %v", err)}
+	}
+
+	nodeLock.RLock()
+	defer nodeLock.RUnlock()
+
+	UTXOSet := blockchainstruct.UTXOSet{Blockchain: s.bc}
+	utxos := []utxoJSON{}
+	for _, utxo := range UTXOSet.ListUnspent(pubKeyHash) {
+		utxos = append(utxos, utxoJSON{hex.EncodeToString(utxo.TxID), utxo.VOut, utxo.Value, utxo.Height})
+	}
+
+	return utxos, nil
+}
+
+// rpcListTransactions returns a page of the wallet history of the node newest first,
+// optionally of a single address: count entries after skipping the skip newest
+func rpcListTransactions(s *rpcServer, params []json.RawMessage) (interface{}, error) {
+	address, err := stringParam(params, 0, "address", false)
+	if err != nil {
+		return nil, err
+	}
+	count := defaultTxCount
+	if len(params) > 1 {
+		count, err = intParam(params, 1, "count", true)
+		if err != nil {
+			return nil, err
+		}
+	}
+	skip, err := intParam(params, 2, "skip", false)
+	if err != nil {
+		return nil, err
+	}
+	if count <= 0 || skip < 0 {
+		return nil, &rpcError{rpcInvalidParams, "param count must be positive and skip not negative"}
+	}
+
+	// syncing saves the wallet file, which other calls read
+	nodeLock.Lock()
+	defer nodeLock.Unlock()
+
+	ws, err := wallets.NewWallets(s.nodeID)
+	if err != nil {
+		return nil, err
+	}
+	syncWallet(s.bc, ws)
+	ws.SaveToFile(s.nodeID)
+
+	return pageEntries(walletEntries(s.bc, ws, address), count, skip), nil
+}
+
+// rpcRescanWallet rebuilds the wallet history of the node from the whole chain, for
+// addresses imported into its wallet file, and returns what it found for each of them
+func rpcRescanWallet(s *rpcServer, params []json.RawMessage) (interface{}, error) {
+	if len(params) < 1 {
+		return nil, &rpcError{rpcInvalidParams, "missing param addresses"}
+	}
+	var addresses []string
+	if err := json.Unmarshal(params[0], &addresses); err != nil {
+		return nil, &rpcError{rpcInvalidParams, "param addresses must be a list of strings"}
+	}
+
+	nodeLock.Lock()
+	defer nodeLock.Unlock()
+
+	ws, err := wallets.NewWallets(s.nodeID)
+	if err != nil {
+		return nil, err
+	}
+	results := rescanWallet(s.bc, ws, addresses)
+	ws.SaveToFile(s.nodeID)
+
+	return results, nil
+}
+
+// rpcWalletPassphrase unlocks the encrypted wallet of the node for timeout seconds. Only
+// the derived key is kept, in memory, and it is wiped when the timeout passes
+func rpcWalletPassphrase(s *rpcServer, params []json.RawMessage) (interface{}, error) {
+	passphrase, err := stringParam(params, 0, "passphrase", true)
+	if err != nil {
+		return nil, err
+	}
+	timeout, err := intParam(params, 1, "timeout", true)
+	if err != nil {
+		return nil, err
+	}
+	if passphrase == "" {
+		return nil, &rpcError{rpcInvalidParams, "passphrase must not be empty"}
+	}
+	if timeout <= 0 {
+		return nil, &rpcError{rpcInvalidParams, "timeout must be positive"}
+	}
+
+	ws, err := wallets.NewWallets(s.nodeID)
+	if err != nil {
+		return nil, err
+	}
+	if err := ws.Unlock(passphrase); err != nil {
+		return nil, &rpcError{rpcMiscError, err.Error()}
+	}
+	key, err := ws.Key()
+	ws.Lock()
+	if err != nil {
+		return nil, err
+	}
+	holdWalletKey(key, time.Duration(timeout)*time.Second)
+
+	logging.Wallet.Info("wallet unlocked", "timeout", timeout)
+	return nil, nil
+}
+
+func rpcWalletLock(s *rpcServer, params []json.RawMessage) (interface{}, error) {
+	dropWalletKey()
+
+	logging.Wallet.Info("wallet locked")
+	return nil, nil
+}
+
+func rpcGetMempoolInfo(s *rpcServer, params []json.RawMessage) (interface{}, error) {
+	nodeLock.RLock()
+	defer nodeLock.RUnlock()
+
+	size := 0
+	for _, tx := range memPool {
+		size += len(tx.SerializeTransaction())
+	}
+
+	return map[string]int{"size": len(memPool), "bytes": size}, nil
+}
+
+func rpcGetPeerInfo(s *rpcServer, params []json.RawMessage) (interface{}, error) {
+	nodeLock.RLock()
+	defer nodeLock.RUnlock()
+
+	peers := []map[string]string{}
+	for _, node := range knownNodes {
+		if node != nodeAddress {
+			peers = append(peers, map[string]string{"addr": node})
+		}
+	}
+
+	return peers, nil
+}
+
+// rpcGenerate mines the given number of blocks right away on regtest, the first one
+// including the valid mempool transactions, and returns their hashes
+func rpcGenerate(s *rpcServer, params []json.RawMessage) (interface{}, error) {
+	blocks, err := intParam(params, 0, "blocks", true)
+	if err != nil {
+		return nil, err
+	}
+	address, err := stringParam(params, 1, "address", true)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := checkGenerate(blocks); err != nil {
+		return nil, &rpcError{rpcInvalidParams, err.Error()}
+	}
+	if err := wallets.ValidateAddress(address); err != nil {
+		return nil, &rpcError{rpcInvalidParams, fmt.Sprintf("address is not valid: %v", err)}
+	}
+
+	hashes := []string{}
+	for i := 0; i < blocks; i++ {
+		// peers and other calls get the node state between blocks
+		nodeLock.Lock()
+		block := mineBlock(s.bc, verifiedMempoolTxs(s.bc), address)
+		nodeLock.Unlock()
+		flushOutbox()
+		if block == nil {
+			return nil, &rpcError{rpcMiscError, "mining was cancelled, the node is shutting down"}
+		}
+		hashes = append(hashes, hex.EncodeToString(block.CurrHash))
+	}
+
+	return hashes, nil
+}
@@ -2,33 +2,83 @@ package cli
 
 import (
 	"bytes"
+	"context"
+	"encoding/binary"
 	"encoding/gob"
 	"encoding/hex"
-	"fmt"
+	"errors"
 	"io"
 	"io/ioutil"
 	"log"
+	"math/rand"
 	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
 
 	"github.com/cyprus09/blockchain/blockchainstruct"
+	"github.com/cyprus09/blockchain/chaincfg"
+	"github.com/cyprus09/blockchain/logging"
 )
 
 const (
 	protocol    = "tcp"
 	nodeVersion = 1
 	commandLen  = 12
+	magicLen    = 4
+	// shutdownTimeout bounds how long a stopping node waits for its handlers
+	shutdownTimeout = 10 * time.Second
+	// mempoolSaveInterval is how often a running node saves its mempool
+	mempoolSaveInterval = time.Minute
+	// gossipInterval is how often a running node looks for peers and advertises itself
+	gossipInterval = 2 * time.Minute
+	// targetPeers is how many peers the node connects to from its address manager
+	targetPeers = 8
+	// maxPeers bounds the peers, including those that connected to the node
+	maxPeers = 32
+	// maxAddrPerMsg bounds the addresses of an addr message
+	maxAddrPerMsg = 100
+	// addr messages of at most addrRelayMax addresses announce nodes, and those heard
+	// of within addrRelayAge are passed on to addrRelayPeers peers
+	addrRelayMax   = 10
+	addrRelayAge   = 10 * time.Minute
+	addrRelayPeers = 2
+	// peerTimeout bounds how long resolving, dialing or writing to a peer may take
+	peerTimeout = 5 * time.Second
 )
 
 var (
 	nodeAddress     string
 	miningAddress   string
-	knownNodes      = []string{"localhost:3000"}
 	blocksInTransit = [][]byte{}
 	memPool         = make(map[string]blockchainstruct.Transaction)
+	// knownNodes are the peers the node exchanges blocks and transactions with
+	knownNodes = []string{}
+	// seedNodes are the peers the node connects to when it has none
+	seedNodes []string
+	// handlers tracks the peer messages and RPC work in flight, for shutdown to wait on
+	handlers sync.WaitGroup
+	// errKnownTx is returned by admitTx for a transaction already in the mempool
+	errKnownTx = errors.New("transaction is already in the mempool")
+	// outbox holds the peer messages queued while nodeLock is held, which flushOutbox
+	// sends once it is released
+	outbox []outMessage
+	// answeredCommands are the messages answered with data sent to the address they name
+	answeredCommands = map[string]bool{"getaddr": true, "getblocks": true, "getdata": true, "version": true}
 )
 
-type address struct {
-	AddrList []string
+// outMessage is a peer message waiting in the outbox
+type outMessage struct {
+	address string
+	data    []byte
+}
+
+type addr struct {
+	AddrFrom string
+	AddrList []netAddress
 }
 
 type block struct {
@@ -36,6 +86,10 @@ type block struct {
 	Block    []byte
 }
 
+type getaddr struct {
+	AddrFrom string
+}
+
 type getblocks struct {
 	AddrFrom string
 }
@@ -83,24 +137,23 @@ func bytesToCommand(bytes []byte) string {
 	return string(command)
 }
 
-func extractCommand(request []byte) []byte {
-	return request[:commandLen]
+// networkMagic returns the bytes that start every message of the active network
+func networkMagic() []byte {
+	magic := make([]byte, magicLen)
+	binary.LittleEndian.PutUint32(magic, chaincfg.ActiveParams().Net)
+
+	return magic
 }
 
-func requestBlocks() {
-	for _, node := range knownNodes {
-		sendGetBlocks(node)
-	}
+func extractCommand(request []byte) []byte {
+	return request[:commandLen]
 }
 
-//lint:ignore U1000 Ignore unused function
-func sendAddress(addr string) {
-	nodes := address{knownNodes}
-	nodes.AddrList = append(nodes.AddrList, nodeAddress)
-	payload := gobEncode(nodes)
-	request := append(commandToBytes("address"), payload...)
+func sendAddr(address string, addrs []netAddress) {
+	payload := gobEncode(addr{nodeAddress, addrs})
+	request := append(commandToBytes("addr"), payload...)
 
-	sendData(addr, request)
+	sendData(address, request)
 }
 
 func sendBlock(address string, b *blockchainstruct.Block) {
@@ -111,27 +164,68 @@ func sendBlock(address string, b *blockchainstruct.Block) {
 	sendData(address, request)
 }
 
+// sendData queues data for address, to be sent by flushOutbox. On a running node
+// nodeLock must be held
 func sendData(address string, data []byte) {
-	conn, err := net.Dial(protocol, address)
-	if err != nil {
-		fmt.Printf("%s is not available\n", address)
-		var updatedNodes []string
+	outbox = append(outbox, outMessage{address, data})
+}
 
-		for _, node := range knownNodes {
-			if node != address {
-				updatedNodes = append(updatedNodes, node)
-			}
+// flushOutbox sends the queued peer messages, dropping the peers that cannot be reached.
+// nodeLock must not be held, so a slow or unreachable peer never stalls the node
+func flushOutbox() {
+	nodeLock.Lock()
+	messages := outbox
+	outbox = nil
+	nodeLock.Unlock()
+
+	unreachable := make(map[string]bool)
+	for _, m := range messages {
+		if unreachable[m.address] {
+			continue
 		}
-		knownNodes = updatedNodes
+		err := deliver(m.address, m.data)
 
-		return
+		nodeLock.Lock()
+		if err != nil {
+			logging.P2P.Warn("peer is not available", "addr", m.address, "err", err)
+			unreachable[m.address] = true
+			dropPeer(m.address)
+			addrMgr.failed(m.address)
+		} else {
+			addrMgr.good(m.address)
+		}
+		nodeLock.Unlock()
+	}
+}
+
+// deliver sends data to address, giving up after peerTimeout
+func deliver(address string, data []byte) error {
+	conn, err := net.DialTimeout(protocol, address, peerTimeout)
+	if err != nil {
+		return err
 	}
 	defer conn.Close()
 
-	_, err = io.Copy(conn, bytes.NewReader(data))
+	conn.SetDeadline(time.Now().Add(peerTimeout))
+	_, err = io.Copy(conn, io.MultiReader(bytes.NewReader(networkMagic()), bytes.NewReader(data)))
 	if err != nil {
-		log.Panic(err)
+		return err
 	}
+	nodeMetrics.messageOut(bytesToCommand(extractCommand(data)))
+
+	return nil
+}
+
+// dropPeer removes address from the peers
+func dropPeer(address string) {
+	var updatedNodes []string
+
+	for _, node := range knownNodes {
+		if node != address {
+			updatedNodes = append(updatedNodes, node)
+		}
+	}
+	knownNodes = updatedNodes
 }
 
 func sendInv(address, kind string, items [][]byte) {
@@ -141,6 +235,13 @@ func sendInv(address, kind string, items [][]byte) {
 	sendData(address, request)
 }
 
+func sendGetAddr(address string) {
+	payload := gobEncode(getaddr{nodeAddress})
+	request := append(commandToBytes("getaddr"), payload...)
+
+	sendData(address, request)
+}
+
 func sendGetBlocks(address string) {
 	payload := gobEncode(getblocks{nodeAddress})
 	request := append(commandToBytes("getblocks"), payload...)
@@ -170,9 +271,50 @@ func sendVersion(address string, bc *blockchainstruct.Blockchain) {
 	sendData(address, request)
 }
 
-func handleAddress(request []byte) {
+// handleAddr adds the addresses a peer sent to the address manager. Fresh ones from a
+// short announcement are passed on to a few other peers, and only the first time, so
+// an announcement spreads through the network once
+func handleAddr(request []byte) {
+	var buff bytes.Buffer
+	var payload addr
+
+	buff.Write(request[commandLen:])
+	dec := gob.NewDecoder(&buff)
+	err := dec.Decode(&payload)
+	if err != nil {
+		log.Panic(err)
+	}
+
+	if len(payload.AddrList) > maxAddrPerMsg {
+		logging.P2P.Warn("dropped oversized addr message", "from", payload.AddrFrom, "count", len(payload.AddrList))
+		return
+	}
+
+	var fresh []netAddress
+	for _, a := range payload.AddrList {
+		if a.Addr == nodeAddress {
+			continue
+		}
+		if addrMgr.add(a.Addr, a.Timestamp) && time.Since(time.Unix(a.Timestamp, 0)) < addrRelayAge {
+			fresh = append(fresh, a)
+		}
+	}
+
+	newAddrs, triedAddrs := addrMgr.counts()
+	logging.P2P.Debug("received addresses", "from", payload.AddrFrom, "count", len(payload.AddrList),
+		"fresh", len(fresh), "new", newAddrs, "tried", triedAddrs)
+
+	if len(payload.AddrList) <= addrRelayMax && len(fresh) > 0 {
+		for _, peer := range randomPeers(addrRelayPeers, payload.AddrFrom) {
+			sendAddr(peer, fresh)
+		}
+	}
+}
+
+// handleGetAddr answers with addresses of the address manager and the node itself
+func handleGetAddr(request []byte) {
 	var buff bytes.Buffer
-	var payload address
+	var payload getaddr
 
 	buff.Write(request[commandLen:])
 	dec := gob.NewDecoder(&buff)
@@ -181,9 +323,8 @@ func handleAddress(request []byte) {
 		log.Panic(err)
 	}
 
-	knownNodes = append(knownNodes, payload.AddrList...)
-	fmt.Printf("There are %d known nodes now.\n", len(knownNodes))
-	requestBlocks()
+	addrs := append(addrMgr.sample(maxAddrPerMsg-1), netAddress{nodeAddress, time.Now().Unix()})
+	sendAddr(payload.AddrFrom, addrs)
 }
 
 func handleBlock(request []byte, bc *blockchainstruct.Blockchain) {
@@ -200,10 +341,15 @@ func handleBlock(request []byte, bc *blockchainstruct.Blockchain) {
 	blockData := payload.Block
 	block :=blockchainstruct.DeserializeBlock(blockData)
 
-	fmt.Println("Received a new block.")
-	bc.AddBlock(block)
+	start := time.Now()
+	err = bc.AddBlock(block)
+	if err != nil {
+		logging.Chain.Warn("rejected block", "hash", hex.EncodeToString(block.CurrHash), "from", payload.AddrFrom, "err", err)
+		return
+	}
+	nodeMetrics.observeBlockValidation(time.Since(start))
 
-	fmt.Printf("Added block %x\n", block.CurrHash)
+	logging.Chain.Info("added block", "hash", hex.EncodeToString(block.CurrHash), "height", block.Height, "from", payload.AddrFrom)
 
 	if len(blocksInTransit) > 0 {
 		blockHash := blocksInTransit[0]
@@ -227,7 +373,7 @@ func handleInv(request []byte, bc *blockchainstruct.Blockchain) {
 		log.Panic(err)
 	}
 
-	fmt.Printf("Received inventory with %d %s\n", len(payload.Items), payload.Type)
+	logging.P2P.Debug("received inventory", "from", payload.AddrFrom, "type", payload.Type, "items", len(payload.Items))
 
 	if payload.Type == "block" {
 		blocksInTransit = payload.Items
@@ -308,49 +454,65 @@ func handleTx(request []byte, bc *blockchainstruct.Blockchain) {
 
 	txData := payload.Transaction
 	tx := blockchainstruct.DeserializeTransaction(txData)
-	memPool[hex.EncodeToString(tx.ID)] = tx
+	acceptTx(tx, payload.AddrFrom, bc)
+}
 
-	if nodeAddress == knownNodes[0] {
-		for _, node := range knownNodes {
-			if node != nodeAddress && node != payload.AddrFrom {
-				sendInv(node, "tx", [][]byte{tx.ID})
-			}
-		}
-	} else {
-		if len(memPool) >= 2 && len(miningAddress) > 0 {
-		MineTransactions:
-			var txs []*blockchainstruct.Transaction
+// acceptTx adds a transaction received from addrFrom to the mempool. A new transaction
+// is announced to the other peers, and a miner mines it
+func acceptTx(tx blockchainstruct.Transaction, addrFrom string, bc *blockchainstruct.Blockchain) {
+	err := admitTx(&tx, addrFrom, bc)
+	if err == errKnownTx {
+		return
+	}
+	if err != nil {
+		logging.Mempool.Warn("rejected transaction", "txid", hex.EncodeToString(tx.ID), "from", addrFrom, "err", err)
+		return
+	}
 
-			for id := range memPool {
-				tx := memPool[id]
-				if bc.VerifyTransaction(&tx) {
-					txs = append(txs, &tx)
-				}
-			}
+	relayTx(&tx, addrFrom, bc)
+}
 
-			if len(txs) == 0 {
-				fmt.Println("All transactions are invalid! Waiting for new ones...")
-				return
-			}
+// admitTx adds tx to the mempool unless it is already there, or it conflicts with the
+// mempool or the chainstate. nodeLock must be held
+func admitTx(tx *blockchainstruct.Transaction, addrFrom string, bc *blockchainstruct.Blockchain) error {
+	txID := hex.EncodeToString(tx.ID)
+	if _, known := memPool[txID]; known {
+		return errKnownTx
+	}
+
+	UTXOSet := blockchainstruct.UTXOSet{Blockchain: bc}
+	if err := checkMempoolTx(tx, &UTXOSet, mempoolSpent()); err != nil {
+		return err
+	}
+
+	logging.Mempool.Info("accepted transaction", "txid", txID, "from", addrFrom, "size", len(memPool)+1)
+	publishTxAccepted(tx)
+	memPool[txID] = *tx
 
-			cbTX := blockchainstruct.NewCoinbaseTx(miningAddress, "")
-			txs = append(txs, cbTX)
+	return nil
+}
 
-			newBlock := bc.MineBlock(txs)
-			UTXOSet := blockchainstruct.UTXOSet{Blockchain: bc}
-			UTXOSet.Reindex()
+// relayTx announces a transaction of the mempool to the peers other than addrFrom, and
+// mines the mempool on a miner. nodeLock must be held
+func relayTx(tx *blockchainstruct.Transaction, addrFrom string, bc *blockchainstruct.Blockchain) {
+	for _, node := range knownNodes {
+		if node != nodeAddress && node != addrFrom {
+			sendInv(node, "tx", [][]byte{tx.ID})
+		}
+	}
 
-			fmt.Println("New block is mined!")
+	if len(miningAddress) > 0 {
+		if len(memPool) >= 2 {
+		MineTransactions:
+			txs := verifiedMempoolTxs(bc)
 
-			for _, tx := range txs {
-				txID := hex.EncodeToString(tx.ID)
-				delete(memPool, txID)
+			if len(txs) == 0 {
+				logging.Mempool.Warn("all transactions are invalid, waiting for new ones", "size", len(memPool))
+				return
 			}
 
-			for _, node := range knownNodes {
-				if node != nodeAddress {
-					sendInv(node, "block", [][]byte{newBlock.CurrHash})
-				}
+			if mineBlock(bc, txs, miningAddress) == nil {
+				return
 			}
 
 			if len(memPool) > 0 {
@@ -360,6 +522,58 @@ func handleTx(request []byte, bc *blockchainstruct.Blockchain) {
 	}
 }
 
+// verifiedMempoolTxs returns the mempool transactions that can go in the next block: those
+// with valid signatures spending unspent outputs, leaving out all but one of the
+// transactions that spend the same output
+func verifiedMempoolTxs(bc *blockchainstruct.Blockchain) []*blockchainstruct.Transaction {
+	var txs []*blockchainstruct.Transaction
+	UTXOSet := blockchainstruct.UTXOSet{Blockchain: bc}
+	spent := make(map[string]bool)
+
+	for id := range memPool {
+		tx := memPool[id]
+		if err := checkMempoolTx(&tx, &UTXOSet, spent); err != nil {
+			logging.Mempool.Debug("left transaction out of the block", "txid", id, "err", err)
+			continue
+		}
+
+		markSpent(&tx, spent)
+		txs = append(txs, &tx)
+	}
+
+	return txs
+}
+
+// mineBlock mines txs and a coinbase paying rewardAddress into a new block, takes them
+// out of the mempool and announces the block to the peers. It returns nil when mining
+// was cancelled
+func mineBlock(bc *blockchainstruct.Blockchain, txs []*blockchainstruct.Transaction, rewardAddress string) *blockchainstruct.Block {
+	cbTX := blockchainstruct.NewCoinbaseTx(rewardAddress, "", bc.GetBestHeight()+1)
+	txs = append(txs, cbTX)
+
+	newBlock := bc.MineBlock(txs)
+	if newBlock == nil {
+		return nil
+	}
+	UTXOSet := blockchainstruct.UTXOSet{Blockchain: bc}
+	UTXOSet.Reindex()
+
+	logging.Miner.Info("mined block", "hash", hex.EncodeToString(newBlock.CurrHash), "height", newBlock.Height, "txs", len(txs))
+
+	for _, tx := range txs {
+		txID := hex.EncodeToString(tx.ID)
+		delete(memPool, txID)
+	}
+
+	for _, node := range knownNodes {
+		if node != nodeAddress {
+			sendInv(node, "block", [][]byte{newBlock.CurrHash})
+		}
+	}
+
+	return newBlock
+}
+
 func handleVersion(request []byte, bc *blockchainstruct.Blockchain) {
 	var buff bytes.Buffer
 	var payload version
@@ -380,8 +594,16 @@ func handleVersion(request []byte, bc *blockchainstruct.Blockchain) {
 		sendVersion(payload.AddrFrom, bc)
 	}
 
-	if !nodeIsKnown(payload.AddrFrom) {
+	if payload.AddrFrom != nodeAddress && !nodeIsKnown(payload.AddrFrom) && len(knownNodes) < maxPeers {
 		knownNodes = append(knownNodes, payload.AddrFrom)
+
+		// announce the new peer to a few others
+		announcement := []netAddress{{payload.AddrFrom, time.Now().Unix()}}
+		addrMgr.add(payload.AddrFrom, announcement[0].Timestamp)
+		for _, peer := range randomPeers(addrRelayPeers, payload.AddrFrom) {
+			sendAddr(peer, announcement)
+		}
+		sendGetAddr(payload.AddrFrom)
 	}
 }
 
@@ -390,12 +612,33 @@ func handleConnenction(conn net.Conn, bc *blockchainstruct.Blockchain) {
 	if err != nil {
 		log.Panic(err)
 	}
+	if len(request) < magicLen+commandLen || !bytes.Equal(request[:magicLen], networkMagic()) {
+		logging.P2P.Warn("dropped message from another network", "addr", conn.RemoteAddr().String())
+		conn.Close()
+		return
+	}
+	request = request[magicLen:]
 	command := bytesToCommand(extractCommand(request))
-	fmt.Printf("Receieved %s command\n", command)
+	logging.P2P.Debug("received message", "command", command, "addr", conn.RemoteAddr().String())
+
+	// resolving the sender may be slow, so it is checked before taking nodeLock
+	if answeredCommands[command] {
+		if from := messageSender(request); !sentFrom(from, conn.RemoteAddr()) {
+			logging.P2P.Warn("dropped message from a host other than its sender", "command", command, "from", from, "addr", conn.RemoteAddr().String())
+			conn.Close()
+			return
+		}
+	}
+
+	defer flushOutbox()
+	nodeLock.Lock()
+	defer nodeLock.Unlock()
 
 	switch command {
-	case "address":
-		handleAddress(request)
+	case "addr":
+		handleAddr(request)
+	case "getaddr":
+		handleGetAddr(request)
 	case "block":
 		handleBlock(request, bc)
 	case "inv":
@@ -409,37 +652,271 @@ func handleConnenction(conn net.Conn, bc *blockchainstruct.Blockchain) {
 	case "version":
 		handleVersion(request, bc)
 	default:
-		fmt.Println("Unknown command!")
+		logging.P2P.Warn("unknown command", "command", command, "addr", conn.RemoteAddr().String())
+		// peers choose the command, so only known ones get a label of their own
+		command = "unknown"
 	}
+	nodeMetrics.messageIn(command)
 
 	conn.Close()
 }
 
-// StartServer starts a node
-func StartServer(nodeID, minerAddress string) {
-	nodeAddress = fmt.Sprintf("localhost:%s", nodeID)
-	miningAddress = minerAddress
-	ln, err := net.Listen(protocol, nodeAddress)
+// StartServer starts a node with the settings of config
+func StartServer(nodeID string, config *Config) {
+	nodeAddress = config.externalAddr()
+	miningAddress = config.MiningAddr
+	seedNodes = config.seeds()
+	peers, err := loadPeers(nodeID)
+	if err != nil {
+		logging.P2P.Warn("cannot read the saved peers", "err", err)
+	}
+	addrMgr.load(peers)
+
+	ln, err := net.Listen(protocol, config.Listen)
 	if err != nil {
 		log.Panic(err)
 	}
 	defer ln.Close()
 
-	bc := blockchainstruct.NewBlockchain(nodeID)
+	bc := openBlockchain(nodeID)
+	bc.Subscribe(publishChainEvent)
 
-	if nodeAddress != knownNodes[0] {
-		sendVersion(knownNodes[0], bc)
+	loaded, dropped, err := loadMempool(nodeID, bc)
+	if err != nil {
+		logging.Mempool.Warn("cannot read the saved mempool", "err", err)
+	} else if loaded+dropped > 0 {
+		logging.Mempool.Info("loaded mempool", "txs", loaded, "dropped", dropped)
 	}
 
+	rpc := startRPCServer(config, nodeID, bc)
+
+	stopping := make(chan struct{})
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		sig := <-signals
+		// a second signal kills the node right away
+		signal.Stop(signals)
+		logging.P2P.Info("shutting down", "signal", sig.String())
+
+		close(stopping)
+		ln.Close()
+	}()
+	go saveMempoolPeriodically(nodeID, stopping)
+
+	nodeLock.Lock()
+	discoverPeers(bc)
+	nodeLock.Unlock()
+	flushOutbox()
+	go gossipPeriodically(bc, stopping)
+
 	for {
 		conn, err := ln.Accept()
 		if err != nil {
-			log.Panic(err)
+			select {
+			case <-stopping:
+				shutdown(nodeID, bc, rpc)
+				return
+			default:
+				log.Panic(err)
+			}
+		}
+
+		handlers.Add(1)
+		go func() {
+			defer handlers.Done()
+			handleConnenction(conn, bc)
+		}()
+	}
+}
+
+// discoverPeers connects to addresses of the address manager until the node has
+// targetPeers peers, starting with the seeds when it has none
+func discoverPeers(bc *blockchainstruct.Blockchain) {
+	if len(knownNodes) == 0 {
+		for _, seed := range seedNodes {
+			if seed != nodeAddress {
+				connectPeer(seed, bc)
+			}
+		}
+	}
+
+	for i := 0; i < targetPeers && len(knownNodes) < targetPeers; i++ {
+		peer := addrMgr.pick(func(a string) bool {
+			return a == nodeAddress || nodeIsKnown(a)
+		})
+		if peer == "" {
+			return
+		}
+		connectPeer(peer, bc)
+	}
+}
+
+// connectPeer makes address a peer, which answers with its height and addresses
+func connectPeer(address string, bc *blockchainstruct.Blockchain) {
+	knownNodes = append(knownNodes, address)
+
+	sendVersion(address, bc)
+	sendGetAddr(address)
+}
+
+// randomPeers returns up to n random peers other than except
+func randomPeers(n int, except string) []string {
+	var peers []string
+
+	for _, node := range knownNodes {
+		if node != nodeAddress && node != except {
+			peers = append(peers, node)
+		}
+	}
+	rand.Shuffle(len(peers), func(i, j int) { peers[i], peers[j] = peers[j], peers[i] })
+
+	if len(peers) > n {
+		peers = peers[:n]
+	}
+	return peers
+}
+
+// gossipPeriodically looks for more peers, asks one for addresses and advertises the
+// node to another every gossipInterval until stopping is closed
+func gossipPeriodically(bc *blockchainstruct.Blockchain, stopping <-chan struct{}) {
+	ticker := time.NewTicker(gossipInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			nodeLock.Lock()
+			discoverPeers(bc)
+			for _, peer := range randomPeers(1, "") {
+				sendGetAddr(peer)
+			}
+			for _, peer := range randomPeers(1, "") {
+				sendAddr(peer, []netAddress{{nodeAddress, time.Now().Unix()}})
+			}
+			nodeLock.Unlock()
+			flushOutbox()
+		case <-stopping:
+			return
 		}
-		go handleConnenction(conn, bc)
 	}
 }
 
+// saveMempoolPeriodically saves the mempool every mempoolSaveInterval until stopping is
+// closed, so a node that crashes loses only the latest transactions
+func saveMempoolPeriodically(nodeID string, stopping <-chan struct{}) {
+	ticker := time.NewTicker(mempoolSaveInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			nodeLock.Lock()
+			err := saveMempool(nodeID)
+			nodeLock.Unlock()
+			if err != nil {
+				logging.Mempool.Error("cannot save the mempool", "err", err)
+			}
+		case <-stopping:
+			return
+		}
+	}
+}
+
+// shutdown cancels mining, stops the RPC server and waits for the handlers in flight,
+// then saves the mempool and the peers and closes the database
+func shutdown(nodeID string, bc *blockchainstruct.Blockchain, rpc *http.Server) {
+	blockchainstruct.CancelMining()
+
+	ctx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+
+	err := rpc.Shutdown(ctx)
+	if err != nil {
+		logging.RPC.Warn("RPC server did not stop cleanly", "err", err)
+	}
+
+	drained := make(chan struct{})
+	go func() {
+		handlers.Wait()
+		close(drained)
+	}()
+
+	select {
+	case <-drained:
+		nodeLock.Lock()
+	case <-ctx.Done():
+		logging.P2P.Warn("handlers still running after the shutdown timeout")
+		// a handler stuck on a peer may hold the node state forever
+		if !nodeLock.TryLock() {
+			logging.P2P.Error("exiting without saving the node state")
+			return
+		}
+	}
+	defer nodeLock.Unlock()
+
+	if err := saveMempool(nodeID); err != nil {
+		logging.Mempool.Error("cannot save the mempool", "err", err)
+	} else {
+		logging.Mempool.Info("saved mempool", "txs", len(memPool))
+	}
+	if err := savePeers(nodeID); err != nil {
+		logging.P2P.Error("cannot save the peers", "err", err)
+	} else {
+		newAddrs, triedAddrs := addrMgr.counts()
+		logging.P2P.Info("saved peers", "new", newAddrs, "tried", triedAddrs)
+	}
+
+	if err := bc.DB.Close(); err != nil {
+		logging.Chain.Error("cannot close the database", "err", err)
+		return
+	}
+	logging.P2P.Info("node stopped")
+}
+
+// sentFrom tells whether addrFrom, the address a message claims to come from, is on the
+// host the message arrived from. Messages answered with data are dropped otherwise, so
+// nobody can aim the answers at another host
+func sentFrom(addrFrom string, remote net.Addr) bool {
+	host, _, err := net.SplitHostPort(addrFrom)
+	if err != nil {
+		return false
+	}
+	remoteHost, _, err := net.SplitHostPort(remote.String())
+	if err != nil {
+		return false
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), peerTimeout)
+	defer cancel()
+
+	ips, err := net.DefaultResolver.LookupHost(ctx, host)
+	if err != nil {
+		return false
+	}
+	for _, ip := range ips {
+		if net.ParseIP(ip).Equal(net.ParseIP(remoteHost)) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// messageSender returns the address a message claims to come from, empty when it names none
+func messageSender(request []byte) string {
+	var payload struct {
+		AddrFrom string
+	}
+
+	dec := gob.NewDecoder(bytes.NewReader(request[commandLen:]))
+	if err := dec.Decode(&payload); err != nil {
+		return ""
+	}
+
+	return payload.AddrFrom
+}
+
 func gobEncode(data interface{}) []byte {
 	var buff bytes.Buffer
 
@@ -0,0 +1,85 @@
+package cli
+
+import (
+	"bytes"
+	"fmt"
+
+	"github.com/cyprus09/blockchain/blockchainstruct"
+	"github.com/cyprus09/blockchain/wallets"
+)
+
+// syncWallet folds the blocks the wallet history has not seen yet into it. When the block
+// it last saw is no longer on the main chain the history is rebuilt from genesis
+func syncWallet(bc *blockchainstruct.Blockchain, ws *wallets.Wallets) {
+	history := ws.History()
+	synced := history.SyncedHeight < 0
+	var pending []*blockchainstruct.Block
+
+	bci := bc.Iterator()
+	for {
+		block := bci.Next()
+
+		if block.Height == history.SyncedHeight {
+			synced = bytes.Equal(block.CurrHash, history.SyncedHash)
+			break
+		}
+		pending = append(pending, block)
+
+		if len(block.PrevBlockHash) == 0 {
+			break
+		}
+	}
+
+	if !synced {
+		// the block the history ends at is no longer on the main chain
+		ws.ResetHistory()
+		syncWallet(bc, ws)
+		return
+	}
+
+	pubKeyHashes := ws.PubKeyHashes()
+	for i := len(pending) - 1; i >= 0; i-- {
+		block := pending[i]
+
+		for _, tx := range block.Transactions {
+			recordWalletTx(history, pubKeyHashes, block, tx)
+		}
+		history.SyncedHash = block.CurrHash
+		history.SyncedHeight = block.Height
+	}
+}
+
+// recordWalletTx adds tx to the history when it pays to or spends from a wallet address
+func recordWalletTx(history *wallets.WalletHistory, pubKeyHashes map[string]string, block *blockchainstruct.Block, tx *blockchainstruct.Transaction) {
+	wtx := &wallets.WalletTx{
+		TxID:      tx.ID,
+		BlockHash: block.CurrHash,
+		Height:    block.Height,
+		Time:      block.Timestamp,
+		Coinbase:  tx.IsCoinbase(),
+		Received:  make(map[string]int),
+		Sent:      make(map[string]int),
+	}
+
+	if !tx.IsCoinbase() {
+		for _, in := range tx.VIn {
+			outpoint := fmt.Sprintf("%x:%d", in.TxId, in.VOut)
+
+			if out, ok := history.Outputs[outpoint]; ok {
+				wtx.Sent[out.Address] += out.Value
+				delete(history.Outputs, outpoint)
+			}
+		}
+	}
+
+	for outIdx, out := range tx.VOut {
+		if address, ok := pubKeyHashes[string(out.PubKeyHash)]; ok {
+			wtx.Received[address] += out.Value
+			history.Outputs[fmt.Sprintf("%x:%d", tx.ID, outIdx)] = wallets.WalletOutput{Address: address, Value: out.Value}
+		}
+	}
+
+	if len(wtx.Received) > 0 || len(wtx.Sent) > 0 {
+		history.Record(wtx)
+	}
+}
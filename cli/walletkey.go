@@ -0,0 +1,82 @@
+package cli
+
+import (
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/cyprus09/blockchain/wallets"
+)
+
+// walletKey holds the derived key of the encrypted wallet while walletpassphrase keeps
+// it unlocked on the running node. It never leaves the memory of the node
+var walletKey struct {
+	mu    sync.Mutex
+	key   []byte
+	timer *time.Timer
+}
+
+// holdWalletKey keeps key until timeout passes, replacing the key of an earlier call
+func holdWalletKey(key []byte, timeout time.Duration) {
+	walletKey.mu.Lock()
+	defer walletKey.mu.Unlock()
+
+	wipeWalletKey()
+	walletKey.key = key
+	walletKey.timer = time.AfterFunc(timeout, dropWalletKey)
+}
+
+// dropWalletKey locks the wallet of the node again
+func dropWalletKey() {
+	walletKey.mu.Lock()
+	defer walletKey.mu.Unlock()
+
+	wipeWalletKey()
+}
+
+// wipeWalletKey overwrites the held key and stops its timer. walletKey.mu must be held
+func wipeWalletKey() {
+	if walletKey.timer != nil {
+		walletKey.timer.Stop()
+		walletKey.timer = nil
+	}
+	for i := range walletKey.key {
+		walletKey.key[i] = 0
+	}
+	walletKey.key = nil
+}
+
+// nodeWallets loads the wallet file of the node, unlocked while walletpassphrase holds its key
+func nodeWallets(nodeID string) (*wallets.Wallets, error) {
+	ws, err := wallets.NewWallets(nodeID)
+	if err != nil {
+		return nil, err
+	}
+
+	walletKey.mu.Lock()
+	defer walletKey.mu.Unlock()
+
+	if ws.IsLocked() && walletKey.key != nil {
+		err = ws.UnlockWithKey(walletKey.key)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return ws, nil
+}
+
+// unlockWallets asks for the passphrase of an encrypted wallet that a command without a
+// running node needs the private keys of
+func unlockWallets(ws *wallets.Wallets) {
+	if !ws.IsLocked() {
+		return
+	}
+
+	err := ws.Unlock(readPassphrase("Wallet passphrase: "))
+	if err != nil {
+		fmt.Printf("ERROR: %v\n", err)
+		os.Exit(1)
+	}
+}
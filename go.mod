@@ -1,16 +1,18 @@
 module github.com/cyprus09/blockchain
 
-go 1.18
+go 1.21
 
 require (
 	github.com/boltdb/bolt v1.3.1
+	github.com/decred/dcrd/dcrec/secp256k1/v4 v4.0.1
+	github.com/tyler-smith/go-bip39 v1.1.0
 	golang.org/x/crypto v0.24.0
+	golang.org/x/net v0.26.0
 )
 
 require (
 	github.com/go-logr/logr v1.4.2 // indirect
 	github.com/google/go-cmp v0.6.0 // indirect
-	golang.org/x/net v0.26.0 // indirect
 	golang.org/x/text v0.16.0 // indirect
 	google.golang.org/protobuf v1.34.2 // indirect
 	gopkg.in/yaml.v3 v3.0.1 // indirect
@@ -0,0 +1,71 @@
+// Package logging provides the leveled, structured loggers of the node subsystems
+package logging
+
+import (
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"strings"
+)
+
+// Subsystem loggers. Every record they write carries a subsystem attribute
+var (
+	Chain   = newLogger("chain")
+	P2P     = newLogger("p2p")
+	Mempool = newLogger("mempool")
+	Miner   = newLogger("miner")
+	Wallet  = newLogger("wallet")
+	RPC     = newLogger("rpc")
+)
+
+var (
+	level                = new(slog.LevelVar)
+	handler slog.Handler = slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: level})
+)
+
+func newLogger(subsystem string) *slog.Logger {
+	return slog.New(handler).With("subsystem", subsystem)
+}
+
+// ParseLevel turns debug, info, warn or error into a level
+func ParseLevel(name string) (slog.Level, error) {
+	var l slog.Level
+
+	err := l.UnmarshalText([]byte(strings.ToLower(name)))
+	if err != nil {
+		return 0, fmt.Errorf("unknown log level %q, expected debug, info, warn or error", name)
+	}
+
+	return l, nil
+}
+
+// Configure sets the level of every subsystem and writes the records to w, as JSON
+// when format is json and as key=value text when it is text. It must be called
+// before the loggers are used by other goroutines
+func Configure(w io.Writer, levelName, format string) error {
+	l, err := ParseLevel(levelName)
+	if err != nil {
+		return err
+	}
+
+	options := &slog.HandlerOptions{Level: level}
+	switch format {
+	case "text":
+		handler = slog.NewTextHandler(w, options)
+	case "json":
+		handler = slog.NewJSONHandler(w, options)
+	default:
+		return fmt.Errorf("unknown log format %q, expected text or json", format)
+	}
+	level.Set(l)
+
+	Chain = newLogger("chain")
+	P2P = newLogger("p2p")
+	Mempool = newLogger("mempool")
+	Miner = newLogger("miner")
+	Wallet = newLogger("wallet")
+	RPC = newLogger("rpc")
+
+	return nil
+}
@@ -2,9 +2,17 @@ package utils
 
 import (
 	"bytes"
+	"crypto/sha256"
+	"errors"
+	"fmt"
 	"math/big"
 )
 
+const base58ChecksumLen = 4
+
+// ErrChecksum is returned when Base58Check data does not match its checksum
+var ErrChecksum = errors.New("Base58Check checksum mismatch")
+
 var b58alphabet = []byte("123456789ABCDEFGHJKLMNPQRSTUVWXYZabcdefghijkmnopqrstuvwxyz")
 
 // Base58Encode encodes a byte array to Base58
@@ -23,7 +31,7 @@ func Base58Encode(input []byte) []byte {
 	}
 
 	ReverseBytes(result)
-	for b := range input {
+	for _, b := range input {
 		if b == 0x00 {
 			result = append([]byte{b58alphabet[0]}, result...)
 		} else {
@@ -34,19 +42,24 @@ func Base58Encode(input []byte) []byte {
 }
 
 // Base58Decode decodes Base58-encoded data
-func Base58Decode(input []byte) []byte {
+func Base58Decode(input []byte) ([]byte, error) {
 	result := big.NewInt(0)
 	zeroBytes := 0
 
-	for b := range input {
-		if b == 0x00 {
+	for _, b := range input {
+		if b == b58alphabet[0] {
 			zeroBytes++
+		} else {
+			break
 		}
 	}
 
 	payload := input[zeroBytes:]
-	for _, b := range payload {
+	for i, b := range payload {
 		charIndex := bytes.IndexByte(b58alphabet, b)
+		if charIndex < 0 {
+			return nil, fmt.Errorf("invalid Base58 character %q at position %d", b, zeroBytes+i)
+		}
 		result.Mul(result, big.NewInt(58))
 		result.Add(result, big.NewInt(int64(charIndex)))
 	}
@@ -54,5 +67,38 @@ func Base58Decode(input []byte) []byte {
 	decoded := result.Bytes()
 	decoded = append(bytes.Repeat([]byte{byte(0x00)}, zeroBytes), decoded...)
 
-	return decoded
+	return decoded, nil
+}
+
+// Base58CheckEncode appends the first 4 bytes of the double SHA-256 of input and encodes the result to Base58
+func Base58CheckEncode(input []byte) []byte {
+	payload := append(append([]byte{}, input...), base58Checksum(input)...)
+
+	return Base58Encode(payload)
+}
+
+// Base58CheckDecode decodes Base58Check data and returns it without its checksum
+func Base58CheckDecode(input []byte) ([]byte, error) {
+	decoded, err := Base58Decode(input)
+	if err != nil {
+		return nil, err
+	}
+	if len(decoded) < base58ChecksumLen {
+		return nil, fmt.Errorf("Base58Check data of %d bytes is shorter than its checksum", len(decoded))
+	}
+
+	payload, sum := decoded[:len(decoded)-base58ChecksumLen], decoded[len(decoded)-base58ChecksumLen:]
+	if !bytes.Equal(base58Checksum(payload), sum) {
+		return nil, ErrChecksum
+	}
+
+	return payload, nil
+}
+
+// base58Checksum returns the first 4 bytes of the double SHA-256 of payload
+func base58Checksum(payload []byte) []byte {
+	firstSHA := sha256.Sum256(payload)
+	secondSHA := sha256.Sum256(firstSHA[:])
+
+	return secondSHA[:base58ChecksumLen]
 }
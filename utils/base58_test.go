@@ -0,0 +1,92 @@
+package utils
+
+import (
+	"encoding/hex"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+// base58Vectors are the hex, Base58 pairs of Bitcoin Core's base58_encode_decode.json
+var base58Vectors = [][2]string{
+	{"", ""},
+	{"61", "2g"},
+	{"626262", "a3gV"},
+	{"636363", "aPEr"},
+	{"73696d706c792061206c6f6e6720737472696e67", "2cFupjhnEsSn59qHXstmK2ffpLv2"},
+	{"00eb15231dfceb60925886b67d065299925915aeb172c06647", "1NS17iag9jJgTHD1VXjvLCEnZuQ3rJDE9L"},
+	{"516b6fcd0f", "ABnLTmg"},
+	{"bf4f89001e670274dd", "3SEo3LWLoPntC"},
+	{"572e4794", "3EFU7m"},
+	{"ecac89cad93923c02321", "EJDM8drfXA6uyA"},
+	{"10c8511e", "Rt5zm"},
+	{"00000000000000000000", "1111111111"},
+	{"000111d38e5fc9071ffcd20b4a763cc9ae4f252bb4e48fd66a835e252ada93ff480d6dd43dc62a641155a5", "123456789ABCDEFGHJKLMNPQRSTUVWXYZabcdefghijkmnopqrstuvwxyz"},
+}
+
+var _ = Describe("Base58", func() {
+	Describe("Base58Encode", func() {
+		It("should encode the reference vectors", func() {
+			for _, vector := range base58Vectors {
+				data, err := hex.DecodeString(vector[0])
+				Expect(err).NotTo(HaveOccurred())
+
+				Expect(string(Base58Encode(data))).To(Equal(vector[1]), "encoding %s", vector[0])
+			}
+		})
+	})
+
+	Describe("Base58Decode", func() {
+		It("should decode the reference vectors", func() {
+			for _, vector := range base58Vectors {
+				data, err := Base58Decode([]byte(vector[1]))
+				Expect(err).NotTo(HaveOccurred())
+
+				Expect(hex.EncodeToString(data)).To(Equal(vector[0]), "decoding %s", vector[1])
+			}
+		})
+
+		It("should keep one zero byte per leading 1", func() {
+			data, err := Base58Decode([]byte("111z"))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(data).To(Equal([]byte{0x00, 0x00, 0x00, 0x39}))
+		})
+
+		It("should reject characters outside the alphabet", func() {
+			for _, invalid := range []string{"0", "O", "I", "l", "3mJr0", "3SEo3LWLoPntC ", "x\x00"} {
+				_, err := Base58Decode([]byte(invalid))
+				Expect(err).To(HaveOccurred(), "decoding %q", invalid)
+			}
+		})
+	})
+
+	Describe("Base58Check", func() {
+		It("should decode addresses and WIF keys", func() {
+			payload, err := Base58CheckDecode([]byte("1A1zP1eP5QGefi2DMPTfTL5SLmv7DivfNa"))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(hex.EncodeToString(payload)).To(Equal("0062e907b15cbf27d5425399ebf6f0fb50ebb88f18"))
+
+			payload, err = Base58CheckDecode([]byte("5HueCGU8rMjxEXxiPuD5BDku4MkFqeZyd4dZ1jvhTVqvbTLvyTJ"))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(hex.EncodeToString(payload)).To(Equal("800c28fca386c7a227600b2fe50b7cae11ec86d3bf1fbe471be89827e19d72aa1d"))
+		})
+
+		It("should round trip payloads with leading zero bytes", func() {
+			payload := []byte{0x00, 0x00, 0x01, 0x02}
+
+			decoded, err := Base58CheckDecode(Base58CheckEncode(payload))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(decoded).To(Equal(payload))
+		})
+
+		It("should reject a wrong checksum", func() {
+			_, err := Base58CheckDecode([]byte("1A1zP1eP5QGefi2DMPTfTL5SLmv7DivfNb"))
+			Expect(err).To(Equal(ErrChecksum))
+		})
+
+		It("should reject data shorter than the checksum", func() {
+			_, err := Base58CheckDecode([]byte("2g"))
+			Expect(err).To(HaveOccurred())
+		})
+	})
+})
@@ -0,0 +1,147 @@
+package utils
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+const bech32Charset = "qpzry9x8gf2tvdw0s3jn54khce6mua7l"
+
+var bech32Generator = []uint32{0x3b6a57b2, 0x26508e6d, 0x1ea119fa, 0x3d4233dd, 0x2a1462b3}
+
+// Bech32Encode encodes 5 bit groups as a BIP173 Bech32 string with human readable part hrp
+func Bech32Encode(hrp string, data []byte) (string, error) {
+	for _, b := range data {
+		if b >= 32 {
+			return "", fmt.Errorf("bech32 data value %d does not fit in 5 bits", b)
+		}
+	}
+
+	hrp = strings.ToLower(hrp)
+	values := append(append([]byte{}, data...), bech32Checksum(hrp, data)...)
+
+	var result strings.Builder
+	result.WriteString(hrp)
+	result.WriteByte('1')
+	for _, v := range values {
+		result.WriteByte(bech32Charset[v])
+	}
+
+	return result.String(), nil
+}
+
+// Bech32Decode splits a Bech32 string into its human readable part and 5 bit groups,
+// checking its characters, case and checksum
+func Bech32Decode(bech string) (string, []byte, error) {
+	if len(bech) > 90 {
+		return "", nil, fmt.Errorf("bech32 string has length %d, at most 90 allowed", len(bech))
+	}
+	if strings.ToLower(bech) != bech && strings.ToUpper(bech) != bech {
+		return "", nil, errors.New("bech32 string mixes upper and lower case")
+	}
+	bech = strings.ToLower(bech)
+
+	sep := strings.LastIndexByte(bech, '1')
+	if sep < 1 || sep+7 > len(bech) {
+		return "", nil, errors.New("bech32 string has no valid separator")
+	}
+
+	hrp := bech[:sep]
+	for i := 0; i < len(hrp); i++ {
+		if hrp[i] < 33 || hrp[i] > 126 {
+			return "", nil, fmt.Errorf("bech32 human readable part has invalid character %q", hrp[i])
+		}
+	}
+
+	var values []byte
+	for i := sep + 1; i < len(bech); i++ {
+		v := strings.IndexByte(bech32Charset, bech[i])
+		if v < 0 {
+			return "", nil, fmt.Errorf("bech32 string has invalid character %q at position %d", bech[i], i)
+		}
+		values = append(values, byte(v))
+	}
+
+	if bech32Polymod(append(bech32HRPExpand(hrp), values...)) != 1 {
+		return "", nil, errors.New("bech32 checksum mismatch")
+	}
+
+	return hrp, values[:len(values)-6], nil
+}
+
+// ConvertBits regroups data from fromBits to toBits wide groups. With pad set the last
+// group is zero padded, otherwise leftover bits must be zero padding
+func ConvertBits(data []byte, fromBits, toBits uint, pad bool) ([]byte, error) {
+	var result []byte
+	acc := uint32(0)
+	bits := uint(0)
+	maxValue := uint32(1)<<toBits - 1
+
+	for _, b := range data {
+		if uint32(b)>>fromBits != 0 {
+			return nil, fmt.Errorf("value %d does not fit in %d bits", b, fromBits)
+		}
+		acc = acc<<fromBits | uint32(b)
+		bits += fromBits
+		for bits >= toBits {
+			bits -= toBits
+			result = append(result, byte(acc>>bits&maxValue))
+		}
+	}
+
+	if pad {
+		if bits > 0 {
+			result = append(result, byte(acc<<(toBits-bits)&maxValue))
+		}
+	} else if bits >= fromBits || acc<<(toBits-bits)&maxValue != 0 {
+		return nil, errors.New("invalid padding")
+	}
+
+	return result, nil
+}
+
+// bech32Polymod computes the BCH checksum of BIP173 over values
+func bech32Polymod(values []byte) uint32 {
+	chk := uint32(1)
+
+	for _, v := range values {
+		top := chk >> 25
+		chk = (chk&0x1ffffff)<<5 ^ uint32(v)
+		for i := 0; i < 5; i++ {
+			if (top>>uint(i))&1 == 1 {
+				chk ^= bech32Generator[i]
+			}
+		}
+	}
+
+	return chk
+}
+
+// bech32HRPExpand spreads the human readable part over 5 bit values for the checksum
+func bech32HRPExpand(hrp string) []byte {
+	result := make([]byte, 0, len(hrp)*2+1)
+
+	for i := 0; i < len(hrp); i++ {
+		result = append(result, hrp[i]>>5)
+	}
+	result = append(result, 0)
+	for i := 0; i < len(hrp); i++ {
+		result = append(result, hrp[i]&31)
+	}
+
+	return result
+}
+
+// bech32Checksum returns the 6 checksum values of data under hrp
+func bech32Checksum(hrp string, data []byte) []byte {
+	values := append(bech32HRPExpand(hrp), data...)
+	polymod := bech32Polymod(append(values, 0, 0, 0, 0, 0, 0)) ^ 1
+
+	checksum := make([]byte, 6)
+	for i := range checksum {
+		checksum[i] = byte(polymod >> uint(5*(5-i)) & 31)
+	}
+
+	return checksum
+}
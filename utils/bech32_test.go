@@ -0,0 +1,227 @@
+package utils
+
+import (
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+func TestUtils(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "Utils Suite")
+}
+
+// bech32ValidChecksums are the valid Bech32 strings of BIP173
+var bech32ValidChecksums = []string{
+	"A12UEL5L",
+	"a12uel5l",
+	"an83characterlonghumanreadablepartthatcontainsthenumber1andtheexcludedcharactersbio1tt5tgs",
+	"abcdef1qpzry9x8gf2tvdw0s3jn54khce6mua7lmqqqxw",
+	"11qqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqc8247j",
+	"split1checkupstagehandshakeupstreamerranterredcaperred2y9e3w",
+	"?1ezyfcl",
+}
+
+// bech32InvalidChecksums are the invalid Bech32 strings of BIP173
+var bech32InvalidChecksums = []string{
+	"\x201nwldj5",
+	"\x7f1axkwrx",
+	"\x801eym55h",
+	"an84characterslonghumanreadablepartthatcontainsthenumber1andtheexcludedcharactersbio1569pvx",
+	"pzry9x0s0muk",
+	"1pzry9x0s0muk",
+	"x1b4n0q5v",
+	"li1dgmt3",
+	"de1lg7wt\xff",
+	"A1G7SGD8",
+	"10a06t8",
+	"1qzzfhee",
+}
+
+// segwitVector is a valid BIP173 address and the output script it stands for
+type segwitVector struct {
+	hrp     string
+	address string
+	script  string
+}
+
+var segwitValidAddresses = []segwitVector{
+	{"bc", "BC1QW508D6QEJXTDG4Y5R3ZARVARY0C5XW7KV8F3T4", "0014751e76e8199196d454941c45d1b3a323f1433bd6"},
+	{"tb", "tb1qrp33g0q5c5txsp9arysrx4k6zdkfs4nce4xj0gdcccefvpysxf3q0sl5k7", "00201863143c14c5166804bd19203356da136c985678cd4d27a1b8c6329604903262"},
+	{"bc", "bc1pw508d6qejxtdg4y5r3zarvary0c5xw7kw508d6qejxtdg4y5r3zarvary0c5xw7k7grplx", "5128751e76e8199196d454941c45d1b3a323f1433bd6751e76e8199196d454941c45d1b3a323f1433bd6"},
+	{"bc", "BC1SW50QA3JX3S", "6002751e"},
+	{"tb", "tb1qqqqqp399et2xygdj5xreqhjjvcmzhxw4aywxecjdzew6hylgvsesrxh6hy", "0020000000c4a5cad46221b2a187905e5266362b99d5e91c6ce24d165dab93e86433"},
+}
+
+// segwitInvalidAddresses are the invalid BIP173 addresses with the reason they fail
+var segwitInvalidAddresses = []struct {
+	hrp     string
+	address string
+	reason  string
+}{
+	{"bc", "tc1qw508d6qejxtdg4y5r3zarvary0c5xw7kg3g4ty", "human readable part mismatch"},
+	{"bc", "bc1qw508d6qejxtdg4y5r3zarvary0c5xw7kv8f3t5", "bad checksum"},
+	{"bc", "BC13W508D6QEJXTDG4Y5R3ZARVARY0C5XW7KN40WF2", "invalid witness version"},
+	{"bc", "bc1rw5uspcuh", "invalid program length"},
+	{"bc", "bc10w508d6qejxtdg4y5r3zarvary0c5xw7kw508d6qejxtdg4y5r3zarvary0c5xw7kw5rljs90", "invalid program length"},
+	{"bc", "BC1QR508D6QEJXTDG4Y5R3ZARVARYV98GJ9P", "invalid program length for witness version 0"},
+	{"tb", "tb1qrp33g0q5c5txsp9arysrx4k6zdkfs4nce4xj0gdcccefvpysxf3q0sL5k7", "mixed case"},
+	{"tb", "tb1qrp33g0q5c5txsp9arysrx4k6zdkfs4nce4xj0gdcccefvpysxf3pjxtptv", "more than 4 bits of padding"},
+	{"bc", "bc1gmk9yu", "empty data section"},
+}
+
+// decodeSegwitAddress follows the BIP173 reference decoder, returning the output script
+// of a witness address with human readable part hrp
+func decodeSegwitAddress(hrp, address string) ([]byte, error) {
+	decodedHRP, data, err := Bech32Decode(address)
+	if err != nil {
+		return nil, err
+	}
+	if decodedHRP != hrp {
+		return nil, fmt.Errorf("human readable part %q, expected %q", decodedHRP, hrp)
+	}
+	if len(data) < 1 || data[0] > 16 {
+		return nil, errors.New("invalid witness version")
+	}
+
+	program, err := ConvertBits(data[1:], 5, 8, false)
+	if err != nil {
+		return nil, err
+	}
+	if len(program) < 2 || len(program) > 40 {
+		return nil, fmt.Errorf("witness program has length %d", len(program))
+	}
+	if data[0] == 0 && len(program) != 20 && len(program) != 32 {
+		return nil, fmt.Errorf("version 0 witness program has length %d", len(program))
+	}
+
+	version := data[0]
+	if version > 0 {
+		version += 0x50
+	}
+
+	return append([]byte{version, byte(len(program))}, program...), nil
+}
+
+var _ = Describe("Bech32", func() {
+	Describe("Bech32Decode", func() {
+		It("should accept the valid checksums and encode them back", func() {
+			for _, vector := range bech32ValidChecksums {
+				hrp, data, err := Bech32Decode(vector)
+				Expect(err).NotTo(HaveOccurred(), "decoding %s", vector)
+
+				encoded, err := Bech32Encode(hrp, data)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(encoded).To(Equal(strings.ToLower(vector)))
+			}
+		})
+
+		It("should reject the invalid checksums", func() {
+			for _, vector := range bech32InvalidChecksums {
+				_, _, err := Bech32Decode(vector)
+				Expect(err).To(HaveOccurred(), "decoding %q", vector)
+			}
+		})
+
+		It("should reject a flipped character", func() {
+			for _, vector := range bech32ValidChecksums {
+				vector = strings.ToLower(vector)
+				last := len(vector) - 1
+				flipped := vector[:last] + "q"
+				if vector[last] == 'q' {
+					flipped = vector[:last] + "p"
+				}
+
+				_, _, err := Bech32Decode(flipped)
+				Expect(err).To(MatchError(ContainSubstring("checksum")), "decoding %s", flipped)
+			}
+		})
+
+		It("should reject mixed case", func() {
+			_, _, err := Bech32Decode("A12uEL5L")
+			Expect(err).To(MatchError(ContainSubstring("case")))
+		})
+	})
+
+	Describe("witness addresses", func() {
+		It("should decode the valid addresses to their output scripts", func() {
+			for _, vector := range segwitValidAddresses {
+				script, err := decodeSegwitAddress(vector.hrp, vector.address)
+				Expect(err).NotTo(HaveOccurred(), "decoding %s", vector.address)
+				Expect(hex.EncodeToString(script)).To(Equal(vector.script))
+			}
+		})
+
+		It("should re-encode the valid addresses", func() {
+			for _, vector := range segwitValidAddresses {
+				hrp, data, err := Bech32Decode(vector.address)
+				Expect(err).NotTo(HaveOccurred())
+
+				program, err := ConvertBits(data[1:], 5, 8, false)
+				Expect(err).NotTo(HaveOccurred())
+				regrouped, err := ConvertBits(program, 8, 5, true)
+				Expect(err).NotTo(HaveOccurred())
+
+				encoded, err := Bech32Encode(hrp, append([]byte{data[0]}, regrouped...))
+				Expect(err).NotTo(HaveOccurred())
+				Expect(encoded).To(Equal(strings.ToLower(vector.address)))
+			}
+		})
+
+		It("should reject the invalid addresses", func() {
+			for _, vector := range segwitInvalidAddresses {
+				_, err := decodeSegwitAddress(vector.hrp, vector.address)
+				Expect(err).To(HaveOccurred(), "%s: %s", vector.address, vector.reason)
+			}
+		})
+
+		It("should reject a human readable part of another network", func() {
+			_, _, err := Bech32Decode("tc1qw508d6qejxtdg4y5r3zarvary0c5xw7kg3g4ty")
+			Expect(err).NotTo(HaveOccurred())
+
+			_, err = decodeSegwitAddress("bc", "tc1qw508d6qejxtdg4y5r3zarvary0c5xw7kg3g4ty")
+			Expect(err).To(MatchError(ContainSubstring("human readable part")))
+		})
+	})
+
+	Describe("ConvertBits", func() {
+		It("should reject invalid padding", func() {
+			// a valid checksum whose data leaves more than 4 padding bits
+			_, data, err := Bech32Decode("tb1qrp33g0q5c5txsp9arysrx4k6zdkfs4nce4xj0gdcccefvpysxf3pjxtptv")
+			Expect(err).NotTo(HaveOccurred())
+			_, err = ConvertBits(data[1:], 5, 8, false)
+			Expect(err).To(MatchError("invalid padding"))
+
+			// 0b00001 leaves a set bit in the padding of a byte
+			_, err = ConvertBits([]byte{0, 1}, 5, 8, false)
+			Expect(err).To(MatchError("invalid padding"))
+		})
+
+		It("should round trip bytes through 5 bit groups", func() {
+			data := []byte{0x00, 0x01, 0x7f, 0x80, 0xff, 0x75, 0x1e}
+			groups, err := ConvertBits(data, 8, 5, true)
+			Expect(err).NotTo(HaveOccurred())
+
+			back, err := ConvertBits(groups, 5, 8, false)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(back).To(Equal(data))
+		})
+
+		It("should reject values wider than the input groups", func() {
+			_, err := ConvertBits([]byte{32}, 5, 8, false)
+			Expect(err).To(HaveOccurred())
+		})
+	})
+
+	Describe("Bech32Encode", func() {
+		It("should reject values wider than 5 bits", func() {
+			_, err := Bech32Encode("bc", []byte{0, 32})
+			Expect(err).To(HaveOccurred())
+		})
+	})
+})
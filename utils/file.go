@@ -0,0 +1,54 @@
+package utils
+
+import (
+	"os"
+	"path/filepath"
+)
+
+// dataDir is where the node keeps its files, the working directory when empty
+var dataDir string
+
+// SetDataDir makes dir, creating it when needed, the directory of the node files
+func SetDataDir(dir string) error {
+	if dir != "" {
+		if err := os.MkdirAll(dir, 0700); err != nil {
+			return err
+		}
+	}
+	dataDir = dir
+
+	return nil
+}
+
+// DataPath returns the path of the node file name inside the data directory
+func DataPath(name string) string {
+	return filepath.Join(dataDir, name)
+}
+
+// WriteFileAtomic writes data to a temporary file next to path and renames it into place,
+// so readers never see a partially written file
+func WriteFileAtomic(path string, data []byte, perm os.FileMode) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".tmp*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if err = tmp.Chmod(perm); err != nil {
+		tmp.Close()
+		return err
+	}
+	if _, err = tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err = tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err = tmp.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp.Name(), path)
+}
@@ -0,0 +1,103 @@
+package wallets
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"errors"
+
+	"golang.org/x/crypto/chacha20poly1305"
+	"golang.org/x/crypto/scrypt"
+)
+
+const (
+	scryptN    = 1 << 15
+	scryptR    = 8
+	scryptP    = 1
+	saltLen    = 16
+	checkPlain = "wallet passphrase check"
+)
+
+var (
+	// ErrWalletLocked is returned when a private key is needed while the wallet is locked
+	ErrWalletLocked = errors.New("wallet is locked, unlock it with walletpassphrase first")
+	// ErrWrongPassphrase is returned when the passphrase does not open the wallet
+	ErrWrongPassphrase = errors.New("the wallet passphrase is incorrect")
+	// ErrNotEncrypted is returned when a passphrase operation runs on a plain wallet
+	ErrNotEncrypted = errors.New("wallet is not encrypted, run encryptwallet first")
+	// ErrAlreadyEncrypted is returned when encrypting a wallet twice
+	ErrAlreadyEncrypted = errors.New("wallet is already encrypted")
+)
+
+// walletCrypto holds the key derivation parameters of an encrypted wallet
+type walletCrypto struct {
+	Salt    []byte
+	N, R, P int
+	// Check is a known plaintext sealed under the wallet key, it tells a wrong passphrase apart
+	Check []byte
+}
+
+// newWalletCrypto picks a fresh salt and returns the parameters with the key they derive from passphrase
+func newWalletCrypto(passphrase string) (*walletCrypto, []byte, error) {
+	salt := make([]byte, saltLen)
+	if _, err := rand.Read(salt); err != nil {
+		return nil, nil, err
+	}
+
+	wc := &walletCrypto{Salt: salt, N: scryptN, R: scryptR, P: scryptP}
+	key, err := wc.deriveKey(passphrase)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	wc.Check, err = seal(key, []byte(checkPlain), nil)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return wc, key, nil
+}
+
+// deriveKey stretches the passphrase into the wallet key
+func (wc *walletCrypto) deriveKey(passphrase string) ([]byte, error) {
+	return scrypt.Key([]byte(passphrase), wc.Salt, wc.N, wc.R, wc.P, chacha20poly1305.KeySize)
+}
+
+// verifyKey checks that key opens this wallet
+func (wc *walletCrypto) verifyKey(key []byte) error {
+	plain, err := open(key, wc.Check, nil)
+	if err != nil || subtle.ConstantTimeCompare(plain, []byte(checkPlain)) != 1 {
+		return ErrWrongPassphrase
+	}
+
+	return nil
+}
+
+// seal encrypts plaintext with XChaCha20-Poly1305 and returns the nonce followed by the ciphertext
+func seal(key, plaintext, additionalData []byte) ([]byte, error) {
+	aead, err := chacha20poly1305.NewX(key)
+	if err != nil {
+		return nil, err
+	}
+
+	nonce := make([]byte, aead.NonceSize(), aead.NonceSize()+len(plaintext)+aead.Overhead())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+
+	return aead.Seal(nonce, nonce, plaintext, additionalData), nil
+}
+
+// open reverses seal
+func open(key, sealed, additionalData []byte) ([]byte, error) {
+	aead, err := chacha20poly1305.NewX(key)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(sealed) < aead.NonceSize() {
+		return nil, errors.New("sealed data is too short")
+	}
+	nonce, ciphertext := sealed[:aead.NonceSize()], sealed[aead.NonceSize():]
+
+	return aead.Open(nil, nonce, ciphertext, additionalData)
+}
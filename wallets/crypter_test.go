@@ -0,0 +1,197 @@
+package wallets
+
+import (
+	"bytes"
+	"os"
+
+	"github.com/cyprus09/blockchain/utils"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+// newTestWallets returns empty Wallets whose file goes to a temporary data directory
+func newTestWallets() *Wallets {
+	dir, err := os.MkdirTemp("", "wallets")
+	Expect(err).NotTo(HaveOccurred())
+	DeferCleanup(os.RemoveAll, dir)
+	Expect(utils.SetDataDir(dir)).To(Succeed())
+
+	ws, err := NewWallets("test")
+	Expect(os.IsNotExist(err)).To(BeTrue())
+
+	return ws
+}
+
+// reloadWallets saves ws and reads it back from its file
+func reloadWallets(ws *Wallets) *Wallets {
+	ws.SaveToFile("test")
+
+	loaded, err := NewWallets("test")
+	Expect(err).NotTo(HaveOccurred())
+
+	return loaded
+}
+
+// privateKeys returns the private scalar of every address of ws
+func privateKeys(ws *Wallets) map[string]string {
+	keys := make(map[string]string)
+
+	for address, wallet := range ws.Wallets {
+		keys[address] = wallet.PrivateKey.D.Text(16)
+	}
+
+	return keys
+}
+
+var _ = Describe("Wallet encryption", func() {
+	Describe("seal and open", func() {
+		key := bytes.Repeat([]byte{0x42}, 32)
+		plaintext := []byte("private key bytes")
+		ad := []byte("address")
+
+		It("should round trip", func() {
+			sealed, err := seal(key, plaintext, ad)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(sealed).NotTo(ContainSubstring(string(plaintext)))
+
+			opened, err := open(key, sealed, ad)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(opened).To(Equal(plaintext))
+		})
+
+		It("should use a fresh nonce every time", func() {
+			first, err := seal(key, plaintext, ad)
+			Expect(err).NotTo(HaveOccurred())
+			second, err := seal(key, plaintext, ad)
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(first).NotTo(Equal(second))
+		})
+
+		It("should not open with another key, other additional data or tampered data", func() {
+			sealed, err := seal(key, plaintext, ad)
+			Expect(err).NotTo(HaveOccurred())
+
+			_, err = open(bytes.Repeat([]byte{0x43}, 32), sealed, ad)
+			Expect(err).To(HaveOccurred())
+
+			_, err = open(key, sealed, []byte("another address"))
+			Expect(err).To(HaveOccurred())
+
+			tampered := append([]byte{}, sealed...)
+			tampered[len(tampered)-1] ^= 0x01
+			_, err = open(key, tampered, ad)
+			Expect(err).To(HaveOccurred())
+
+			_, err = open(key, sealed[:10], ad)
+			Expect(err).To(HaveOccurred())
+		})
+	})
+
+	Describe("Wallets", func() {
+		var ws *Wallets
+		var keys map[string]string
+		var mnemonic string
+
+		BeforeEach(func() {
+			ws = newTestWallets()
+			for i := 0; i < 2; i++ {
+				_, err := ws.CreateWallet()
+				Expect(err).NotTo(HaveOccurred())
+			}
+			_, err := ws.ImportKey(NewWallet(KeyTypeLegacy).PrivateKey, KeyTypeLegacy)
+			Expect(err).NotTo(HaveOccurred())
+
+			keys = privateKeys(ws)
+			mnemonic, err = ws.Mnemonic()
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(ws.Encrypt("correct horse")).To(Succeed())
+		})
+
+		It("should come back locked from the file and unlock with the passphrase", func() {
+			loaded := reloadWallets(ws)
+			Expect(loaded.IsEncrypted()).To(BeTrue())
+			Expect(loaded.IsLocked()).To(BeTrue())
+			for _, wallet := range loaded.Wallets {
+				Expect(wallet.PrivateKey.D).To(BeNil())
+			}
+			_, err := loaded.Mnemonic()
+			Expect(err).To(Equal(ErrWalletLocked))
+			_, err = loaded.CreateWallet()
+			Expect(err).To(Equal(ErrWalletLocked))
+
+			Expect(loaded.Unlock("correct horse")).To(Succeed())
+			Expect(privateKeys(loaded)).To(Equal(keys))
+			Expect(loaded.Mnemonic()).To(Equal(mnemonic))
+		})
+
+		It("should not keep the keys or the mnemonic in the file", func() {
+			ws.SaveToFile("test")
+
+			content, err := os.ReadFile(utils.DataPath("wallet_test.dat"))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(string(content)).NotTo(ContainSubstring(mnemonic))
+		})
+
+		It("should refuse a wrong passphrase", func() {
+			loaded := reloadWallets(ws)
+
+			Expect(loaded.Unlock("wrong horse")).To(Equal(ErrWrongPassphrase))
+			Expect(loaded.IsLocked()).To(BeTrue())
+		})
+
+		It("should lock again", func() {
+			ws.Lock()
+
+			Expect(ws.IsLocked()).To(BeTrue())
+			for _, wallet := range ws.Wallets {
+				Expect(wallet.PrivateKey.D).To(BeNil())
+			}
+			Expect(ws.Unlock("correct horse")).To(Succeed())
+			Expect(privateKeys(ws)).To(Equal(keys))
+		})
+
+		It("should unlock with the derived key", func() {
+			key, err := ws.Key()
+			Expect(err).NotTo(HaveOccurred())
+
+			loaded := reloadWallets(ws)
+			Expect(loaded.UnlockWithKey(key)).To(Succeed())
+			Expect(privateKeys(loaded)).To(Equal(keys))
+		})
+
+		It("should re-key under a new passphrase", func() {
+			Expect(ws.ChangePassphrase("wrong horse", "battery staple")).To(Equal(ErrWrongPassphrase))
+			Expect(ws.ChangePassphrase("correct horse", "battery staple")).To(Succeed())
+
+			loaded := reloadWallets(ws)
+			Expect(loaded.Unlock("correct horse")).To(Equal(ErrWrongPassphrase))
+			Expect(loaded.Unlock("battery staple")).To(Succeed())
+			Expect(privateKeys(loaded)).To(Equal(keys))
+			Expect(loaded.Mnemonic()).To(Equal(mnemonic))
+		})
+
+		It("should seal keys added while unlocked", func() {
+			address, err := ws.CreateWallet()
+			Expect(err).NotTo(HaveOccurred())
+			keys[address] = ws.Wallets[address].PrivateKey.D.Text(16)
+
+			loaded := reloadWallets(ws)
+			Expect(loaded.Unlock("correct horse")).To(Succeed())
+			Expect(privateKeys(loaded)).To(Equal(keys))
+		})
+
+		It("should refuse to encrypt twice", func() {
+			Expect(ws.Encrypt("battery staple")).To(Equal(ErrAlreadyEncrypted))
+		})
+	})
+
+	It("should refuse passphrase operations on a plain wallet", func() {
+		ws := newTestWallets()
+
+		Expect(ws.Unlock("correct horse")).To(Equal(ErrNotEncrypted))
+		_, err := ws.Key()
+		Expect(err).To(Equal(ErrNotEncrypted))
+	})
+})
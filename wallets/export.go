@@ -0,0 +1,246 @@
+package wallets
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/x509"
+	"encoding/asn1"
+	"encoding/pem"
+	"errors"
+	"fmt"
+
+	"github.com/cyprus09/blockchain/utils"
+)
+
+const (
+	wifVersion    = byte(0x80)
+	wifCompressed = byte(0x01)
+	pemType       = "PRIVATE KEY"
+	pemTypeEC     = "EC PRIVATE KEY"
+	// pemKeyTypeHeader tells P-256 keys with legacy public keys apart from SEC1 ones
+	pemKeyTypeHeader = "Key-Type"
+)
+
+// oidSecp256k1 is the named curve OID of secp256k1 in SEC1 private keys
+var oidSecp256k1 = asn1.ObjectIdentifier{1, 3, 132, 0, 10}
+
+// ecPrivateKey is the SEC1 ECPrivateKey structure of RFC 5915
+type ecPrivateKey struct {
+	Version       int
+	PrivateKey    []byte
+	NamedCurveOID asn1.ObjectIdentifier `asn1:"optional,explicit,tag:0"`
+	PublicKey     asn1.BitString        `asn1:"optional,explicit,tag:1"`
+}
+
+// KeyDump is a single key of a wallet dump
+type KeyDump struct {
+	Address string `json:"address"`
+	WIF     string `json:"wif"`
+}
+
+// WalletDump is the JSON form of a whole wallet, private keys included
+type WalletDump struct {
+	Mnemonic  string    `json:"mnemonic,omitempty"`
+	HDKeyType string    `json:"hd_key_type,omitempty"`
+	NextIndex uint32    `json:"next_index"`
+	Keys      []KeyDump `json:"keys"`
+}
+
+// EncodeWIF encodes a private key as version byte, private scalar and checksum in Base58.
+// Legacy keys carry nothing after the scalar, secp256k1 keys the 0x01 compression
+// flag of Bitcoin WIF keys and other key types the flag followed by the key type
+func EncodeWIF(privKey ecdsa.PrivateKey, keyType KeyType) string {
+	payload := append([]byte{wifVersion}, privateKeyBytes(privKey)...)
+	switch keyType {
+	case KeyTypeLegacy:
+	case KeyTypeSecp256k1:
+		payload = append(payload, wifCompressed)
+	default:
+		payload = append(payload, wifCompressed, byte(keyType))
+	}
+
+	return string(utils.Base58CheckEncode(payload))
+}
+
+// DecodeWIF decodes a private key written by EncodeWIF
+func DecodeWIF(wif string) (ecdsa.PrivateKey, KeyType, error) {
+	body, err := utils.Base58CheckDecode([]byte(wif))
+	if err != nil {
+		return ecdsa.PrivateKey{}, 0, fmt.Errorf("WIF key: %v", err)
+	}
+	if len(body) < 1+32 || len(body) > 1+32+2 {
+		return ecdsa.PrivateKey{}, 0, fmt.Errorf("WIF key holds %d bytes, expected %d to %d", len(body), 1+32, 1+32+2)
+	}
+	if body[0] != wifVersion {
+		return ecdsa.PrivateKey{}, 0, fmt.Errorf("WIF key has version 0x%02x, expected 0x%02x", body[0], wifVersion)
+	}
+
+	keyType := KeyTypeLegacy
+	suffix := body[1+32:]
+	if len(suffix) > 0 && suffix[0] != wifCompressed {
+		return ecdsa.PrivateKey{}, 0, fmt.Errorf("WIF key has compression flag 0x%02x, expected 0x%02x", suffix[0], wifCompressed)
+	}
+	switch len(suffix) {
+	case 1:
+		keyType = KeyTypeSecp256k1
+	case 2:
+		keyType = KeyType(suffix[1])
+		if keyType != KeyTypeP256 && keyType != KeyTypeSecp256k1 {
+			return ecdsa.PrivateKey{}, 0, fmt.Errorf("WIF key has unknown key type 0x%02x", suffix[1])
+		}
+	}
+
+	d := body[1 : 1+32]
+	if !validPrivateKey(keyType.Curve(), d) {
+		return ecdsa.PrivateKey{}, 0, errors.New("WIF key is out of range")
+	}
+
+	return privateKeyFromBytes(keyType, d), keyType, nil
+}
+
+// EncodePEM encodes a private key as a PEM block, PKCS8 for P-256 keys and SEC1 for
+// secp256k1 keys which PKCS8 in the standard library cannot carry
+func EncodePEM(privKey ecdsa.PrivateKey, keyType KeyType) ([]byte, error) {
+	block := &pem.Block{Headers: map[string]string{pemKeyTypeHeader: keyType.String()}}
+
+	if keyType == KeyTypeSecp256k1 {
+		der, err := asn1.Marshal(ecPrivateKey{
+			Version:       1,
+			PrivateKey:    privateKeyBytes(privKey),
+			NamedCurveOID: oidSecp256k1,
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		block.Type = pemTypeEC
+		block.Bytes = der
+	} else {
+		der, err := x509.MarshalPKCS8PrivateKey(&privKey)
+		if err != nil {
+			return nil, err
+		}
+
+		block.Type = pemType
+		block.Bytes = der
+	}
+
+	return pem.EncodeToMemory(block), nil
+}
+
+// DecodePEM decodes a PKCS8 or SEC1 PEM encoded P-256 or secp256k1 private key.
+// P-256 keys without a Key-Type header were written before key types and are legacy keys
+func DecodePEM(data []byte) (ecdsa.PrivateKey, KeyType, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return ecdsa.PrivateKey{}, 0, errors.New("no PEM block found")
+	}
+
+	var key interface{}
+	var err error
+	keyType := KeyTypeLegacy
+
+	if block.Type == pemTypeEC {
+		var ecKey ecPrivateKey
+		if _, err = asn1.Unmarshal(block.Bytes, &ecKey); err == nil && ecKey.NamedCurveOID.Equal(oidSecp256k1) {
+			keyType = KeyTypeSecp256k1
+			if !validPrivateKey(keyType.Curve(), ecKey.PrivateKey) {
+				return ecdsa.PrivateKey{}, 0, errors.New("PEM key is out of range")
+			}
+			return privateKeyFromBytes(keyType, ecKey.PrivateKey), keyType, nil
+		}
+		key, err = x509.ParseECPrivateKey(block.Bytes)
+	} else {
+		key, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+	}
+	if err != nil {
+		return ecdsa.PrivateKey{}, 0, err
+	}
+
+	privKey, ok := key.(*ecdsa.PrivateKey)
+	if !ok || privKey.Curve != elliptic.P256() {
+		return ecdsa.PrivateKey{}, 0, errors.New("PEM key is not a P-256 or secp256k1 ECDSA key")
+	}
+
+	if name, ok := block.Headers[pemKeyTypeHeader]; ok {
+		keyType, err = ParseKeyType(name)
+		if err != nil {
+			return ecdsa.PrivateKey{}, 0, err
+		}
+		if keyType == KeyTypeSecp256k1 {
+			return ecdsa.PrivateKey{}, 0, errors.New("PEM key is a P-256 key but its header says secp256k1")
+		}
+	}
+
+	return privateKeyFromBytes(keyType, privateKeyBytes(*privKey)), keyType, nil
+}
+
+// ImportKey adds an existing private key to the wallet and returns its address
+func (ws *Wallets) ImportKey(privKey ecdsa.PrivateKey, keyType KeyType) (string, error) {
+	if ws.IsLocked() {
+		return "", ErrWalletLocked
+	}
+
+	return ws.addWallet(newWalletFromKey(keyType, privKey))
+}
+
+// Dump returns every key of the wallet, and its HD seed, in a form that can be written out as JSON
+func (ws *Wallets) Dump() (*WalletDump, error) {
+	if ws.IsLocked() {
+		return nil, ErrWalletLocked
+	}
+
+	dump := &WalletDump{Mnemonic: ws.mnemonic, NextIndex: ws.nextIndex}
+	if ws.HasHDSeed() {
+		dump.HDKeyType = ws.hdKeyType.String()
+	}
+	for _, address := range ws.GetAddresses() {
+		wallet := ws.Wallets[address]
+		dump.Keys = append(dump.Keys, KeyDump{address, EncodeWIF(wallet.PrivateKey, wallet.KeyType)})
+	}
+
+	return dump, nil
+}
+
+// ImportDump adds the keys of a wallet dump and returns their addresses.
+// The HD seed of the dump is only taken over by a wallet that has none
+func (ws *Wallets) ImportDump(dump *WalletDump) ([]string, error) {
+	var addresses []string
+
+	if ws.IsLocked() {
+		return nil, ErrWalletLocked
+	}
+
+	if dump.Mnemonic != "" && !ws.HasHDSeed() {
+		// dumps without a key type come from wallets that predate key types
+		keyType := KeyTypeLegacy
+		if dump.HDKeyType != "" {
+			var err error
+			if keyType, err = ParseKeyType(dump.HDKeyType); err != nil {
+				return nil, err
+			}
+		}
+		if err := ws.SetMnemonic(dump.Mnemonic, keyType); err != nil {
+			return nil, err
+		}
+		ws.nextIndex = dump.NextIndex
+	}
+
+	for _, key := range dump.Keys {
+		privKey, keyType, err := DecodeWIF(key.WIF)
+		if err != nil {
+			return nil, fmt.Errorf("key of %s: %v", key.Address, err)
+		}
+
+		address, err := ws.ImportKey(privKey, keyType)
+		if err != nil {
+			return nil, err
+		}
+		if key.Address != "" && key.Address != address {
+			return nil, fmt.Errorf("key of %s belongs to %s", key.Address, address)
+		}
+		addresses = append(addresses, address)
+	}
+
+	return addresses, nil
+}
@@ -0,0 +1,142 @@
+package wallets
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/hmac"
+	"crypto/sha512"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math/big"
+	"strconv"
+	"strings"
+)
+
+const (
+	// HardenedKeyStart is the first hardened child index, written with a ' in paths
+	HardenedKeyStart = 0x80000000
+	// hdAccountPath is the parent of every address key, address i lives at hdAccountPath/i
+	hdAccountPath = "m/44'/0'/0'/0"
+	// hdMasterSecret is the SLIP-0010 HMAC key for P-256 master keys
+	hdMasterSecret = "Nist256p1 seed"
+	// hdMasterSecretSecp256k1 is the BIP32 HMAC key for secp256k1 master keys
+	hdMasterSecretSecp256k1 = "Bitcoin seed"
+)
+
+// ExtendedKey is a BIP32-style private key together with the chain code needed to derive its children
+type ExtendedKey struct {
+	Key       []byte
+	ChainCode []byte
+	Depth     uint8
+	ChildNum  uint32
+	KeyType   KeyType
+}
+
+// NewMasterKey derives the root key of a wallet from its seed
+func NewMasterKey(keyType KeyType, seed []byte) *ExtendedKey {
+	curve := keyType.Curve()
+	secret := []byte(hdMasterSecret)
+	if keyType == KeyTypeSecp256k1 {
+		secret = []byte(hdMasterSecretSecp256k1)
+	}
+	I := hmacSHA512(secret, seed)
+
+	// SLIP-0010 retries with the previous output until the key lands inside the curve order
+	for !validPrivateKey(curve, I[:32]) {
+		I = hmacSHA512(secret, I)
+	}
+
+	return &ExtendedKey{I[:32], I[32:], 0, 0, keyType}
+}
+
+// Child derives the child key at index, indexes from HardenedKeyStart on are hardened
+func (k *ExtendedKey) Child(index uint32) (*ExtendedKey, error) {
+	if k.Depth == 255 {
+		return nil, errors.New("cannot derive beyond depth 255")
+	}
+
+	curve := k.KeyType.Curve()
+	var data []byte
+
+	if index >= HardenedKeyStart {
+		data = append([]byte{0x00}, k.Key...)
+	} else {
+		x, y := curve.ScalarBaseMult(k.Key)
+		data = elliptic.MarshalCompressed(curve, x, y)
+	}
+	data = append(data, ser32(index)...)
+
+	N := curve.Params().N
+	parent := new(big.Int).SetBytes(k.Key)
+
+	for {
+		I := hmacSHA512(k.ChainCode, data)
+		IL := new(big.Int).SetBytes(I[:32])
+		child := new(big.Int).Add(IL, parent)
+		child.Mod(child, N)
+
+		if IL.Cmp(N) < 0 && child.Sign() != 0 {
+			return &ExtendedKey{child.FillBytes(make([]byte, 32)), I[32:], k.Depth + 1, index, k.KeyType}, nil
+		}
+
+		// SLIP-0010: retry with 0x01 || IR || index
+		data = append([]byte{0x01}, I[32:]...)
+		data = append(data, ser32(index)...)
+	}
+}
+
+// DerivePath walks a path such as m/44'/0'/0'/0/1 down from a master key
+func (k *ExtendedKey) DerivePath(path string) (*ExtendedKey, error) {
+	parts := strings.Split(path, "/")
+	if parts[0] != "m" {
+		return nil, fmt.Errorf("derivation path %q must start at m", path)
+	}
+
+	key := k
+	for _, part := range parts[1:] {
+		hardened := strings.HasSuffix(part, "'")
+		index, err := strconv.ParseUint(strings.TrimSuffix(part, "'"), 10, 31)
+		if err != nil {
+			return nil, fmt.Errorf("derivation path %q has an invalid index %q", path, part)
+		}
+		if hardened {
+			index += HardenedKeyStart
+		}
+
+		key, err = key.Child(uint32(index))
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return key, nil
+}
+
+// PrivateKey returns the ECDSA key held by the extended key
+func (k *ExtendedKey) PrivateKey() ecdsa.PrivateKey {
+	return privateKeyFromBytes(k.KeyType, k.Key)
+}
+
+// ser32 serializes a child index as 4 big endian bytes
+func ser32(index uint32) []byte {
+	buf := make([]byte, 4)
+	binary.BigEndian.PutUint32(buf, index)
+
+	return buf
+}
+
+// hmacSHA512 returns HMAC-SHA512 of data under key
+func hmacSHA512(key, data []byte) []byte {
+	mac := hmac.New(sha512.New, key)
+	mac.Write(data)
+
+	return mac.Sum(nil)
+}
+
+// validPrivateKey checks that k is in [1, n-1]
+func validPrivateKey(curve elliptic.Curve, k []byte) bool {
+	d := new(big.Int).SetBytes(k)
+
+	return d.Sign() > 0 && d.Cmp(curve.Params().N) < 0
+}
@@ -0,0 +1,121 @@
+package wallets
+
+import (
+	"encoding/hex"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+// hdVector is a published derivation: the chain code and private key of path below the
+// master key of seed
+type hdVector struct {
+	keyType   KeyType
+	seed      string
+	path      string
+	chainCode string
+	key       string
+}
+
+var hdVectors = []hdVector{
+	// BIP32 test vector 1
+	{KeyTypeSecp256k1, "000102030405060708090a0b0c0d0e0f", "m",
+		"873dff81c02f525623fd1fe5167eac3a55a049de3d314bb42ee227ffed37d508",
+		"e8f32e723decf4051aefac8e2c93c9c5b214313817cdb01a1494b917c8436b35"},
+	{KeyTypeSecp256k1, "000102030405060708090a0b0c0d0e0f", "m/0'",
+		"47fdacbd0f1097043b78c63c20c34ef4ed9a111d980047ad16282c7ae6236141",
+		"edb2e14f9ee77d26dd93b4ecede8d16ed408ce149b6cd80b0715a2d911a0afea"},
+	{KeyTypeSecp256k1, "000102030405060708090a0b0c0d0e0f", "m/0'/1",
+		"2a7857631386ba23dacac34180dd1983734e444fdbf774041578e9b6adb37c19",
+		"3c6cb8d0f6a264c91ea8b5030fadaa8e538b020f0a387421a12de9319dc93368"},
+	{KeyTypeSecp256k1, "000102030405060708090a0b0c0d0e0f", "m/0'/1/2'",
+		"04466b9cc8e161e966409ca52986c584f07e9dc81f735db683c3ff6ec7b1503f",
+		"cbce0d719ecf7431d88e6a89fa1483e02e35092af60c042b1df2ff59fa424dca"},
+	{KeyTypeSecp256k1, "000102030405060708090a0b0c0d0e0f", "m/0'/1/2'/2",
+		"cfb71883f01676f587d023cc53a35bc7f88f724b1f8c2892ac1275ac822a3edd",
+		"0f479245fb19a38a1954c5c7c0ebab2f9bdfd96a17563ef28a6a4b1a2a764ef4"},
+	{KeyTypeSecp256k1, "000102030405060708090a0b0c0d0e0f", "m/0'/1/2'/2/1000000000",
+		"c783e67b921d2beb8f6b389cc646d7263b4145701dadd2161548a8b078e65e9e",
+		"471b76e389e528d6de6d816857e012c5455051cad6660850e58372a6c3e6e7c8"},
+	// BIP32 test vector 3, whose master key has a leading zero byte
+	{KeyTypeSecp256k1, "4b381541583be4423346c643850da4b320e46a87ae3d2a4e6da11eba819cd4acba45d239319ac14f863b8d5ab5a0d0c64d2e8a1e7d1457df2e5a3c51c73235be", "m",
+		"01d28a3e53cffa419ec122c968b3259e16b65076495494d97cae10bbfec3c36f",
+		"00ddb80b067e0d4993197fe10f2657a844a384589847602d56f0c629c81aae32"},
+	{KeyTypeSecp256k1, "4b381541583be4423346c643850da4b320e46a87ae3d2a4e6da11eba819cd4acba45d239319ac14f863b8d5ab5a0d0c64d2e8a1e7d1457df2e5a3c51c73235be", "m/0'",
+		"e5fea12a97b927fc9dc3d2cb0d1ea1cf50aa5a1fdc1f933e8906bb38df3377bd",
+		"491f7a2eebc7b57028e0d3faa0acda02e75c33b03c48fb288c41e2ea44e1daef"},
+	// SLIP-0010 test vector 1 for nist256p1
+	{KeyTypeP256, "000102030405060708090a0b0c0d0e0f", "m",
+		"beeb672fe4621673f722f38529c07392fecaa61015c80c34f29ce8b41b3cb6ea",
+		"612091aaa12e22dd2abef664f8a01a82cae99ad7441b7ef8110424915c268bc2"},
+	{KeyTypeP256, "000102030405060708090a0b0c0d0e0f", "m/0'",
+		"3460cea53e6a6bb5fb391eeef3237ffd8724bf0a40e94943c98b83825342ee11",
+		"6939694369114c67917a182c59ddb8cafc3004e63ca5d3b84403ba8613debc0c"},
+	{KeyTypeP256, "000102030405060708090a0b0c0d0e0f", "m/0'/1",
+		"4187afff1aafa8445010097fb99d23aee9f599450c7bd140b6826ac22ba21d0c",
+		"284e9d38d07d21e4e281b645089a94f4cf5a5a81369acf151a1c3a57f18b2129"},
+	{KeyTypeP256, "000102030405060708090a0b0c0d0e0f", "m/0'/1/2'",
+		"98c7514f562e64e74170cc3cf304ee1ce54d6b6da4f880f313e8204c2a185318",
+		"694596e8a54f252c960eb771a3c41e7e32496d03b954aeb90f61635b8e092aa7"},
+	{KeyTypeP256, "000102030405060708090a0b0c0d0e0f", "m/0'/1/2'/2",
+		"ba96f776a5c3907d7fd48bde5620ee374d4acfd540378476019eab70790c63a0",
+		"5996c37fd3dd2679039b23ed6f70b506c6b56b3cb5e424681fb0fa64caf82aaa"},
+	{KeyTypeP256, "000102030405060708090a0b0c0d0e0f", "m/0'/1/2'/2/1000000000",
+		"b9b7b82d326bb9cb5b5b121066feea4eb93d5241103c9e7a18aad40f1dde8059",
+		"21c4f269ef0a5fd1badf47eeacebeeaa3de22eb8e5b0adcd0f27dd99d34d0119"},
+	// SLIP-0010 derivation retry for nist256p1
+	{KeyTypeP256, "000102030405060708090a0b0c0d0e0f", "m/28578'",
+		"e94c8ebe30c2250a14713212f6449b20f3329105ea15b652ca5bdfc68f6c65c2",
+		"06f0db126f023755d0b8d86d4591718a5210dd8d024e3e14b6159d63f53aa669"},
+	{KeyTypeP256, "000102030405060708090a0b0c0d0e0f", "m/28578'/33941",
+		"9e87fe95031f14736774cd82f25fd885065cb7c358c1edf813c72af535e83071",
+		"092154eed4af83e078ff9b84322015aefe5769e31270f62c3f66c33888335f3a"},
+	// SLIP-0010 seed retry for nist256p1
+	{KeyTypeP256, "a7305bc8df8d0951f0cb224c0e95d7707cbdf2c6ce7e8d481fec69c7ff5e9446", "m",
+		"7762f9729fed06121fd13f326884c82f59aa95c57ac492ce8c9654e60efd130c",
+		"3b8c18469a4634517d6d0b65448f8e6c62091b45540a1743c5846be55d47d88f"},
+}
+
+var _ = Describe("HD keys", func() {
+	Describe("DerivePath", func() {
+		It("should derive the published test vectors", func() {
+			for _, vector := range hdVectors {
+				seed, err := hex.DecodeString(vector.seed)
+				Expect(err).NotTo(HaveOccurred())
+
+				key, err := NewMasterKey(vector.keyType, seed).DerivePath(vector.path)
+				Expect(err).NotTo(HaveOccurred(), "%s %s", vector.keyType, vector.path)
+
+				Expect(hex.EncodeToString(key.ChainCode)).To(Equal(vector.chainCode), "%s %s", vector.keyType, vector.path)
+				Expect(hex.EncodeToString(key.Key)).To(Equal(vector.key), "%s %s", vector.keyType, vector.path)
+			}
+		})
+
+		It("should reject paths that do not start at m or have bad indexes", func() {
+			master := NewMasterKey(KeyTypeP256, make([]byte, 16))
+
+			for _, invalid := range []string{"", "0/1", "m/", "m/x", "m/-1", "m/2147483648", "m/1''"} {
+				_, err := master.DerivePath(invalid)
+				Expect(err).To(HaveOccurred(), "deriving %q", invalid)
+			}
+		})
+	})
+
+	Describe("Wallets", func() {
+		It("should derive the same addresses again from the mnemonic", func() {
+			ws := newTestWallets()
+			Expect(ws.NewHDSeed(KeyTypeSecp256k1)).To(Succeed())
+			first, err := ws.CreateWallet()
+			Expect(err).NotTo(HaveOccurred())
+			second, err := ws.CreateWallet()
+			Expect(err).NotTo(HaveOccurred())
+			mnemonic, err := ws.Mnemonic()
+			Expect(err).NotTo(HaveOccurred())
+
+			restored := newTestWallets()
+			Expect(restored.SetMnemonic(mnemonic, KeyTypeSecp256k1)).To(Succeed())
+			Expect(restored.CreateWallet()).To(Equal(first))
+			Expect(restored.CreateWallet()).To(Equal(second))
+		})
+	})
+})
@@ -0,0 +1,84 @@
+package wallets
+
+import (
+	"sort"
+)
+
+// WalletTx records how a confirmed transaction moved coins in and out of wallet addresses
+type WalletTx struct {
+	TxID      []byte
+	BlockHash []byte
+	Height    int
+	Time      int64
+	Coinbase  bool
+	// Received and Sent hold the coins each wallet address got from and put into the transaction
+	Received map[string]int
+	Sent     map[string]int
+}
+
+// WalletOutput is an unspent output paying a wallet address, kept so later spends can be valued
+type WalletOutput struct {
+	Address string
+	Value   int
+}
+
+// WalletHistory is the wallet-side index of transactions touching wallet addresses
+type WalletHistory struct {
+	Txs     map[string]*WalletTx
+	Outputs map[string]WalletOutput
+	// SyncedHash and SyncedHeight identify the last block folded into the history
+	SyncedHash   []byte
+	SyncedHeight int
+}
+
+// newWalletHistory returns an empty history that has not seen any block
+func newWalletHistory() *WalletHistory {
+	return &WalletHistory{
+		Txs:          make(map[string]*WalletTx),
+		Outputs:      make(map[string]WalletOutput),
+		SyncedHeight: -1,
+	}
+}
+
+// History returns the transaction history of the wallet
+func (ws *Wallets) History() *WalletHistory {
+	if ws.history == nil {
+		ws.history = newWalletHistory()
+	}
+
+	return ws.history
+}
+
+// ResetHistory forgets every recorded transaction so the next sync rescans the whole chain
+func (ws *Wallets) ResetHistory() {
+	ws.history = newWalletHistory()
+}
+
+// Record adds a transaction to the history
+func (h *WalletHistory) Record(wtx *WalletTx) {
+	h.Txs[string(wtx.TxID)] = wtx
+}
+
+// Transactions returns the recorded transactions touching address, or every recorded
+// transaction when address is empty, newest first
+func (h *WalletHistory) Transactions(address string) []*WalletTx {
+	var txs []*WalletTx
+
+	for _, wtx := range h.Txs {
+		_, received := wtx.Received[address]
+		_, sent := wtx.Sent[address]
+
+		if address == "" || received || sent {
+			txs = append(txs, wtx)
+		}
+	}
+
+	sort.Slice(txs, func(i, j int) bool {
+		if txs[i].Height != txs[j].Height {
+			return txs[i].Height > txs[j].Height
+		}
+		return string(txs[i].TxID) < string(txs[j].TxID)
+	})
+
+	return txs
+}
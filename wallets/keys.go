@@ -0,0 +1,188 @@
+package wallets
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"errors"
+	"fmt"
+	"math/big"
+
+	"github.com/decred/dcrd/dcrec/secp256k1/v4"
+)
+
+// KeyType identifies the curve of a key and how its public half is serialized
+type KeyType byte
+
+const (
+	// KeyTypeLegacy is P-256 with the unpadded X||Y public key of early wallets
+	KeyTypeLegacy KeyType = 0x00
+	// KeyTypeP256 is P-256 with a SEC1 public key
+	KeyTypeP256 KeyType = 0x01
+	// KeyTypeSecp256k1 is secp256k1 with a SEC1 public key
+	KeyTypeSecp256k1 KeyType = 0x02
+)
+
+const (
+	pubKeyCompressedLen   = 33
+	pubKeyUncompressedLen = 65
+)
+
+// ParseKeyType returns the key type called name
+func ParseKeyType(name string) (KeyType, error) {
+	switch name {
+	case "legacy":
+		return KeyTypeLegacy, nil
+	case "p256":
+		return KeyTypeP256, nil
+	case "secp256k1":
+		return KeyTypeSecp256k1, nil
+	default:
+		return 0, fmt.Errorf("unknown key type %q, use p256, secp256k1 or legacy", name)
+	}
+}
+
+// String returns the name of the key type
+func (t KeyType) String() string {
+	switch t {
+	case KeyTypeLegacy:
+		return "legacy"
+	case KeyTypeP256:
+		return "p256"
+	case KeyTypeSecp256k1:
+		return "secp256k1"
+	default:
+		return fmt.Sprintf("KeyType(0x%02x)", byte(t))
+	}
+}
+
+// Curve returns the elliptic curve keys of this type live on
+func (t KeyType) Curve() elliptic.Curve {
+	if t == KeyTypeSecp256k1 {
+		return secp256k1.S256()
+	}
+
+	return elliptic.P256()
+}
+
+// curveA returns the a coefficient of the curve equation y² = x³ + ax + b
+func (t KeyType) curveA() *big.Int {
+	if t == KeyTypeSecp256k1 {
+		return big.NewInt(0)
+	}
+
+	return big.NewInt(-3)
+}
+
+// EncodePubKey serializes a public key as its key type followed by the SEC1 encoding,
+// or as the unpadded X||Y for legacy keys
+func EncodePubKey(keyType KeyType, pub *ecdsa.PublicKey, compressed bool) []byte {
+	if keyType == KeyTypeLegacy {
+		return append(pub.X.Bytes(), pub.Y.Bytes()...)
+	}
+
+	byteLen := (pub.Curve.Params().BitSize + 7) / 8
+	var sec1 []byte
+
+	if compressed {
+		sec1 = make([]byte, 1+byteLen)
+		sec1[0] = 0x02 + byte(pub.Y.Bit(0))
+	} else {
+		sec1 = make([]byte, 1+2*byteLen)
+		sec1[0] = 0x04
+		pub.Y.FillBytes(sec1[1+byteLen:])
+	}
+	pub.X.FillBytes(sec1[1 : 1+byteLen])
+
+	return append([]byte{byte(keyType)}, sec1...)
+}
+
+// DecodePubKey parses a public key written by EncodePubKey. Keys of up to 64 bytes
+// without a key type are read as legacy X||Y P-256 keys
+func DecodePubKey(data []byte) (*ecdsa.PublicKey, error) {
+	if len(data) == pubKeyCompressedLen+1 || len(data) == pubKeyUncompressedLen+1 {
+		keyType := KeyType(data[0])
+		if keyType == KeyTypeP256 || keyType == KeyTypeSecp256k1 {
+			return decodeSEC1(keyType, data[1:])
+		}
+	}
+
+	if len(data) > 0 && len(data) <= 64 {
+		return decodeLegacyPubKey(data)
+	}
+
+	return nil, fmt.Errorf("public key of %d bytes has an unknown encoding", len(data))
+}
+
+// decodeLegacyPubKey parses an unpadded X||Y P-256 key. Either coordinate may have
+// lost leading zero bytes, so every split that leaves both halves at most 32 bytes is tried
+func decodeLegacyPubKey(data []byte) (*ecdsa.PublicKey, error) {
+	curve := elliptic.P256()
+
+	for xLen := len(data) - 32; xLen <= 32 && xLen <= len(data); xLen++ {
+		if xLen < 0 {
+			continue
+		}
+
+		x := new(big.Int).SetBytes(data[:xLen])
+		y := new(big.Int).SetBytes(data[xLen:])
+		if curve.IsOnCurve(x, y) {
+			return &ecdsa.PublicKey{Curve: curve, X: x, Y: y}, nil
+		}
+	}
+
+	return nil, errors.New("legacy public key is not on the curve")
+}
+
+// decodeSEC1 parses a compressed or uncompressed SEC1 point
+func decodeSEC1(keyType KeyType, sec1 []byte) (*ecdsa.PublicKey, error) {
+	curve := keyType.Curve()
+	params := curve.Params()
+	byteLen := (params.BitSize + 7) / 8
+	x := new(big.Int).SetBytes(sec1[1 : 1+byteLen])
+	var y *big.Int
+
+	switch {
+	case sec1[0] == 0x04 && len(sec1) == 1+2*byteLen:
+		y = new(big.Int).SetBytes(sec1[1+byteLen:])
+	case (sec1[0] == 0x02 || sec1[0] == 0x03) && len(sec1) == 1+byteLen:
+		if x.Cmp(params.P) >= 0 {
+			return nil, errors.New("public key X is out of range")
+		}
+
+		var err error
+		y, err = decompressY(keyType, x, sec1[0] == 0x03)
+		if err != nil {
+			return nil, err
+		}
+	default:
+		return nil, fmt.Errorf("SEC1 public key has prefix 0x%02x and length %d", sec1[0], len(sec1))
+	}
+
+	if !curve.IsOnCurve(x, y) {
+		return nil, errors.New("public key is not on the curve")
+	}
+
+	return &ecdsa.PublicKey{Curve: curve, X: x, Y: y}, nil
+}
+
+// decompressY returns the Y coordinate of the curve point with the given X and Y parity
+func decompressY(keyType KeyType, x *big.Int, odd bool) (*big.Int, error) {
+	params := keyType.Curve().Params()
+
+	// y² = x³ + ax + b
+	y2 := new(big.Int).Exp(x, big.NewInt(3), params.P)
+	ax := new(big.Int).Mul(keyType.curveA(), x)
+	y2.Add(y2, ax)
+	y2.Add(y2, params.B)
+	y2.Mod(y2, params.P)
+
+	y := new(big.Int).ModSqrt(y2, params.P)
+	if y == nil {
+		return nil, errors.New("X is not on the curve")
+	}
+	if (y.Bit(0) == 1) != odd {
+		y.Sub(params.P, y)
+	}
+
+	return y, nil
+}
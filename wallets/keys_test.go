@@ -0,0 +1,140 @@
+package wallets
+
+import (
+	"crypto/ecdsa"
+	"encoding/hex"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+// pubKeyVectors are the SEC1 encodings of the generator of each curve, the public key
+// of the private key 1
+var pubKeyVectors = []struct {
+	keyType      KeyType
+	compressed   string
+	uncompressed string
+}{
+	{
+		KeyTypeSecp256k1,
+		"0279be667ef9dcbbac55a06295ce870b07029bfcdb2dce28d959f2815b16f81798",
+		"0479be667ef9dcbbac55a06295ce870b07029bfcdb2dce28d959f2815b16f81798483ada7726a3c4655da4fbfc0e1108a8fd17b448a68554199c47d08ffb10d4b8",
+	},
+	{
+		KeyTypeP256,
+		"036b17d1f2e12c4247f8bce6e563a440f277037d812deb33a0f4a13945d898c296",
+		"046b17d1f2e12c4247f8bce6e563a440f277037d812deb33a0f4a13945d898c2964fe342e2fe1a7f9b8ee7eb4a7c0f9e162bce33576b315ececbb6406837bf51f5",
+	},
+}
+
+// legacyKeyWith returns a legacy key whose X, or Y when shortX is false, has a leading zero byte
+func legacyKeyWith(shortX bool) *ecdsa.PublicKey {
+	for {
+		pub := NewWallet(KeyTypeLegacy).PrivateKey.PublicKey
+		if shortX && len(pub.X.Bytes()) < 32 || !shortX && len(pub.Y.Bytes()) < 32 {
+			return &pub
+		}
+	}
+}
+
+var _ = Describe("Public keys", func() {
+	Describe("EncodePubKey", func() {
+		It("should produce the SEC1 encodings of the generators after the key type", func() {
+			for _, vector := range pubKeyVectors {
+				pub := privateKeyOne(vector.keyType).PublicKey
+
+				encoded := EncodePubKey(vector.keyType, &pub, true)
+				Expect(encoded[0]).To(Equal(byte(vector.keyType)))
+				Expect(hex.EncodeToString(encoded[1:])).To(Equal(vector.compressed), vector.keyType.String())
+
+				encoded = EncodePubKey(vector.keyType, &pub, false)
+				Expect(encoded[0]).To(Equal(byte(vector.keyType)))
+				Expect(hex.EncodeToString(encoded[1:])).To(Equal(vector.uncompressed), vector.keyType.String())
+			}
+		})
+
+		It("should write legacy keys as the unpadded X||Y", func() {
+			pub := legacyKeyWith(true)
+
+			encoded := EncodePubKey(KeyTypeLegacy, pub, true)
+			Expect(encoded).To(Equal(append(pub.X.Bytes(), pub.Y.Bytes()...)))
+			Expect(len(encoded)).To(BeNumerically("<", 64))
+		})
+	})
+
+	Describe("DecodePubKey", func() {
+		It("should decode the SEC1 encodings of the generators", func() {
+			for _, vector := range pubKeyVectors {
+				want := privateKeyOne(vector.keyType).PublicKey
+
+				for _, sec1 := range []string{vector.compressed, vector.uncompressed} {
+					data, err := hex.DecodeString(sec1)
+					Expect(err).NotTo(HaveOccurred())
+
+					pub, err := DecodePubKey(append([]byte{byte(vector.keyType)}, data...))
+					Expect(err).NotTo(HaveOccurred(), sec1)
+					Expect(pub.Curve).To(Equal(vector.keyType.Curve()))
+					Expect(pub.X).To(Equal(want.X), sec1)
+					Expect(pub.Y).To(Equal(want.Y), sec1)
+				}
+			}
+		})
+
+		It("should round trip compressed and uncompressed keys of both parities", func() {
+			for _, keyType := range []KeyType{KeyTypeP256, KeyTypeSecp256k1} {
+				parities := make(map[uint]bool)
+
+				for i := 0; i < 16 || len(parities) < 2; i++ {
+					pub := NewWallet(keyType).PrivateKey.PublicKey
+					parities[pub.Y.Bit(0)] = true
+
+					for _, compressed := range []bool{true, false} {
+						decoded, err := DecodePubKey(EncodePubKey(keyType, &pub, compressed))
+						Expect(err).NotTo(HaveOccurred())
+						Expect(decoded.X).To(Equal(pub.X))
+						Expect(decoded.Y).To(Equal(pub.Y))
+					}
+				}
+			}
+		})
+
+		It("should split legacy keys whose X or Y lost a leading zero byte", func() {
+			for _, shortX := range []bool{true, false} {
+				pub := legacyKeyWith(shortX)
+
+				decoded, err := DecodePubKey(EncodePubKey(KeyTypeLegacy, pub, true))
+				Expect(err).NotTo(HaveOccurred())
+				Expect(decoded.X).To(Equal(pub.X))
+				Expect(decoded.Y).To(Equal(pub.Y))
+			}
+		})
+
+		It("should reject keys off the curve or with an unknown encoding", func() {
+			generator, err := hex.DecodeString(pubKeyVectors[0].compressed)
+			Expect(err).NotTo(HaveOccurred())
+			uncompressed, err := hex.DecodeString(pubKeyVectors[0].uncompressed)
+			Expect(err).NotTo(HaveOccurred())
+
+			badPrefix := append([]byte{byte(KeyTypeSecp256k1), 0x05}, generator[1:]...)
+			offCurve := append([]byte{byte(KeyTypeSecp256k1)}, uncompressed...)
+			offCurve[len(offCurve)-1] ^= 0x01
+			// X = p, which is outside the field
+			xOutOfRange, err := hex.DecodeString("02fffffffffffffffffffffffffffffffffffffffffffffffffffffffefffffc2f")
+			Expect(err).NotTo(HaveOccurred())
+			legacyOffCurve := make([]byte, 64)
+			legacyOffCurve[63] = 1
+
+			for _, invalid := range [][]byte{
+				nil,
+				badPrefix,
+				offCurve,
+				append([]byte{byte(KeyTypeSecp256k1)}, xOutOfRange...),
+				append(append([]byte{byte(KeyTypeSecp256k1)}, uncompressed...), 0x00),
+				legacyOffCurve,
+			} {
+				_, err := DecodePubKey(invalid)
+				Expect(err).To(HaveOccurred(), "decoding %x", invalid)
+			}
+		})
+	})
+})
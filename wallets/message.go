@@ -0,0 +1,84 @@
+package wallets
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+)
+
+// messageMagic is prepended to signed messages so they can never pass for transactions
+const messageMagic = "Blockchain Signed Message:\n"
+
+// messageHeaderBase is the first header byte of a message signature. The header adds
+// 4 times the key type and the recovery ID
+const messageHeaderBase = 27
+
+// MessageHash returns the double SHA-256 of the length prefixed magic and message
+func MessageHash(message string) []byte {
+	var buf bytes.Buffer
+	varint := make([]byte, binary.MaxVarintLen64)
+
+	buf.Write(varint[:binary.PutUvarint(varint, uint64(len(messageMagic)))])
+	buf.WriteString(messageMagic)
+	buf.Write(varint[:binary.PutUvarint(varint, uint64(len(message)))])
+	buf.WriteString(message)
+
+	firstSHA := sha256.Sum256(buf.Bytes())
+	secondSHA := sha256.Sum256(firstSHA[:])
+
+	return secondSHA[:]
+}
+
+// SignMessage signs message with the wallet key and returns the Base64 encoded
+// recoverable signature
+func (w Wallet) SignMessage(message string) (string, error) {
+	if w.PrivateKey.D == nil {
+		return "", ErrWalletLocked
+	}
+
+	signature := SignRecoverable(&w.PrivateKey, MessageHash(message))
+	signature[0] += messageHeaderBase + 4*byte(w.KeyType)
+
+	return base64.StdEncoding.EncodeToString(signature), nil
+}
+
+// VerifyMessage checks that signature was made over message by the key of address.
+// The public key is recovered from the signature and hashed into the address
+func VerifyMessage(address, signature, message string) error {
+	pubKeyHash, err := DecodeAddress(address)
+	if err != nil {
+		return err
+	}
+
+	sig, err := base64.StdEncoding.DecodeString(signature)
+	if err != nil {
+		return fmt.Errorf("signature is not Base64: %v", err)
+	}
+	if len(sig) != 1+SignatureLen {
+		return fmt.Errorf("signature has length %d, expected %d", len(sig), 1+SignatureLen)
+	}
+	if sig[0] < messageHeaderBase {
+		return fmt.Errorf("signature has invalid header %d", sig[0])
+	}
+
+	header := sig[0] - messageHeaderBase
+	keyType := KeyType(header / 4)
+	if keyType != KeyTypeLegacy && keyType != KeyTypeP256 && keyType != KeyTypeSecp256k1 {
+		return fmt.Errorf("signature has invalid header %d", sig[0])
+	}
+
+	recoverable := append([]byte{header % 4}, sig[1:]...)
+	pubKey, err := RecoverPubKey(keyType, MessageHash(message), recoverable)
+	if err != nil {
+		return err
+	}
+
+	if !bytes.Equal(HashPubKey(EncodePubKey(keyType, pubKey, true)), pubKeyHash) {
+		return errors.New("signature was not made by the key of the address")
+	}
+
+	return nil
+}
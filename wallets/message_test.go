@@ -0,0 +1,214 @@
+package wallets
+
+import (
+	"crypto/ecdsa"
+	"encoding/base64"
+	"fmt"
+	"math/big"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var keyTypes = []KeyType{KeyTypeLegacy, KeyTypeP256, KeyTypeSecp256k1}
+
+// messageVectors are the signatures of "Hello, world" by the private key 1 of each key type
+var messageVectors = map[KeyType]string{
+	KeyTypeLegacy:    "G5p37GunQ6FetbfauWFfKtIfWIqTbKOnRWSTw13IwEEJQQiUl1NfDIUmLqIPhWJD352oH4WTnRfoPx65gvxR+Zs=",
+	KeyTypeP256:      "H5p37GunQ6FetbfauWFfKtIfWIqTbKOnRWSTw13IwEEJQQiUl1NfDIUmLqIPhWJD352oH4WTnRfoPx65gvxR+Zs=",
+	KeyTypeSecp256k1: "JJ6vsVJkJCATL3dorPmYApnc59O0cYvxYrPYoVawfaS9AMNLfViD9bIct8o3iopBNm9Uvk5wk/a0LZXjABX4SO4=",
+}
+
+// walletOfKey returns the wallet of the private key d
+func walletOfKey(keyType KeyType, d int64) *Wallet {
+	return newWalletFromKey(keyType, privateKeyFromBytes(keyType, big.NewInt(d).Bytes()))
+}
+
+// addressOf returns the Base58Check address of a wallet
+func addressOf(wallet *Wallet) string {
+	return EncodeAddress(HashPubKey(wallet.PublicKey))
+}
+
+// withHeader returns the Base64 signature with its header byte replaced
+func withHeader(signature string, header byte) string {
+	sig, err := base64.StdEncoding.DecodeString(signature)
+	Expect(err).NotTo(HaveOccurred())
+	sig[0] = header
+
+	return base64.StdEncoding.EncodeToString(sig)
+}
+
+// reducedRSignature builds a recoverable signature of hash whose R.x is at least N, which
+// a real nonce hits with a probability of about 2^-128. The public key is worked out from
+// R, as RecoverPubKey would
+func reducedRSignature(keyType KeyType, hash []byte) ([]byte, *ecdsa.PublicKey) {
+	curve := keyType.Curve()
+	params := curve.Params()
+	N := params.N
+
+	Rx := new(big.Int).Set(N)
+	var Ry *big.Int
+	for {
+		Rx.Add(Rx, big.NewInt(1))
+		Expect(Rx.Cmp(params.P)).To(BeNumerically("<", 0))
+
+		y, err := decompressY(keyType, Rx, false)
+		if err == nil {
+			Ry = y
+			break
+		}
+	}
+
+	r := new(big.Int).Sub(Rx, N)
+	s := big.NewInt(1)
+
+	// Q = r⁻¹(sR - eG)
+	e := hashToInt(hash, N)
+	eGx, eGy := curve.ScalarBaseMult(new(big.Int).Mod(e, N).Bytes())
+	eGy.Sub(params.P, eGy)
+	Qx, Qy := curve.Add(Rx, Ry, eGx, eGy)
+	Qx, Qy = curve.ScalarMult(Qx, Qy, new(big.Int).ModInverse(r, N).Bytes())
+
+	signature := make([]byte, 1+SignatureLen)
+	signature[0] = 2 | byte(Ry.Bit(0))
+	r.FillBytes(signature[1 : 1+SignatureLen/2])
+	s.FillBytes(signature[1+SignatureLen/2:])
+
+	return signature, &ecdsa.PublicKey{Curve: curve, X: Qx, Y: Qy}
+}
+
+var _ = Describe("Message signing", func() {
+	Describe("SignMessage and VerifyMessage", func() {
+		It("should round trip for every key type", func() {
+			for _, keyType := range keyTypes {
+				wallet := NewWallet(keyType)
+
+				signature, err := wallet.SignMessage("Hello, world")
+				Expect(err).NotTo(HaveOccurred())
+				Expect(VerifyMessage(addressOf(wallet), signature, "Hello, world")).To(Succeed(), "%s", keyType)
+				Expect(VerifyMessage(wallet.GetBech32Address(), signature, "Hello, world")).To(Succeed(), "%s", keyType)
+			}
+		})
+
+		It("should reject a tampered message", func() {
+			for _, keyType := range keyTypes {
+				wallet := NewWallet(keyType)
+
+				signature, err := wallet.SignMessage("Pay 10 coins")
+				Expect(err).NotTo(HaveOccurred())
+				Expect(VerifyMessage(addressOf(wallet), signature, "Pay 100 coins")).NotTo(Succeed(), "%s", keyType)
+			}
+		})
+
+		It("should reject the address of another key", func() {
+			for _, keyType := range keyTypes {
+				wallet := NewWallet(keyType)
+				other := NewWallet(keyType)
+
+				signature, err := wallet.SignMessage("Hello, world")
+				Expect(err).NotTo(HaveOccurred())
+				Expect(VerifyMessage(addressOf(other), signature, "Hello, world")).NotTo(Succeed(), "%s", keyType)
+			}
+		})
+
+		It("should reject bad headers and lengths", func() {
+			wallet := walletOfKey(KeyTypeSecp256k1, 1)
+			address := addressOf(wallet)
+			signature := messageVectors[KeyTypeSecp256k1]
+
+			for _, header := range []byte{0, 26, messageHeaderBase + 12, 255} {
+				Expect(VerifyMessage(address, withHeader(signature, header), "Hello, world")).NotTo(Succeed(), "header %d", header)
+			}
+			// a header of another key type recovers a key on the wrong curve
+			Expect(VerifyMessage(address, withHeader(signature, messageHeaderBase+1), "Hello, world")).NotTo(Succeed())
+
+			sig, err := base64.StdEncoding.DecodeString(signature)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(VerifyMessage(address, base64.StdEncoding.EncodeToString(sig[:len(sig)-1]), "Hello, world")).NotTo(Succeed())
+			Expect(VerifyMessage(address, "not base64!", "Hello, world")).NotTo(Succeed())
+		})
+
+		It("should refuse to sign without the private key", func() {
+			wallet := NewWallet(KeyTypeP256)
+			wallet.PrivateKey.D = nil
+
+			_, err := wallet.SignMessage("Hello, world")
+			Expect(err).To(Equal(ErrWalletLocked))
+		})
+	})
+
+	Describe("header format", func() {
+		It("should match the fixed vectors", func() {
+			for _, keyType := range keyTypes {
+				wallet := walletOfKey(keyType, 1)
+
+				signature, err := wallet.SignMessage("Hello, world")
+				Expect(err).NotTo(HaveOccurred())
+				Expect(signature).To(Equal(messageVectors[keyType]), "%s", keyType)
+				Expect(VerifyMessage(addressOf(wallet), signature, "Hello, world")).To(Succeed())
+			}
+		})
+
+		It("should encode the key type and the parity of R.y", func() {
+			for _, keyType := range keyTypes {
+				wallet := walletOfKey(keyType, 7)
+				seen := map[byte]bool{}
+
+				for i := 0; i < 16; i++ {
+					message := fmt.Sprintf("message %d", i)
+					signature, err := wallet.SignMessage(message)
+					Expect(err).NotTo(HaveOccurred())
+					sig, err := base64.StdEncoding.DecodeString(signature)
+					Expect(err).NotTo(HaveOccurred())
+
+					recID := sig[0] - messageHeaderBase - 4*byte(keyType)
+					Expect(recID).To(BeNumerically("<=", 1), "%s %q", keyType, message)
+					seen[recID] = true
+
+					// the other parity recovers another key
+					flipped := withHeader(signature, sig[0]^1)
+					Expect(VerifyMessage(addressOf(wallet), flipped, message)).NotTo(Succeed(), "%s %q", keyType, message)
+				}
+
+				Expect(seen).To(HaveKey(byte(0)), "%s", keyType)
+				Expect(seen).To(HaveKey(byte(1)), "%s", keyType)
+			}
+		})
+
+		It("should recover keys from signatures whose R.x was reduced modulo N", func() {
+			for _, keyType := range []KeyType{KeyTypeP256, KeyTypeSecp256k1} {
+				message := "R.x >= N"
+				hash := MessageHash(message)
+				recoverable, pubKey := reducedRSignature(keyType, hash)
+
+				recovered, err := RecoverPubKey(keyType, hash, recoverable)
+				Expect(err).NotTo(HaveOccurred(), "%s", keyType)
+				Expect(recovered.X).To(Equal(pubKey.X))
+				Expect(recovered.Y).To(Equal(pubKey.Y))
+
+				// without bit 1 the point at x = r is used, which is another key
+				cleared := append([]byte{recoverable[0] &^ 2}, recoverable[1:]...)
+				other, err := RecoverPubKey(keyType, hash, cleared)
+				if err == nil {
+					Expect(other.X).NotTo(Equal(pubKey.X))
+				}
+
+				signature := append([]byte{recoverable[0] + messageHeaderBase + 4*byte(keyType)}, recoverable[1:]...)
+				address := EncodeAddress(HashPubKey(EncodePubKey(keyType, pubKey, true)))
+				Expect(VerifyMessage(address, base64.StdEncoding.EncodeToString(signature), message)).To(Succeed(), "%s", keyType)
+			}
+		})
+
+		It("should reject recovery IDs out of range", func() {
+			wallet := walletOfKey(KeyTypeP256, 1)
+			hash := MessageHash("Hello, world")
+			recoverable := SignRecoverable(&wallet.PrivateKey, hash)
+
+			recoverable[0] = 4
+			_, err := RecoverPubKey(KeyTypeP256, hash, recoverable)
+			Expect(err).To(HaveOccurred())
+			_, err = RecoverPubKey(KeyTypeP256, hash, recoverable[:SignatureLen])
+			Expect(err).To(HaveOccurred())
+		})
+	})
+})
@@ -0,0 +1,27 @@
+package wallets
+
+import "github.com/cyprus09/blockchain/chaincfg"
+
+// networksWithAddrID names the networks using version as Base58Check version byte
+func networksWithAddrID(version byte) []string {
+	var names []string
+
+	for _, params := range chaincfg.Networks {
+		if params.PubKeyHashAddrID == version {
+			names = append(names, params.Name)
+		}
+	}
+
+	return names
+}
+
+// networkWithHRP names the network whose Bech32 addresses start with hrp
+func networkWithHRP(hrp string) string {
+	for _, params := range chaincfg.Networks {
+		if params.Bech32HRP == hrp {
+			return params.Name
+		}
+	}
+
+	return ""
+}
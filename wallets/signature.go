@@ -0,0 +1,233 @@
+package wallets
+
+import (
+	"crypto/ecdsa"
+	"crypto/hmac"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"math/big"
+)
+
+// SignatureLen is the size of a compact r||s signature
+const SignatureLen = 64
+
+// Sign signs hash with a deterministic RFC 6979 nonce and returns the 64 byte compact
+// r||s signature, with s in the lower half of the curve order
+func Sign(privKey *ecdsa.PrivateKey, hash []byte) []byte {
+	r, s, _ := sign(privKey, hash)
+
+	signature := make([]byte, SignatureLen)
+	r.FillBytes(signature[:SignatureLen/2])
+	s.FillBytes(signature[SignatureLen/2:])
+
+	return signature
+}
+
+// SignRecoverable signs hash like Sign and prefixes the signature with the recovery ID
+// RecoverPubKey needs to rebuild the public key
+func SignRecoverable(privKey *ecdsa.PrivateKey, hash []byte) []byte {
+	r, s, recID := sign(privKey, hash)
+
+	signature := make([]byte, 1+SignatureLen)
+	signature[0] = recID
+	r.FillBytes(signature[1 : 1+SignatureLen/2])
+	s.FillBytes(signature[1+SignatureLen/2:])
+
+	return signature
+}
+
+// sign returns a low-S signature of hash and its recovery ID. Bit 0 of the recovery ID
+// is the parity of R.y and bit 1 is set when R.x was reduced modulo N
+func sign(privKey *ecdsa.PrivateKey, hash []byte) (*big.Int, *big.Int, byte) {
+	curve := privKey.Curve
+	N := curve.Params().N
+	e := hashToInt(hash, N)
+	nonce := newRFC6979(privKey.D, e, N)
+
+	for {
+		k := nonce.next()
+
+		x, y := curve.ScalarBaseMult(k.Bytes())
+		r := new(big.Int).Mod(x, N)
+		if r.Sign() == 0 {
+			continue
+		}
+
+		// s = k⁻¹(e + rd) mod N
+		s := new(big.Int).Mul(r, privKey.D)
+		s.Add(s, e)
+		s.Mul(s, new(big.Int).ModInverse(k, N))
+		s.Mod(s, N)
+		if s.Sign() == 0 {
+			continue
+		}
+
+		recID := byte(y.Bit(0))
+		if x.Cmp(N) >= 0 {
+			recID |= 2
+		}
+
+		// (r, s) and (r, N-s) both verify, only the low one is canonical.
+		// N-s is the signature of -k, whose R has the opposite parity
+		if s.Cmp(halfOrder(N)) > 0 {
+			s.Sub(N, s)
+			recID ^= 1
+		}
+
+		return r, s, recID
+	}
+}
+
+// RecoverPubKey rebuilds the keyType public key that made a signature of SignRecoverable
+func RecoverPubKey(keyType KeyType, hash, signature []byte) (*ecdsa.PublicKey, error) {
+	if len(signature) != 1+SignatureLen {
+		return nil, fmt.Errorf("recoverable signature has length %d, expected %d", len(signature), 1+SignatureLen)
+	}
+
+	curve := keyType.Curve()
+	params := curve.Params()
+	N := params.N
+	recID := signature[0]
+	r := new(big.Int).SetBytes(signature[1 : 1+SignatureLen/2])
+	s := new(big.Int).SetBytes(signature[1+SignatureLen/2:])
+
+	if recID > 3 {
+		return nil, fmt.Errorf("recovery ID %d is out of range", recID)
+	}
+	if r.Sign() == 0 || r.Cmp(N) >= 0 || s.Sign() == 0 || s.Cmp(halfOrder(N)) > 0 {
+		return nil, errors.New("signature is not canonical")
+	}
+
+	// R.x is r, or r+N when it was reduced
+	Rx := new(big.Int).Set(r)
+	if recID&2 != 0 {
+		Rx.Add(Rx, N)
+		if Rx.Cmp(params.P) >= 0 {
+			return nil, errors.New("signature R.x is out of range")
+		}
+	}
+	Ry, err := decompressY(keyType, Rx, recID&1 == 1)
+	if err != nil {
+		return nil, err
+	}
+
+	// Q = r⁻¹(sR - eG)
+	e := hashToInt(hash, N)
+	sRx, sRy := curve.ScalarMult(Rx, Ry, s.Bytes())
+	eGx, eGy := curve.ScalarBaseMult(new(big.Int).Mod(e, N).Bytes())
+	eGy.Sub(params.P, eGy)
+	Qx, Qy := curve.Add(sRx, sRy, eGx, eGy)
+	Qx, Qy = curve.ScalarMult(Qx, Qy, new(big.Int).ModInverse(r, N).Bytes())
+
+	if Qx.Sign() == 0 && Qy.Sign() == 0 {
+		return nil, errors.New("recovered public key is the point at infinity")
+	}
+
+	pubKey := &ecdsa.PublicKey{Curve: curve, X: Qx, Y: Qy}
+	if !Verify(pubKey, hash, signature[1:]) {
+		return nil, errors.New("signature does not verify with the recovered public key")
+	}
+
+	return pubKey, nil
+}
+
+// Verify checks a compact signature made by Sign. Signatures of the wrong size, with
+// r or s out of range or with a high s are rejected
+func Verify(pubKey *ecdsa.PublicKey, hash, signature []byte) bool {
+	if len(signature) != SignatureLen {
+		return false
+	}
+
+	N := pubKey.Curve.Params().N
+	r := new(big.Int).SetBytes(signature[:SignatureLen/2])
+	s := new(big.Int).SetBytes(signature[SignatureLen/2:])
+
+	if r.Sign() == 0 || r.Cmp(N) >= 0 || s.Sign() == 0 || s.Cmp(halfOrder(N)) > 0 {
+		return false
+	}
+
+	return ecdsa.Verify(pubKey, hash, r, s)
+}
+
+// halfOrder returns N/2, the largest canonical s
+func halfOrder(N *big.Int) *big.Int {
+	return new(big.Int).Rsh(N, 1)
+}
+
+// hashToInt converts a hash to an integer the way ECDSA does, keeping its leftmost bits
+func hashToInt(hash []byte, N *big.Int) *big.Int {
+	orderBits := N.BitLen()
+	orderBytes := (orderBits + 7) / 8
+	if len(hash) > orderBytes {
+		hash = hash[:orderBytes]
+	}
+
+	e := new(big.Int).SetBytes(hash)
+	if excess := len(hash)*8 - orderBits; excess > 0 {
+		e.Rsh(e, uint(excess))
+	}
+
+	return e
+}
+
+// rfc6979 generates the nonces of RFC 6979 section 3.2 with HMAC-SHA256
+type rfc6979 struct {
+	N    *big.Int
+	K, V []byte
+	// started is set once the first candidate nonce was returned
+	started bool
+}
+
+// newRFC6979 seeds the nonce generator with the private key d and the hash e
+func newRFC6979(d, e *big.Int, N *big.Int) *rfc6979 {
+	size := (N.BitLen() + 7) / 8
+	x := d.FillBytes(make([]byte, size))
+	h := new(big.Int).Mod(e, N).FillBytes(make([]byte, size))
+
+	g := &rfc6979{N: N, K: make([]byte, sha256.Size), V: make([]byte, sha256.Size)}
+	for i := range g.V {
+		g.V[i] = 0x01
+	}
+
+	g.K = g.mac(g.V, []byte{0x00}, x, h)
+	g.V = g.mac(g.V)
+	g.K = g.mac(g.V, []byte{0x01}, x, h)
+	g.V = g.mac(g.V)
+
+	return g
+}
+
+// next returns the next candidate nonce in [1, N-1]
+func (g *rfc6979) next() *big.Int {
+	size := (g.N.BitLen() + 7) / 8
+
+	for {
+		if g.started {
+			g.K = g.mac(g.V, []byte{0x00})
+			g.V = g.mac(g.V)
+		}
+		g.started = true
+
+		var t []byte
+		for len(t) < size {
+			g.V = g.mac(g.V)
+			t = append(t, g.V...)
+		}
+
+		k := hashToInt(t, g.N)
+		if k.Sign() > 0 && k.Cmp(g.N) < 0 {
+			return k
+		}
+	}
+}
+
+// mac returns HMAC-SHA256 under the current K of the concatenated parts
+func (g *rfc6979) mac(parts ...[]byte) []byte {
+	mac := hmac.New(sha256.New, g.K)
+	for _, part := range parts {
+		mac.Write(part)
+	}
+
+	return mac.Sum(nil)
+}
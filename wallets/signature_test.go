@@ -0,0 +1,193 @@
+package wallets
+
+import (
+	"crypto/ecdsa"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"math/big"
+	"testing"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+func TestWallets(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "Wallets Suite")
+}
+
+// signatureVector is a known answer for a private key and a message hashed with SHA-256.
+// s is the signature as published, before low-S normalisation
+type signatureVector struct {
+	keyType KeyType
+	d       string
+	message string
+	k       string
+	r       string
+	s       string
+}
+
+var signatureVectors = []signatureVector{
+	// RFC 6979 A.2.5, P-256 with SHA-256
+	{
+		KeyTypeP256,
+		"c9afa9d845ba75166b5c215767b1d6934e50c3db36e89b127b8a622b120f6721",
+		"sample",
+		"a6e3c57dd01abe90086538398355dd4c3b17aa873382b0f24d6129493d8aad60",
+		"efd48b2aacb6a8fd1140dd9cd45e81d69d2c877b56aaf991c34d0ea84eaf3716",
+		"f7cb1c942d657c41d436c7a1b6e29f65f3e900dbb9aff4064dc4ab2f843acda8",
+	},
+	{
+		KeyTypeP256,
+		"c9afa9d845ba75166b5c215767b1d6934e50c3db36e89b127b8a622b120f6721",
+		"test",
+		"d16b6ae827f17175e040871a1c7ec3500192c4c92677336ec2537acaee0008e0",
+		"f1abb023518351cd71d881567b1ea663ed3efcf6c5132b354f28d3b0b7d38367",
+		"019f4113742a2b14bd25926b49c649155f267e60d3814b4c0cc84250e46f0083",
+	},
+	// secp256k1 with SHA-256, as used by the deterministic signature tests of Bitcoin wallets
+	{
+		KeyTypeSecp256k1,
+		"0000000000000000000000000000000000000000000000000000000000000001",
+		"Satoshi Nakamoto",
+		"8f8a276c19f4149656b280621e358cce24f5f52542772691ee69063b74f15d15",
+		"934b1ea10a4b3c1757e2b0c017d0b6143ce3c9a7e6a4a49860d7a6ab210ee3d8",
+		"2442ce9d2b916064108014783e923ec36b49743e2ffa1c4496f01a512aafd9e5",
+	},
+	{
+		KeyTypeSecp256k1,
+		"0000000000000000000000000000000000000000000000000000000000000001",
+		"All those moments will be lost in time, like tears in rain. Time to die...",
+		"38aa22d72376b4dbc472e06c3ba403ee0a394da63fc58d88686c611aba98d6b3",
+		"8600dbd41e348fe5c9465ab92d23e3db8b98b873beecd930736488696438cb6b",
+		"547fe64427496db33bf66019dacbf0039c04199abb0122918601db38a72cfc21",
+	},
+}
+
+// hexInt parses a hex test value
+func hexInt(value string) *big.Int {
+	i, ok := new(big.Int).SetString(value, 16)
+	Expect(ok).To(BeTrue(), "invalid hex %s", value)
+
+	return i
+}
+
+// vectorKey returns the private key of a vector
+func vectorKey(vector signatureVector) *ecdsa.PrivateKey {
+	d, err := hex.DecodeString(vector.d)
+	Expect(err).NotTo(HaveOccurred())
+	privKey := privateKeyFromBytes(vector.keyType, d)
+
+	return &privKey
+}
+
+// compactSignature joins r and s into the 64 byte form Verify takes
+func compactSignature(r, s *big.Int) []byte {
+	signature := make([]byte, SignatureLen)
+	r.FillBytes(signature[:SignatureLen/2])
+	s.FillBytes(signature[SignatureLen/2:])
+
+	return signature
+}
+
+var _ = Describe("Signature", func() {
+	Describe("Sign", func() {
+		It("should derive the RFC 6979 nonce of the known answers", func() {
+			for _, vector := range signatureVectors {
+				privKey := vectorKey(vector)
+				N := privKey.Curve.Params().N
+				hash := sha256.Sum256([]byte(vector.message))
+
+				nonce := newRFC6979(privKey.D, hashToInt(hash[:], N), N)
+				Expect(nonce.next()).To(Equal(hexInt(vector.k)), "%s %q", vector.keyType, vector.message)
+			}
+		})
+
+		It("should produce the known answer signatures with a low s", func() {
+			for _, vector := range signatureVectors {
+				privKey := vectorKey(vector)
+				N := privKey.Curve.Params().N
+				hash := sha256.Sum256([]byte(vector.message))
+
+				s := hexInt(vector.s)
+				if s.Cmp(halfOrder(N)) > 0 {
+					s.Sub(N, s)
+				}
+				expected := compactSignature(hexInt(vector.r), s)
+
+				signature := Sign(privKey, hash[:])
+				Expect(hex.EncodeToString(signature)).To(Equal(hex.EncodeToString(expected)), "%s %q", vector.keyType, vector.message)
+				Expect(Verify(&privKey.PublicKey, hash[:], signature)).To(BeTrue())
+			}
+		})
+
+		It("should normalise s to the lower half of the order", func() {
+			for _, keyType := range []KeyType{KeyTypeP256, KeyTypeSecp256k1} {
+				wallet := NewWallet(keyType)
+				N := keyType.Curve().Params().N
+
+				for i := 0; i < 32; i++ {
+					hash := sha256.Sum256([]byte(fmt.Sprintf("message %d", i)))
+					signature := Sign(&wallet.PrivateKey, hash[:])
+
+					s := new(big.Int).SetBytes(signature[SignatureLen/2:])
+					Expect(s.Cmp(halfOrder(N))).To(BeNumerically("<=", 0))
+					Expect(Verify(&wallet.PrivateKey.PublicKey, hash[:], signature)).To(BeTrue())
+				}
+			}
+		})
+	})
+
+	Describe("Verify", func() {
+		var (
+			privKey   *ecdsa.PrivateKey
+			hash      []byte
+			signature []byte
+			N         *big.Int
+		)
+
+		BeforeEach(func() {
+			privKey = vectorKey(signatureVectors[2])
+			sum := sha256.Sum256([]byte(signatureVectors[2].message))
+			hash = sum[:]
+			signature = Sign(privKey, hash)
+			N = privKey.Curve.Params().N
+		})
+
+		It("should reject the high-S twin of a valid signature", func() {
+			r := new(big.Int).SetBytes(signature[:SignatureLen/2])
+			s := new(big.Int).SetBytes(signature[SignatureLen/2:])
+			highS := new(big.Int).Sub(N, s)
+
+			// the twin is a valid ECDSA signature, only its s is not canonical
+			Expect(ecdsa.Verify(&privKey.PublicKey, hash, r, highS)).To(BeTrue())
+			Expect(Verify(&privKey.PublicKey, hash, compactSignature(r, highS))).To(BeFalse())
+		})
+
+		It("should reject signatures of the wrong length", func() {
+			Expect(Verify(&privKey.PublicKey, hash, append(signature, 0))).To(BeFalse())
+			Expect(Verify(&privKey.PublicKey, hash, append([]byte{0}, signature...))).To(BeFalse())
+			Expect(Verify(&privKey.PublicKey, hash, signature[:SignatureLen-1])).To(BeFalse())
+			Expect(Verify(&privKey.PublicKey, hash, nil)).To(BeFalse())
+		})
+
+		It("should reject r and s out of range", func() {
+			r := new(big.Int).SetBytes(signature[:SignatureLen/2])
+			s := new(big.Int).SetBytes(signature[SignatureLen/2:])
+			zero := big.NewInt(0)
+			max := new(big.Int).Sub(new(big.Int).Lsh(big.NewInt(1), 256), big.NewInt(1))
+
+			Expect(Verify(&privKey.PublicKey, hash, compactSignature(zero, s))).To(BeFalse())
+			Expect(Verify(&privKey.PublicKey, hash, compactSignature(r, zero))).To(BeFalse())
+			Expect(Verify(&privKey.PublicKey, hash, compactSignature(max, s))).To(BeFalse())
+			Expect(Verify(&privKey.PublicKey, hash, compactSignature(N, s))).To(BeFalse())
+			Expect(Verify(&privKey.PublicKey, hash, compactSignature(r, N))).To(BeFalse())
+		})
+
+		It("should reject a signature of another hash", func() {
+			other := sha256.Sum256([]byte("another message"))
+			Expect(Verify(&privKey.PublicKey, other[:], signature)).To(BeFalse())
+		})
+	})
+})
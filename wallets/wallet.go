@@ -1,51 +1,67 @@
 package wallets
 
 import (
-	"bytes"
 	"crypto/ecdsa"
-	"crypto/elliptic"
 	"crypto/rand"
 	"crypto/sha256"
+	"errors"
+	"fmt"
 	"log"
+	"math/big"
+	"strings"
 
+	"github.com/cyprus09/blockchain/chaincfg"
 	"github.com/cyprus09/blockchain/utils"
 	"golang.org/x/crypto/ripemd160"
 )
 
 const (
-	version            = byte(0x00)
-	addressChecksumLen = 4
+	pubKeyHashLen = 20
+	// bech32Version is the witness version Bech32 addresses are written with
+	bech32Version = byte(0x00)
+	// DefaultKeyType is the key type of new wallets
+	DefaultKeyType = KeyTypeP256
 )
 
 // Wallet stores private and public keys
 type Wallet struct {
 	PrivateKey ecdsa.PrivateKey
 	PublicKey  []byte
+	KeyType    KeyType
 }
 
-// NewWallet creates and returns a wallet
-func NewWallet() *Wallet {
-	private, public := newKeyPair()
-	wallet := Wallet{private, public}
+// NewWallet creates and returns a wallet with a random key of the given type
+func NewWallet(keyType KeyType) *Wallet {
+	private, public := newKeyPair(keyType)
+	wallet := Wallet{private, public, keyType}
 
 	return &wallet
 }
 
-// GetAddress returns the wallet address
-func (w *Wallet) GetAddress() []byte {
-	pubKeyHash := w.HashPubKey(w.PublicKey)
+// newWalletFromKey wraps an existing private key in a wallet
+func newWalletFromKey(keyType KeyType, private ecdsa.PrivateKey) *Wallet {
+	pubKey := EncodePubKey(keyType, &private.PublicKey, true)
 
-	versionPayload := append([]byte{version}, pubKeyHash...)
-	checksum := checksum(versionPayload)
+	return &Wallet{private, pubKey, keyType}
+}
 
-	fullPayload := append(versionPayload, checksum...)
-	address := utils.Base58Encode(fullPayload)
+// GetAddress returns the Base58Check wallet address on the active network
+func (w *Wallet) GetAddress() []byte {
+	return []byte(EncodeAddress(w.HashPubKey(w.PublicKey)))
+}
 
-	return address
+// GetBech32Address returns the Bech32 wallet address on the active network
+func (w *Wallet) GetBech32Address() string {
+	return EncodeBech32Address(w.HashPubKey(w.PublicKey))
 }
 
 // HashPubKey hashes a public key
 func (w *Wallet) HashPubKey(pubKey []byte) []byte {
+	return HashPubKey(pubKey)
+}
+
+// HashPubKey hashes a public key with SHA256 followed by RIPEMD160
+func HashPubKey(pubKey []byte) []byte {
 	publicSHA256 := sha256.Sum256(pubKey)
 
 	RIPEMD160Hasher := ripemd160.New()
@@ -59,38 +75,120 @@ func (w *Wallet) HashPubKey(pubKey []byte) []byte {
 	return publicRIPEMD160
 }
 
-// ValidateAddress checks if the given address is valid or not
-func ValidateAddress(address string) bool {
-	pubKeyhash := utils.Base58Decode([]byte(address))
+// EncodeAddress encodes a pubkey hash as a Base58Check address on the active network
+func EncodeAddress(pubKeyHash []byte) string {
+	versionPayload := append([]byte{chaincfg.ActiveParams().PubKeyHashAddrID}, pubKeyHash...)
+
+	return string(utils.Base58CheckEncode(versionPayload))
+}
+
+// EncodeBech32Address encodes a pubkey hash as a version 0 Bech32 address on the active network
+func EncodeBech32Address(pubKeyHash []byte) string {
+	program, err := utils.ConvertBits(pubKeyHash, 8, 5, true)
+	if err != nil {
+		log.Panic(err)
+	}
 
-	actualChecksum := pubKeyhash[len(pubKeyhash)-addressChecksumLen:]
-	version := pubKeyhash[0]
+	address, err := utils.Bech32Encode(chaincfg.ActiveParams().Bech32HRP, append([]byte{bech32Version}, program...))
+	if err != nil {
+		log.Panic(err)
+	}
 
-	pubKeyhash = pubKeyhash[1 : len(pubKeyhash)-addressChecksumLen]
+	return address
+}
 
-	targetChecksum := checksum(append([]byte{version}, pubKeyhash...))
+// DecodeAddress returns the pubkey hash of a Base58Check or Bech32 address of the active network
+func DecodeAddress(address string) ([]byte, error) {
+	if sep := strings.LastIndexByte(address, '1'); sep > 0 && networkWithHRP(strings.ToLower(address[:sep])) != "" {
+		return decodeBech32Address(address)
+	}
 
-	return bytes.Equal(actualChecksum, targetChecksum)
+	return decodeBase58Address(address)
 }
 
-// checksum generates a checksum for the public key
-func checksum(payload []byte) []byte {
-	firstSHA := sha256.Sum256(payload)
-	secondSHA := sha256.Sum256(firstSHA[:])
+// ValidateAddress checks if the given address is a valid address of the active network
+// and reports why it is not
+func ValidateAddress(address string) error {
+	_, err := DecodeAddress(address)
 
-	return secondSHA[:addressChecksumLen]
+	return err
+}
+
+// decodeBase58Address returns the pubkey hash of a Base58Check address
+func decodeBase58Address(address string) ([]byte, error) {
+	if address == "" {
+		return nil, errors.New("address is empty")
+	}
+
+	versionPayload, err := utils.Base58CheckDecode([]byte(address))
+	if err != nil {
+		return nil, err
+	}
+	if len(versionPayload) != 1+pubKeyHashLen {
+		return nil, fmt.Errorf("address holds %d bytes, expected %d", len(versionPayload), 1+pubKeyHashLen)
+	}
+
+	if version := versionPayload[0]; version != chaincfg.ActiveParams().PubKeyHashAddrID {
+		if names := networksWithAddrID(version); len(names) > 0 {
+			return nil, fmt.Errorf("address is for %s, not %s", strings.Join(names, " or "), chaincfg.ActiveParams().Name)
+		}
+		return nil, fmt.Errorf("address has unknown version 0x%02x", version)
+	}
+
+	return versionPayload[1:], nil
+}
+
+// decodeBech32Address returns the pubkey hash of a Bech32 address
+func decodeBech32Address(address string) ([]byte, error) {
+	hrp, data, err := utils.Bech32Decode(address)
+	if err != nil {
+		return nil, err
+	}
+
+	if hrp != chaincfg.ActiveParams().Bech32HRP {
+		return nil, fmt.Errorf("address is for %s, not %s", networkWithHRP(hrp), chaincfg.ActiveParams().Name)
+	}
+	if len(data) == 0 || data[0] != bech32Version {
+		return nil, errors.New("bech32 address has an unsupported version")
+	}
+
+	pubKeyHash, err := utils.ConvertBits(data[1:], 5, 8, false)
+	if err != nil {
+		return nil, fmt.Errorf("bech32 address: %v", err)
+	}
+	if len(pubKeyHash) != pubKeyHashLen {
+		return nil, fmt.Errorf("bech32 address holds %d bytes, expected %d", len(pubKeyHash), pubKeyHashLen)
+	}
+
+	return pubKeyHash, nil
 }
 
 // newKeyPair generates a set of private and public key for the given wallet
-func newKeyPair() (ecdsa.PrivateKey, []byte) {
-	curve := elliptic.P256()
+func newKeyPair(keyType KeyType) (ecdsa.PrivateKey, []byte) {
+	curve := keyType.Curve()
 
 	private, err := ecdsa.GenerateKey(curve, rand.Reader)
 	if err != nil {
 		log.Panic(err)
 	}
 
-	pubKey := append(private.PublicKey.X.Bytes(), private.PublicKey.Y.Bytes()...)
+	pubKey := EncodePubKey(keyType, &private.PublicKey, true)
 
 	return *private, pubKey
 }
+
+// privateKeyBytes returns the private scalar padded to the curve size
+func privateKeyBytes(privKey ecdsa.PrivateKey) []byte {
+	return privKey.D.FillBytes(make([]byte, (privKey.Curve.Params().BitSize+7)/8))
+}
+
+// privateKeyFromBytes rebuilds a private key and its public half from the private scalar
+func privateKeyFromBytes(keyType KeyType, d []byte) ecdsa.PrivateKey {
+	curve := keyType.Curve()
+
+	private := ecdsa.PrivateKey{D: new(big.Int).SetBytes(d)}
+	private.PublicKey.Curve = curve
+	private.PublicKey.X, private.PublicKey.Y = curve.ScalarBaseMult(d)
+
+	return private
+}
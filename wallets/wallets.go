@@ -4,37 +4,211 @@ import (
 	"bytes"
 	"crypto/elliptic"
 	"encoding/gob"
+	"errors"
 	"fmt"
 	"io/ioutil"
 	"log"
 	"os"
+	"strings"
+
+	"github.com/cyprus09/blockchain/utils"
+	"github.com/tyler-smith/go-bip39"
 )
 
-const walletFile = "wallet_%s.dat"
+const (
+	walletFile = "wallet_%s.dat"
+	// oldSessionFile held the wallet key in the clear in earlier versions, it is removed on sight
+	oldSessionFile = "wallet_%s.unlock"
+	// mnemonicAD binds the sealed mnemonic to its place in the file
+	mnemonicAD = "mnemonic"
+)
 
 // Wallets stores a collection of wallets
 type Wallets struct {
 	Wallets map[string]*Wallet
+
+	// crypto is nil for plain wallet files
+	crypto        *walletCrypto
+	encryptedKeys map[string][]byte
+	// sealedAddresses maps the address of a key sealed under an address of another
+	// version byte to that address, until the next unlock seals it again
+	sealedAddresses map[string]string
+	// key is the derived wallet key, set while an encrypted wallet is unlocked
+	key []byte
+
+	// mnemonic is the HD backup phrase, empty while an encrypted wallet is locked
+	mnemonic          string
+	encryptedMnemonic []byte
+	// nextIndex is the HD index of the next address
+	nextIndex uint32
+	// hdKeyType is the key type of addresses derived from the mnemonic
+	hdKeyType KeyType
+
+	// watchOnly maps addresses tracked without a private key to their pubkey hash
+	watchOnly map[string][]byte
+	history   *WalletHistory
+}
+
+// walletRecord is how a single key is written to the wallet file
+type walletRecord struct {
+	PublicKey []byte
+	// PrivateKey is the private scalar, left empty in encrypted files
+	PrivateKey []byte
+	// EncryptedKey is the sealed private scalar of encrypted files
+	EncryptedKey []byte
+	// SealedAddress is the address EncryptedKey is bound to when it is not the record's own
+	SealedAddress string
+	KeyType       KeyType
+}
+
+// walletFileContent is the layout of the wallet file
+type walletFileContent struct {
+	Crypto  *walletCrypto
+	Records map[string]walletRecord
+
+	Mnemonic          string
+	EncryptedMnemonic []byte
+	NextIndex         uint32
+	// HDKeyType is zero, the legacy key type, in files written before key types existed
+	HDKeyType KeyType
+
+	WatchOnly map[string][]byte
+	History   *WalletHistory
 }
 
-// NewWallets creates Wallets and fills it from a file if it exists
+// NewWallets creates Wallets and fills it from a file if it exists.
+// An encrypted wallet comes back locked
 func NewWallets(nodeID string) (*Wallets, error) {
 	wallets := Wallets{}
 	wallets.Wallets = make(map[string]*Wallet)
+	wallets.encryptedKeys = make(map[string][]byte)
+	wallets.sealedAddresses = make(map[string]string)
+	wallets.watchOnly = make(map[string][]byte)
 
 	err := wallets.LoadFromFile(nodeID)
+	os.Remove(utils.DataPath(fmt.Sprintf(oldSessionFile, nodeID)))
 
 	return &wallets, err
 }
 
-// CreateWallet adds a Wallet to Wallets
-func (ws *Wallets) CreateWallet() string {
-	wallet := NewWallet()
+// CreateWallet derives the next HD address and adds it to Wallets.
+// The first call on a wallet without an HD seed generates a new mnemonic for DefaultKeyType keys
+func (ws *Wallets) CreateWallet() (string, error) {
+	if ws.IsLocked() {
+		return "", ErrWalletLocked
+	}
+
+	if !ws.HasHDSeed() {
+		if err := ws.NewHDSeed(DefaultKeyType); err != nil {
+			return "", err
+		}
+	}
+
+	wallet, err := ws.DeriveWallet(ws.nextIndex)
+	if err != nil {
+		return "", err
+	}
+	ws.nextIndex++
+
+	return ws.addWallet(wallet)
+}
+
+// addWallet stores wallet under its address, sealing its private key in encrypted wallets
+func (ws *Wallets) addWallet(wallet *Wallet) (string, error) {
 	address := fmt.Sprintf("%s", wallet.GetAddress())
 
+	if ws.IsEncrypted() {
+		sealed, err := seal(ws.key, privateKeyBytes(wallet.PrivateKey), []byte(address))
+		if err != nil {
+			return "", err
+		}
+		ws.encryptedKeys[address] = sealed
+	}
 	ws.Wallets[address] = wallet
+	// the private key supersedes a watch-only entry of the same address
+	delete(ws.watchOnly, address)
 
-	return address
+	return address, nil
+}
+
+// NewHDSeed generates a new mnemonic whose addresses use keyType keys
+func (ws *Wallets) NewHDSeed(keyType KeyType) error {
+	entropy, err := bip39.NewEntropy(128)
+	if err != nil {
+		return err
+	}
+	mnemonic, err := bip39.NewMnemonic(entropy)
+	if err != nil {
+		return err
+	}
+
+	return ws.SetMnemonic(mnemonic, keyType)
+}
+
+// HDKeyType returns the key type of addresses derived from the HD seed
+func (ws *Wallets) HDKeyType() KeyType {
+	return ws.hdKeyType
+}
+
+// HasHDSeed reports whether the wallet derives its addresses from a mnemonic
+func (ws *Wallets) HasHDSeed() bool {
+	return ws.mnemonic != "" || len(ws.encryptedMnemonic) > 0
+}
+
+// Mnemonic returns the backup phrase of the HD seed
+func (ws *Wallets) Mnemonic() (string, error) {
+	if !ws.HasHDSeed() {
+		return "", errors.New("wallet has no HD seed")
+	}
+	if ws.IsLocked() {
+		return "", ErrWalletLocked
+	}
+
+	return ws.mnemonic, nil
+}
+
+// SetMnemonic makes mnemonic the HD seed of a wallet that has none yet
+func (ws *Wallets) SetMnemonic(mnemonic string, keyType KeyType) error {
+	if ws.HasHDSeed() {
+		return errors.New("wallet already has an HD seed")
+	}
+	if ws.IsLocked() {
+		return ErrWalletLocked
+	}
+
+	mnemonic = strings.Join(strings.Fields(mnemonic), " ")
+	if !bip39.IsMnemonicValid(mnemonic) {
+		return errors.New("mnemonic is not valid")
+	}
+
+	if ws.IsEncrypted() {
+		sealed, err := seal(ws.key, []byte(mnemonic), []byte(mnemonicAD))
+		if err != nil {
+			return err
+		}
+		ws.encryptedMnemonic = sealed
+	}
+	ws.mnemonic = mnemonic
+	ws.hdKeyType = keyType
+	ws.nextIndex = 0
+
+	return nil
+}
+
+// DeriveWallet derives the HD address at index without adding it to Wallets
+func (ws *Wallets) DeriveWallet(index uint32) (*Wallet, error) {
+	mnemonic, err := ws.Mnemonic()
+	if err != nil {
+		return nil, err
+	}
+
+	master := NewMasterKey(ws.hdKeyType, bip39.NewSeed(mnemonic, ""))
+	key, err := master.DerivePath(fmt.Sprintf("%s/%d", hdAccountPath, index))
+	if err != nil {
+		return nil, err
+	}
+
+	return newWalletFromKey(ws.hdKeyType, key.PrivateKey()), nil
 }
 
 // GetAddresses returns an array of addresses stored in the wallet file
@@ -48,14 +222,226 @@ func (ws *Wallets) GetAddresses() []string {
 	return addresses
 }
 
-// GetWallet returns a Wallet by its address
-func (ws *Wallets) GetWallet(address string) Wallet {
-	return *ws.Wallets[address]
+// AddWatchOnly tracks an address the wallet holds no private key for
+func (ws *Wallets) AddWatchOnly(address string) error {
+	pubKeyHash, err := DecodeAddress(address)
+	if err != nil {
+		return fmt.Errorf("address %s is not valid: %v", address, err)
+	}
+
+	address = EncodeAddress(pubKeyHash)
+	if _, ok := ws.Wallets[address]; ok {
+		return fmt.Errorf("address %s is already in the wallet with its private key", address)
+	}
+
+	ws.watchOnly[address] = pubKeyHash
+
+	return nil
+}
+
+// GetWatchOnlyAddresses returns the addresses tracked without a private key
+func (ws *Wallets) GetWatchOnlyAddresses() []string {
+	var addresses []string
+
+	for address := range ws.watchOnly {
+		addresses = append(addresses, address)
+	}
+
+	return addresses
+}
+
+// PubKeyHashes maps the pubkey hash of every wallet address, watch-only ones included, to the address
+func (ws *Wallets) PubKeyHashes() map[string]string {
+	pubKeyHashes := make(map[string]string)
+
+	for address, wallet := range ws.Wallets {
+		pubKeyHashes[string(HashPubKey(wallet.PublicKey))] = address
+	}
+	for address, pubKeyHash := range ws.watchOnly {
+		pubKeyHashes[string(pubKeyHash)] = address
+	}
+
+	return pubKeyHashes
+}
+
+// GetWallet returns a Wallet by its Base58Check or Bech32 address. Its private key is only
+// usable while the wallet is unlocked
+func (ws *Wallets) GetWallet(address string) (Wallet, error) {
+	if pubKeyHash, err := DecodeAddress(address); err == nil {
+		address = EncodeAddress(pubKeyHash)
+	}
+
+	wallet, ok := ws.Wallets[address]
+	if _, watched := ws.watchOnly[address]; !ok && watched {
+		return Wallet{}, fmt.Errorf("address %s is watch-only, the wallet has no private key for it", address)
+	}
+	if !ok {
+		return Wallet{}, fmt.Errorf("address %s is not in the wallet", address)
+	}
+
+	if ws.IsLocked() {
+		return Wallet{}, ErrWalletLocked
+	}
+
+	return *wallet, nil
+}
+
+// IsEncrypted reports whether the private keys are encrypted at rest
+func (ws *Wallets) IsEncrypted() bool {
+	return ws.crypto != nil
+}
+
+// IsLocked reports whether the private keys of an encrypted wallet are unavailable
+func (ws *Wallets) IsLocked() bool {
+	return ws.IsEncrypted() && ws.key == nil
+}
+
+// Encrypt encrypts a plain wallet under passphrase. The keys stay unlocked in memory
+func (ws *Wallets) Encrypt(passphrase string) error {
+	if ws.IsEncrypted() {
+		return ErrAlreadyEncrypted
+	}
+
+	crypto, key, err := newWalletCrypto(passphrase)
+	if err != nil {
+		return err
+	}
+
+	return ws.rekey(crypto, key)
+}
+
+// Unlock decrypts the private keys with passphrase
+func (ws *Wallets) Unlock(passphrase string) error {
+	if !ws.IsEncrypted() {
+		return ErrNotEncrypted
+	}
+
+	key, err := ws.crypto.deriveKey(passphrase)
+	if err != nil {
+		return err
+	}
+
+	return ws.UnlockWithKey(key)
+}
+
+// Key returns a copy of the derived wallet key of an unlocked wallet, which UnlockWithKey
+// takes instead of the passphrase
+func (ws *Wallets) Key() ([]byte, error) {
+	if !ws.IsEncrypted() {
+		return nil, ErrNotEncrypted
+	}
+	if ws.IsLocked() {
+		return nil, ErrWalletLocked
+	}
+
+	return append([]byte{}, ws.key...), nil
+}
+
+// Lock drops the decrypted private keys from memory
+func (ws *Wallets) Lock() {
+	if !ws.IsEncrypted() {
+		return
+	}
+
+	for _, wallet := range ws.Wallets {
+		wallet.PrivateKey.D = nil
+	}
+	ws.mnemonic = ""
+	for i := range ws.key {
+		ws.key[i] = 0
+	}
+	ws.key = nil
+}
+
+// ChangePassphrase re-encrypts every private key under a fresh key derived from newPassphrase
+func (ws *Wallets) ChangePassphrase(oldPassphrase, newPassphrase string) error {
+	if err := ws.Unlock(oldPassphrase); err != nil {
+		return err
+	}
+
+	crypto, key, err := newWalletCrypto(newPassphrase)
+	if err != nil {
+		return err
+	}
+
+	return ws.rekey(crypto, key)
+}
+
+// rekey seals every private key under key and makes crypto the wallet's parameters
+func (ws *Wallets) rekey(crypto *walletCrypto, key []byte) error {
+	encryptedKeys := make(map[string][]byte)
+
+	for address, wallet := range ws.Wallets {
+		sealed, err := seal(key, privateKeyBytes(wallet.PrivateKey), []byte(address))
+		if err != nil {
+			return err
+		}
+		encryptedKeys[address] = sealed
+	}
+
+	if ws.mnemonic != "" {
+		sealed, err := seal(key, []byte(ws.mnemonic), []byte(mnemonicAD))
+		if err != nil {
+			return err
+		}
+		ws.encryptedMnemonic = sealed
+	}
+
+	ws.crypto = crypto
+	ws.encryptedKeys = encryptedKeys
+	ws.sealedAddresses = make(map[string]string)
+	ws.key = key
+
+	return nil
+}
+
+// UnlockWithKey opens every sealed private key with the derived wallet key
+func (ws *Wallets) UnlockWithKey(key []byte) error {
+	if !ws.IsEncrypted() {
+		return ErrNotEncrypted
+	}
+	if err := ws.crypto.verifyKey(key); err != nil {
+		return err
+	}
+
+	for address, sealed := range ws.encryptedKeys {
+		sealedAddress, migrated := ws.sealedAddresses[address]
+		if !migrated {
+			sealedAddress = address
+		}
+
+		d, err := open(key, sealed, []byte(sealedAddress))
+		if err != nil {
+			return fmt.Errorf("private key of %s is corrupt: %v", address, err)
+		}
+		wallet := ws.Wallets[address]
+		wallet.PrivateKey = privateKeyFromBytes(wallet.KeyType, d)
+
+		if migrated {
+			resealed, err := seal(key, d, []byte(address))
+			if err != nil {
+				return err
+			}
+			ws.encryptedKeys[address] = resealed
+			delete(ws.sealedAddresses, address)
+		}
+	}
+
+	if len(ws.encryptedMnemonic) > 0 {
+		mnemonic, err := open(key, ws.encryptedMnemonic, []byte(mnemonicAD))
+		if err != nil {
+			return fmt.Errorf("HD seed is corrupt: %v", err)
+		}
+		ws.mnemonic = string(mnemonic)
+	}
+	ws.key = append([]byte{}, key...)
+
+	return nil
 }
 
 // LoadFromFile loads wallets from the file
 func (ws *Wallets) LoadFromFile(nodeID string) error {
-	walletFile := fmt.Sprintf(walletFile, nodeID)
+	walletFile := utils.DataPath(fmt.Sprintf(walletFile, nodeID))
 
 	if _, err := os.Stat(walletFile); os.IsNotExist(err) {
 		return err
@@ -66,33 +452,113 @@ func (ws *Wallets) LoadFromFile(nodeID string) error {
 		log.Panic(err)
 	}
 
-	var wallets Wallets
+	var content walletFileContent
+	decoder := gob.NewDecoder(bytes.NewReader(fileContent))
+	err = decoder.Decode(&content)
+	if err != nil {
+		return ws.loadLegacy(fileContent)
+	}
+
+	ws.crypto = content.Crypto
+	ws.mnemonic = content.Mnemonic
+	ws.encryptedMnemonic = content.EncryptedMnemonic
+	ws.nextIndex = content.NextIndex
+	ws.hdKeyType = content.HDKeyType
+	ws.history = content.History
+	// addresses are derived again, as files of earlier versions used other version bytes
+	rederived := false
+	for storedAddress, pubKeyHash := range content.WatchOnly {
+		address := EncodeAddress(pubKeyHash)
+		ws.watchOnly[address] = pubKeyHash
+		rederived = rederived || address != storedAddress
+	}
+	for storedAddress, record := range content.Records {
+		wallet := &Wallet{PublicKey: record.PublicKey, KeyType: record.KeyType}
+		wallet.PrivateKey.Curve = record.KeyType.Curve()
+		address := EncodeAddress(HashPubKey(record.PublicKey))
+		rederived = rederived || address != storedAddress
+
+		if len(record.EncryptedKey) > 0 {
+			ws.encryptedKeys[address] = record.EncryptedKey
+
+			sealedAddress := record.SealedAddress
+			if sealedAddress == "" {
+				sealedAddress = storedAddress
+			}
+			if sealedAddress != address {
+				ws.sealedAddresses[address] = sealedAddress
+			}
+		} else {
+			wallet.PrivateKey = privateKeyFromBytes(record.KeyType, record.PrivateKey)
+		}
+		ws.Wallets[address] = wallet
+	}
+	// the history is indexed by address, so it is rebuilt under the new ones
+	if rederived {
+		ws.ResetHistory()
+	}
+
+	return nil
+}
+
+// loadLegacy reads wallet files written as a gob of the whole Wallets struct
+func (ws *Wallets) loadLegacy(fileContent []byte) error {
+	var wallets struct {
+		Wallets map[string]*Wallet
+	}
+
 	gob.Register(elliptic.P256())
 	decoder := gob.NewDecoder(bytes.NewReader(fileContent))
-	err = decoder.Decode(&wallets)
+	err := decoder.Decode(&wallets)
 	if err != nil {
 		log.Panic(err)
 	}
 
-	ws.Wallets = wallets.Wallets
+	for _, wallet := range wallets.Wallets {
+		ws.Wallets[EncodeAddress(HashPubKey(wallet.PublicKey))] = wallet
+	}
 
 	return nil
 }
 
-// SaveToFile saves wallets to a file
+// SaveToFile saves wallets to a file readable only by its owner.
+// Encrypted wallets keep their private keys sealed on disk
 func (ws *Wallets) SaveToFile(nodeID string) {
 	var content bytes.Buffer
-	walletFile := fmt.Sprintf(walletFile, nodeID)
+	walletFile := utils.DataPath(fmt.Sprintf(walletFile, nodeID))
 
-	gob.Register(elliptic.P256())
+	fileContent := walletFileContent{
+		Crypto:    ws.crypto,
+		Records:   make(map[string]walletRecord),
+		NextIndex: ws.nextIndex,
+		HDKeyType: ws.hdKeyType,
+		WatchOnly: ws.watchOnly,
+		History:   ws.history,
+	}
+	if ws.IsEncrypted() {
+		fileContent.EncryptedMnemonic = ws.encryptedMnemonic
+	} else {
+		fileContent.Mnemonic = ws.mnemonic
+	}
+	for address, wallet := range ws.Wallets {
+		record := walletRecord{PublicKey: wallet.PublicKey, KeyType: wallet.KeyType}
+
+		if ws.IsEncrypted() {
+			record.EncryptedKey = ws.encryptedKeys[address]
+			record.SealedAddress = ws.sealedAddresses[address]
+		} else {
+			record.PrivateKey = privateKeyBytes(wallet.PrivateKey)
+		}
+		fileContent.Records[address] = record
+	}
 
 	encoder := gob.NewEncoder(&content)
-	err := encoder.Encode(ws)
+	err := encoder.Encode(fileContent)
 	if err != nil {
 		log.Panic(err)
 	}
 
-	err = ioutil.WriteFile(walletFile, content.Bytes(), 0644)
+	err = utils.WriteFileAtomic(walletFile, content.Bytes(), 0600)
 	if err != nil {
 		log.Panic(err)
 	}